@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jonstacks/aws/pkg/cmd"
+)
+
+func main() {
+	cmd.Execute(os.Args[1:])
+}
@@ -1,20 +1,8 @@
+// Command empty-subnets is a shim for `aws-audit vpc empty-subnets`.
 package main
 
-import (
-	"os"
-
-	"github.com/jonstacks/aws/pkg/models"
-	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/jonstacks/aws/pkg/views"
-)
+import "github.com/jonstacks/aws/pkg/cmd"
 
 func main() {
-	models.Init(models.DefaultSession())
-
-	subnets, err := models.Subnets()
-	utils.ExitErrorHandler(err)
-
-	var view views.View
-	view = views.NewEmptySubnets(subnets)
-	view.Render(os.Stdout)
+	cmd.Main("vpc", "empty-subnets")
 }
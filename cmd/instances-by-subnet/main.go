@@ -1,20 +1,8 @@
+// Command instances-by-subnet is a shim for `aws-audit instances by-subnet`.
 package main
 
-import (
-	"github.com/jonstacks/aws/pkg/models"
-	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/jonstacks/aws/pkg/views"
-)
+import "github.com/jonstacks/aws/pkg/cmd"
 
 func main() {
-	models.Init(models.DefaultSession())
-
-	subnets, err := models.Subnets()
-	utils.ExitErrorHandler(err)
-
-	opts := models.RunningInstancesOpts{IncludeSpot: true}
-	instances := models.RunningInstances(opts)
-
-	view := views.NewInstancesBySubnet(instances, subnets)
-	view.Print()
+	cmd.Main("instances", "by-subnet")
 }
@@ -1,20 +1,8 @@
+// Command reserved-rds-audit is a shim for `aws-audit rds-ri`.
 package main
 
-import (
-	"github.com/jonstacks/aws/pkg/models"
-	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/jonstacks/aws/pkg/views"
-)
+import "github.com/jonstacks/aws/pkg/cmd"
 
 func main() {
-	models.Init(models.DefaultSession())
-
-	ris, err := models.ReservedDBInstances()
-	utils.ExitErrorHandler(err)
-
-	dbs, err := models.RunningDBInstances()
-	utils.ExitErrorHandler(err)
-
-	v := views.NewRDSReservationUtilization(dbs, ris)
-	v.Print()
+	cmd.Main("rds-ri")
 }
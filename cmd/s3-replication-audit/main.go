@@ -1,29 +1,8 @@
+// Command s3-replication-audit is a shim for `aws-audit s3 replication`.
 package main
 
-import (
-	"os"
-
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/jonstacks/aws/pkg/models"
-	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/jonstacks/aws/pkg/views"
-)
+import "github.com/jonstacks/aws/pkg/cmd"
 
 func main() {
-	models.S3Client(s3.New(models.DefaultSession()))
-
-	buckets, err := models.ListBuckets()
-	utils.ExitErrorHandler(err)
-
-	replications := make([]*s3.GetBucketReplicationOutput, len(buckets))
-	for i, bucket := range buckets {
-		replication, err := models.GetBucketReplication(bucket)
-		if err != nil {
-			replication = nil
-		}
-		replications[i] = replication
-	}
-
-	audit := views.NewS3ReplicationAudit(buckets, replications)
-	audit.Render(os.Stdout)
+	cmd.Main("s3", "replication")
 }
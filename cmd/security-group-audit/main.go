@@ -1,20 +1,8 @@
+// Command security-group-audit is a shim for `aws-audit sg audit`.
 package main
 
-import (
-	"github.com/jonstacks/aws/pkg/models"
-	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/jonstacks/aws/pkg/views"
-)
+import "github.com/jonstacks/aws/pkg/cmd"
 
 func main() {
-	models.Init(models.DefaultSession())
-
-	sgs, err := models.SecurityGroups()
-	utils.ExitErrorHandler(err)
-
-	ifcs, err := models.NetworkInterfaces()
-	utils.ExitErrorHandler(err)
-
-	v := views.NewSecurityGroupAudit(sgs, ifcs)
-	v.Print()
+	cmd.Main("sg", "audit")
 }
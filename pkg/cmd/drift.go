@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newDriftCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "drift <older-snapshot> [newer-snapshot]",
+		Short: "Show the resources which changed between two snapshots",
+		Long: `Compare two files written by the snapshot command and show the resources
+which were added, removed or modified, along with the fields that changed.
+
+If only one snapshot is given it is compared against the current state of the
+selected accounts and regions. Only the scopes which are queried are compared,
+so resources in other regions or accounts of the snapshot aren't reported as
+removed.`,
+		Example: "  aws-audit drift inventory-2024-01.json.gz inventory-2024-02.json.gz\n" +
+			"  aws-audit drift --regions all inventory-2024-01.json.gz",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(c *cobra.Command, args []string) error {
+			older, err := models.LoadSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+
+			var newer *models.Snapshot
+			if len(args) == 2 {
+				newer, err = models.LoadSnapshot(args[1])
+				if err != nil {
+					return err
+				}
+			} else {
+				invs, err := o.Inventories()
+				if err != nil {
+					return err
+				}
+				newer, err = models.Capture(o.pool, invs)
+				if err = o.warnScopeErrors(c, len(newer.Regional)+len(newer.Global), err); err != nil {
+					return err
+				}
+				older = older.Filter(newer.Scopes())
+			}
+
+			changes, err := models.Diff(older, newer)
+			if err != nil {
+				return err
+			}
+			return o.render(c, views.NewDriftReport(changes))
+		},
+	}
+}
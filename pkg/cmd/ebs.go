@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newEBSCommand(o *Options) *cobra.Command {
+	return newGroupCommand("ebs", "Audit EBS storage",
+		newEBSVolumesCommand(o), newEBSSnapshotsCommand(o))
+}
+
+func newEBSVolumesCommand(o *Options) *cobra.Command {
+	var pricesFile string
+
+	c := &cobra.Command{
+		Use:   "volumes",
+		Short: "List volumes which aren't attached, or are only attached to stopped instances",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			var prices *pricing.Table
+			if pricesFile != "" {
+				var err error
+				if prices, err = pricing.Load(pricesFile); err != nil {
+					return err
+				}
+			}
+
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			volumes, err := models.Collect(invs, (*models.Inventory).Volumes)
+			if err = o.warnScopeErrors(c, len(volumes), err); err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, func(inv *models.Inventory) ([]*ec2.Instance, error) {
+				return inv.Instances(nil)
+			})
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			// Attached volumes would look unused in scopes where the
+			// instances couldn't be listed.
+			if err = dropIncompleteScopes(volumes, scopesOf(instances)); err != nil {
+				return err
+			}
+
+			v := views.NewUnusedVolumes(volumes, instances, views.UnusedVolumesOptions{Prices: prices})
+			return o.render(c, v)
+		},
+	}
+	c.Flags().StringVar(&pricesFile, "prices", "",
+		"A YAML or JSON price table with the monthly storage prices used to estimate the cost of the volumes.")
+	return c
+}
+
+func newEBSSnapshotsCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshots",
+		Short: "Find EBS snapshots whose volume no longer exists and which no AMI uses",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			volumes, err := models.Collect(invs, (*models.Inventory).Volumes)
+			if err = o.warnScopeErrors(c, len(volumes), err); err != nil {
+				return err
+			}
+
+			snapshots, err := models.Collect(invs, (*models.Inventory).EBSSnapshots)
+			if err = o.warnScopeErrors(c, len(snapshots), err); err != nil {
+				return err
+			}
+
+			images, err := models.Collect(invs, (*models.Inventory).Images)
+			if err = o.warnScopeErrors(c, len(images), err); err != nil {
+				return err
+			}
+
+			// A snapshot's volume is only deleted, and the snapshot unused,
+			// if the volumes and images were all listed, so scopes where
+			// either failed are left out.
+			if err = dropIncompleteScopes(snapshots, scopesOf(volumes), scopesOf(images)); err != nil {
+				return err
+			}
+
+			view := views.NewEBSSnapshotAudit(snapshots, volumes, images)
+			return o.render(c, view)
+		},
+	}
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func runningInstances(opts models.RunningInstancesOpts) func(*models.Inventory) ([]*ec2.Instance, error) {
+	return func(inv *models.Inventory) ([]*ec2.Instance, error) {
+		return inv.RunningInstances(opts)
+	}
+}
+
+func newRICommand(o *Options) *cobra.Command {
+	var onlyUnmatched bool
+
+	c := &cobra.Command{
+		Use:   "ri",
+		Short: "Audit reserved EC2 instances against running instances",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ris, err := models.Collect(invs, (*models.Inventory).ReservedInstances)
+			if err = o.warnScopeErrors(c, len(ris), err); err != nil {
+				return err
+			}
+
+			all, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: false}))
+			if err = o.warnScopeErrors(c, len(all), err); err != nil {
+				return err
+			}
+
+			// Reservations would be reported as unused, or instances as
+			// uncovered, in scopes where either failed.
+			complete := []map[models.Scope]bool{scopesOf(ris), scopesOf(all)}
+			if err = dropIncompleteScopes(ris, complete...); err != nil {
+				return err
+			}
+			if err = dropIncompleteScopes(all, complete...); err != nil {
+				return err
+			}
+
+			viewOpts := views.ReservationUtilizationOptions{
+				OnlyUnmatched:   onlyUnmatched,
+				NormalizedUnits: o.normalizedUnits,
+			}
+			v := views.NewReservationUtilization(all, ris, viewOpts)
+			return o.render(c, v)
+		},
+	}
+	c.Flags().BoolVar(&onlyUnmatched, "only-unmatched", false,
+		"Only show instance types that are unmatched in running & reserved instnace count.")
+	return c
+}
+
+func newInstancesCommand(o *Options) *cobra.Command {
+	return newGroupCommand("instances", "Audit running EC2 instances",
+		newInstancesBySubnetCommand(o), newInstancesWithoutCostTagCommand(o), newInstancesStoppedCommand(o))
+}
+
+func newInstancesBySubnetCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "by-subnet",
+		Short: "List running instances grouped by subnet",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			subnets, err := models.Collect(invs, (*models.Inventory).Subnets)
+			if err = o.warnScopeErrors(c, len(subnets), err); err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: true}))
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			if err = dropIncompleteScopes(instances, scopesOf(subnets)); err != nil {
+				return err
+			}
+
+			view := views.NewInstancesBySubnet(instances, subnets)
+			return o.render(c, view)
+		},
+	}
+}
+
+func newInstancesWithoutCostTagCommand(o *Options) *cobra.Command {
+	var costTag string
+
+	c := &cobra.Command{
+		Use:   "without-cost-tag",
+		Short: "List running instances without a non-empty cost tag",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			all, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: true}))
+			if err = o.warnScopeErrors(c, len(all), err); err != nil {
+				return err
+			}
+
+			view := views.NewInstancesWithoutTag(all, costTag)
+			return o.render(c, view)
+		},
+	}
+	c.Flags().StringVar(&costTag, "cost-tag", "cost", "The tag key for determining cost")
+	return c
+}
+
+func newInstancesStoppedCommand(o *Options) *cobra.Command {
+	var stoppedFor time.Duration
+
+	c := &cobra.Command{
+		Use:   "stopped",
+		Short: "List stopped instances with how long they've been stopped and the storage and Elastic IPs they keep",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, func(inv *models.Inventory) ([]*ec2.Instance, error) {
+				return inv.Instances(nil)
+			})
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			volumes, err := models.Collect(invs, (*models.Inventory).Volumes)
+			if err = o.warnScopeErrors(c, len(volumes), err); err != nil {
+				return err
+			}
+
+			addresses, err := models.Collect(invs, (*models.Inventory).Addresses)
+			if err = o.warnScopeErrors(c, len(addresses), err); err != nil {
+				return err
+			}
+
+			// The storage and addresses an instance keeps would be missing
+			// in scopes where listing them failed.
+			if err = dropIncompleteScopes(instances, scopesOf(volumes), scopesOf(addresses)); err != nil {
+				return err
+			}
+
+			view := views.NewStoppedInstances(instances, volumes, addresses, views.StoppedInstancesOptions{StoppedFor: stoppedFor})
+			return o.render(c, view)
+		},
+	}
+	c.Flags().DurationVar(&stoppedFor, "stopped-for", 0,
+		"Only show instances which have been stopped for at least this long, e.g. 720h. "+
+			"Instances whose stop time is unknown are always shown.")
+	return c
+}
+
+func newSpotIPCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "spot-ip <spot-instance-request-id>...",
+		Short:   "Print the private IPs of the instances of spot instance requests",
+		Example: "  aws-audit spot-ip sir-kd4rbkim sir-cmd8atam",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			inv, err := o.Inventory()
+			if err != nil {
+				return err
+			}
+
+			requests, err := inv.SpotInstanceRequests(args)
+			if err != nil {
+				return err
+			}
+
+			instanceIDs := make([]string, 0)
+			for _, request := range requests {
+				instanceIDs = append(instanceIDs, aws.StringValue(request.InstanceId))
+			}
+
+			instances, err := inv.Instances(instanceIDs)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range instances {
+				if i != nil && i.PrivateIpAddress != nil {
+					fmt.Fprintln(c.OutOrStdout(), aws.StringValue(i.PrivateIpAddress))
+				}
+			}
+			return nil
+		},
+	}
+}
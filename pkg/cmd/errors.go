@@ -1,14 +1,87 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/spf13/cobra"
+)
+
+// The exit statuses of the commands.
+const (
+	// ExitError is used when a command fails, e.g. because of an API error.
+	ExitError = 2
+	// ExitViolation is used when an audit has findings at or above --fail-on.
+	ExitViolation = 3
 )
 
-// HandleError is a common error handler for our commands.
+// HandleError is a common error handler for our commands. Policy violations
+// print each of the findings which caused them.
 func HandleError(err error) {
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
-		os.Exit(2)
+	if err == nil {
+		return
+	}
+
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		for _, f := range violation.Findings {
+			fmt.Fprintf(os.Stderr, "FAIL: %s\n", f)
+		}
+		fmt.Fprintf(os.Stderr, "FAIL: %s\n", err)
+		os.Exit(ExitViolation)
+	}
+
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+	os.Exit(ExitError)
+}
+
+// warnScopeErrors reports the failures of individual accounts or regions to
+// the command's stderr and returns nil, so the results of the remaining scopes
+// are still shown. If none of the scopes succeeded there's nothing to show, so
+// the error is returned instead. It's returned as well when --fail-on is set,
+// as an audit missing some scopes can't be trusted to have no findings. Any
+// other error is returned as is.
+func (o *Options) warnScopeErrors(c *cobra.Command, succeeded int, err error) error {
+	var scopeErrs models.ScopeErrors
+	if !errors.As(err, &scopeErrs) || succeeded == 0 || o.failOn != 0 {
+		return err
+	}
+	for _, scope := range models.SortedScopes(scopeErrs) {
+		fmt.Fprintf(c.ErrOrStderr(), "WARNING: %s: %s\n", scope, scopeErrs[scope])
+	}
+	return nil
+}
+
+// scopesOf returns the set of scopes in results.
+func scopesOf[T any](results map[models.Scope]T) map[models.Scope]bool {
+	scopes := make(map[models.Scope]bool, len(results))
+	for scope := range results {
+		scopes[scope] = true
+	}
+	return scopes
+}
+
+// dropIncompleteScopes deletes the scopes of results which aren't in every one
+// of complete, i.e. where a query the results depend on failed. Audits which
+// report resources as unused need this, as otherwise every resource in such a
+// scope would be reported. An error is returned if no scope is left.
+func dropIncompleteScopes[T any](results map[models.Scope]T, complete ...map[models.Scope]bool) error {
+	dropped := make([]string, 0)
+	for _, scope := range models.SortedScopes(results) {
+		for _, scopes := range complete {
+			if !scopes[scope] {
+				delete(results, scope)
+				dropped = append(dropped, scope.String())
+				break
+			}
+		}
+	}
+	if len(dropped) > 0 && len(results) == 0 {
+		return fmt.Errorf("no scope has complete results, %s failed", strings.Join(dropped, ", "))
 	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newRIExpiryCommand(o *Options) *cobra.Command {
+	var horizons []int
+
+	c := &cobra.Command{
+		Use:   "ri-expiry",
+		Short: "Forecast how EC2 and RDS reservation coverage changes as reservations expire",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ris, err := models.Collect(invs, (*models.Inventory).ReservedInstances)
+			if err = o.warnScopeErrors(c, len(ris), err); err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: false}))
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			dbRIs, err := models.Collect(invs, (*models.Inventory).ReservedDBInstances)
+			if err = o.warnScopeErrors(c, len(dbRIs), err); err != nil {
+				return err
+			}
+
+			dbs, err := models.Collect(invs, runningDBInstances(models.RunningDBInstancesOpts{}))
+			if err = o.warnScopeErrors(c, len(dbs), err); err != nil {
+				return err
+			}
+
+			if err = dropIncompleteReservationScopes(ris, instances, dbRIs, dbs); err != nil {
+				return err
+			}
+
+			v := views.NewReservationExpiry(instances, ris, dbs, dbRIs, views.ReservationExpiryOptions{
+				Now:             time.Now(),
+				Horizons:        horizons,
+				NormalizedUnits: o.normalizedUnits,
+			})
+			return o.render(c, v)
+		},
+	}
+	c.Flags().IntSliceVar(&horizons, "horizons", views.DefaultExpiryHorizons,
+		"Comma separated list of the number of days ahead to forecast coverage for.")
+	return c
+}
+
+// dropIncompleteReservationScopes deletes the scopes where listing either the
+// EC2 reservations or the instances failed, as the reservations would be
+// reported as unused or the instances as uncovered. The same goes for RDS.
+func dropIncompleteReservationScopes(
+	ris map[models.Scope][]*ec2.ReservedInstances,
+	instances map[models.Scope][]*ec2.Instance,
+	dbRIs map[models.Scope][]*rds.ReservedDBInstance,
+	dbs map[models.Scope][]*rds.DBInstance,
+) error {
+	complete := []map[models.Scope]bool{scopesOf(ris), scopesOf(instances)}
+	if err := dropIncompleteScopes(ris, complete...); err != nil {
+		return err
+	}
+	if err := dropIncompleteScopes(instances, complete...); err != nil {
+		return err
+	}
+
+	complete = []map[models.Scope]bool{scopesOf(dbRIs), scopesOf(dbs)}
+	if err := dropIncompleteScopes(dbRIs, complete...); err != nil {
+		return err
+	}
+	return dropIncompleteScopes(dbs, complete...)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	KeyStatusInactive = "Inactive"
+	KeyStatusActive   = "Active"
+)
+
+func newIAMCommand(o *Options) *cobra.Command {
+	return newGroupCommand("iam", "Audit IAM users",
+		newIAMAccessKeysCommand(o))
+}
+
+func newIAMAccessKeysCommand(o *Options) *cobra.Command {
+	var minAge time.Duration
+
+	c := &cobra.Command{
+		Use:   "access-keys",
+		Short: "Show when the active access keys of each IAM user were last used",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			// IAM is a global service, so we only need to query each account once.
+			results, err := models.Collect(models.PerAccount(invs), func(inv *models.Inventory) (accessKeys, error) {
+				return auditAccessKeys(o.pool, inv, minAge)
+			})
+			if err = o.warnScopeErrors(c, len(results), err); err != nil {
+				return err
+			}
+
+			userKeyMap := make(map[models.Scope]map[string][]*iam.AccessKeyMetadata)
+			keyLastUsedMap := make(map[string]*iam.AccessKeyLastUsed)
+			for scope, result := range results {
+				userKeyMap[scope] = result.users
+				for id, lastUsed := range result.lastUsed {
+					keyLastUsedMap[id] = lastUsed
+				}
+			}
+
+			v := views.IAMAccessKeysAudit{
+				Users:    userKeyMap,
+				LastUsed: keyLastUsedMap,
+			}
+			return o.render(c, &v)
+		},
+	}
+	c.Flags().DurationVar(&minAge, "min-age", 10*24*time.Hour, "Ignore access keys created more recently than this.")
+	return c
+}
+
+// accessKeys holds the access keys of each user in an account.
+type accessKeys struct {
+	users    map[string][]*iam.AccessKeyMetadata
+	lastUsed map[string]*iam.AccessKeyLastUsed
+}
+
+func auditAccessKeys(pool *models.Pool, inv *models.Inventory, minAge time.Duration) (accessKeys, error) {
+	result := accessKeys{
+		users:    make(map[string][]*iam.AccessKeyMetadata),
+		lastUsed: make(map[string]*iam.AccessKeyLastUsed),
+	}
+
+	users, err := inv.IAMUsers()
+	if err != nil {
+		return result, err
+	}
+	logrus.Infof("Found %d users in AWS account %s", len(users), inv.Account)
+
+	usernames := make([]string, 0, len(users))
+	for _, user := range users {
+		if user.UserName != nil {
+			usernames = append(usernames, *user.UserName)
+		}
+	}
+
+	userKeys, err := models.Map(pool, usernames, func(username string) ([]*iam.AccessKeyMetadata, error) {
+		keys, err := inv.IAMAccessKeysMeatadata(username)
+		if err != nil {
+			return nil, err
+		}
+		keys = filterInactiveKeys(keys)
+		return filterRecentlyCreatedKeys(keys, minAge), nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	keys := make([]*iam.AccessKeyMetadata, 0)
+	for i, username := range usernames {
+		result.users[username] = userKeys[i]
+		keys = append(keys, userKeys[i]...)
+	}
+
+	lastUsed, err := models.Map(pool, keys, func(key *iam.AccessKeyMetadata) (*iam.AccessKeyLastUsed, error) {
+		resp, err := inv.IAMAccessKeyLastUsed(aws.StringValue(key.AccessKeyId))
+		if err != nil {
+			return nil, err
+		}
+		return resp.AccessKeyLastUsed, nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for i, key := range keys {
+		result.lastUsed[aws.StringValue(key.AccessKeyId)] = lastUsed[i]
+	}
+	return result, nil
+}
+
+func filterInactiveKeys(keys []*iam.AccessKeyMetadata) []*iam.AccessKeyMetadata {
+	filtered := make([]*iam.AccessKeyMetadata, 0)
+	for _, k := range keys {
+		if k == nil {
+			continue
+		}
+
+		if k.Status == nil {
+			continue
+		}
+
+		if aws.StringValue(k.Status) == KeyStatusInactive {
+			continue
+		}
+
+		filtered = append(filtered, k)
+	}
+	return filtered
+}
+
+func filterRecentlyCreatedKeys(keys []*iam.AccessKeyMetadata, d time.Duration) []*iam.AccessKeyMetadata {
+	filtered := make([]*iam.AccessKeyMetadata, 0)
+	for _, k := range keys {
+		if k == nil {
+			continue
+		}
+		if k.CreateDate != nil && time.Since(*k.CreateDate) <= d {
+			continue
+		}
+		filtered = append(filtered, k)
+	}
+	return filtered
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newAMIsCommand(o *Options) *cobra.Command {
+	return newGroupCommand("amis", "Audit the AMIs owned by the account",
+		newAMIsUnusedCommand(o))
+}
+
+func newAMIsUnusedCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unused",
+		Short: "Find AMIs which no instance, launch template or launch configuration uses",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			images, err := models.Collect(invs, (*models.Inventory).Images)
+			if err = o.warnScopeErrors(c, len(images), err); err != nil {
+				return err
+			}
+
+			var users views.ImageUsers
+			users.Instances, err = models.Collect(invs, func(inv *models.Inventory) ([]*ec2.Instance, error) {
+				return inv.Instances(nil)
+			})
+			if err = o.warnScopeErrors(c, len(users.Instances), err); err != nil {
+				return err
+			}
+
+			users.LaunchTemplateVersions, err = models.Collect(invs, (*models.Inventory).LaunchTemplateVersions)
+			if err = o.warnScopeErrors(c, len(users.LaunchTemplateVersions), err); err != nil {
+				return err
+			}
+
+			users.LaunchConfigurations, err = models.Collect(invs, (*models.Inventory).LaunchConfigurations)
+			if err = o.warnScopeErrors(c, len(users.LaunchConfigurations), err); err != nil {
+				return err
+			}
+
+			// An image is only unused if everything which could use it was
+			// listed, so scopes where any of that failed are left out.
+			err = dropIncompleteScopes(images, scopesOf(users.Instances),
+				scopesOf(users.LaunchTemplateVersions), scopesOf(users.LaunchConfigurations))
+			if err != nil {
+				return err
+			}
+
+			view := views.NewUnusedImages(images, users)
+			return o.render(c, view)
+		},
+	}
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newVPCCommand(o *Options) *cobra.Command {
+	return newGroupCommand("vpc", "Audit VPCs and subnets",
+		newVPCFreeRangesCommand(o), newVPCEmptySubnetsCommand(o), newVPCUnattachedCommand(o))
+}
+
+func newVPCFreeRangesCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "free-ranges",
+		Short: "Show the free ranges in each VPC that can be used to create new subnets",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			vpcs, err := models.Collect(invs, (*models.Inventory).VPCs)
+			if err = o.warnScopeErrors(c, len(vpcs), err); err != nil {
+				return err
+			}
+
+			subnets, err := models.Collect(invs, (*models.Inventory).Subnets)
+			if err = o.warnScopeErrors(c, len(subnets), err); err != nil {
+				return err
+			}
+
+			// A VPC would look free in scopes where its subnets couldn't be
+			// listed.
+			if err = dropIncompleteScopes(vpcs, scopesOf(subnets)); err != nil {
+				return err
+			}
+
+			view := views.NewVPCFreeSubnets(vpcs, subnets)
+			return o.render(c, view)
+		},
+	}
+}
+
+func newVPCEmptySubnetsCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "empty-subnets",
+		Short: "List subnets without any network interfaces",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			subnets, err := models.Collect(invs, (*models.Inventory).Subnets)
+			if err = o.warnScopeErrors(c, len(subnets), err); err != nil {
+				return err
+			}
+
+			view := views.NewEmptySubnets(subnets)
+			return o.render(c, view)
+		},
+	}
+}
+
+func newVPCUnattachedCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unattached",
+		Short: "List network interfaces which aren't attached and Elastic IPs which aren't associated",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ifcs, err := models.Collect(invs, (*models.Inventory).NetworkInterfaces)
+			if err = o.warnScopeErrors(c, len(ifcs), err); err != nil {
+				return err
+			}
+
+			addresses, err := models.Collect(invs, (*models.Inventory).Addresses)
+			if err = o.warnScopeErrors(c, len(addresses), err); err != nil {
+				return err
+			}
+
+			view := views.NewUnattachedNetwork(ifcs, addresses)
+			return o.render(c, view)
+		},
+	}
+}
+
+func newSGCommand(o *Options) *cobra.Command {
+	return newGroupCommand("sg", "Audit and back up security groups",
+		newSGAuditCommand(o), newSGBackupCommand(o))
+}
+
+func newSGAuditCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "audit",
+		Short: "Show how many network interfaces use each security group",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			sgs, err := models.Collect(invs, (*models.Inventory).SecurityGroups)
+			if err = o.warnScopeErrors(c, len(sgs), err); err != nil {
+				return err
+			}
+
+			ifcs, err := models.Collect(invs, (*models.Inventory).NetworkInterfaces)
+			if err = o.warnScopeErrors(c, len(ifcs), err); err != nil {
+				return err
+			}
+
+			// Security groups would look unused in scopes where the network
+			// interfaces couldn't be listed.
+			if err = dropIncompleteScopes(sgs, scopesOf(ifcs)); err != nil {
+				return err
+			}
+
+			v := views.NewSecurityGroupAudit(sgs, ifcs)
+			return o.render(c, v)
+		},
+	}
+}
+
+func newSGBackupCommand(o *Options) *cobra.Command {
+	var dir string
+
+	c := &cobra.Command{
+		Use:   "backup",
+		Short: "Save each security group as JSON under <dir>/aws-sg-backup/<vpc-id>",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			sgs, err := models.Collect(invs, (*models.Inventory).SecurityGroups)
+			if err = o.warnScopeErrors(c, len(sgs), err); err != nil {
+				return err
+			}
+
+			for _, scope := range models.SortedScopes(sgs) {
+				for _, sg := range sgs[scope] {
+					if err := backupSecurityGroup(dir, sg); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+	c.Flags().StringVar(&dir, "dir", ".", "The directory to save the backups in.")
+	return c
+}
+
+func sgDirectoryPath(basePath string, sg *ec2.SecurityGroup) string {
+	var vpcID string
+	if sg.VpcId != nil {
+		vpcID = aws.StringValue(sg.VpcId)
+	}
+	if vpcID == "" {
+		vpcID = "vpc-default"
+	}
+
+	return filepath.Join(basePath, "aws-sg-backup", vpcID)
+}
+
+func backupSecurityGroup(basePath string, sg *ec2.SecurityGroup) error {
+	sgBasePath := sgDirectoryPath(basePath, sg)
+	if err := os.MkdirAll(sgBasePath, 0755); err != nil {
+		return err
+	}
+
+	name := aws.StringValue(sg.GroupName)
+	if name == "" {
+		name = aws.StringValue(sg.GroupId)
+	}
+	name = strings.ReplaceAll(name, "/", "-slash-")
+
+	objBytes, err := json.Marshal(sg)
+	if err != nil {
+		return err
+	}
+
+	fullPath := fmt.Sprintf("%s.json", filepath.Join(sgBasePath, name))
+	return os.WriteFile(fullPath, objBytes, 0644)
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/utils"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func runningDBInstances(opts models.RunningDBInstancesOpts) func(*models.Inventory) ([]*rds.DBInstance, error) {
+	return func(inv *models.Inventory) ([]*rds.DBInstance, error) {
+		return inv.RunningDBInstances(opts)
+	}
+}
+
+func newRDSRICommand(o *Options) *cobra.Command {
+	var statuses []string
+
+	c := &cobra.Command{
+		Use:   "rds-ri",
+		Short: "Audit reserved RDS instances against running DB instances",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ris, err := models.Collect(invs, (*models.Inventory).ReservedDBInstances)
+			if err = o.warnScopeErrors(c, len(ris), err); err != nil {
+				return err
+			}
+
+			dbs, err := models.Collect(invs, runningDBInstances(models.RunningDBInstancesOpts{Statuses: statuses}))
+			if err = o.warnScopeErrors(c, len(dbs), err); err != nil {
+				return err
+			}
+
+			// Reservations would be reported as unused, or instances as
+			// uncovered, in scopes where either failed.
+			complete := []map[models.Scope]bool{scopesOf(ris), scopesOf(dbs)}
+			if err = dropIncompleteScopes(ris, complete...); err != nil {
+				return err
+			}
+			if err = dropIncompleteScopes(dbs, complete...); err != nil {
+				return err
+			}
+
+			v := views.NewRDSReservationUtilization(dbs, ris, views.RDSReservationUtilizationOptions{
+				NormalizedUnits: o.normalizedUnits,
+			})
+			return o.render(c, v)
+		},
+	}
+	c.Flags().StringSliceVar(&statuses, "billable-statuses", models.DefaultBillableDBInstanceStatuses,
+		"Comma separated list of the DB instance statuses which count against reservations.")
+	return c
+}
+
+func newRDSSnapshotsCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rds-snapshots",
+		Short: "Find RDS snapshots whose DB instance no longer exists",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			dbs, err := models.Collect(invs, runningDBInstances(models.RunningDBInstancesOpts{}))
+			if err = o.warnScopeErrors(c, len(dbs), err); err != nil {
+				return err
+			}
+
+			snapshots, err := models.Collect(invs, (*models.Inventory).DBSnapshots)
+			if err = o.warnScopeErrors(c, len(snapshots), err); err != nil {
+				return err
+			}
+
+			// Snapshots would look orphaned in scopes where the DB instances
+			// couldn't be listed.
+			if err = dropIncompleteScopes(snapshots, scopesOf(dbs)); err != nil {
+				return err
+			}
+
+			view := views.NewRDSSnapshotAudit(snapshots, dbs)
+			return o.render(c, view)
+		},
+	}
+}
+
+func newRDSLogsCommand(o *Options) *cobra.Command {
+	return newGroupCommand("rds-logs", "Download RDS log files",
+		newRDSLogsURLCommand(o), newRDSLogsSyncCommand(o))
+}
+
+func newRDSLogsURLCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "url <dbInstanceIdentifier> <logName>",
+		Short:   "Print a presigned URL for downloading a complete RDS log file",
+		Example: "  curl -o postgresql.log.2018-04-05-15 $(aws-audit rds-logs url fa16fmqt5yah7r8 error/postgresql.log.2018-04-05-15)",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(c *cobra.Command, args []string) error {
+			inv, err := o.Inventory()
+			if err != nil {
+				return err
+			}
+
+			req, err := inv.GetRDSLogDownloadURL(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(c.OutOrStdout(), req.URL)
+			return nil
+		},
+	}
+}
+
+func newRDSLogsSyncCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "sync <dbInstanceIdentifier> <directory>",
+		Short:   "Sync all of the log files of a DB instance to a local directory",
+		Example: "  aws-audit rds-logs sync some-identifier /my/log/directory",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(c *cobra.Command, args []string) error {
+			dbIdentifier := args[0]
+			directory := args[1]
+
+			inv, err := o.Inventory()
+			if err != nil {
+				return err
+			}
+
+			logFiles, err := inv.DescribeDBLogFiles(dbIdentifier)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Found %d log files for DBInstanceIdentifier=%s", len(logFiles), dbIdentifier)
+
+			if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+				return err
+			}
+
+			for _, logFile := range logFiles {
+				fileName := aws.StringValue(logFile.LogFileName)
+				remoteSize := aws.Int64Value(logFile.Size)
+				localFilePath := filepath.Join(directory, filepath.Base(fileName))
+
+				stat, err := os.Stat(localFilePath)
+				if err == nil {
+					// File Exists
+					localSize := stat.Size()
+					logrus.Infof("%s (Local Size: %d, Remote Size: %d)", fileName, localSize, remoteSize)
+					// Now check if remote size is bigger
+					if remoteSize <= localSize {
+						logrus.Infof("Not downloading %s as filesize matches remote", fileName)
+						continue
+					}
+				}
+
+				logrus.Infof("Downloading %s to '%s'", fileName, localFilePath)
+				req, err := inv.GetRDSLogDownloadURL(dbIdentifier, fileName)
+				if err != nil {
+					return err
+				}
+				if err := utils.DownloadFile(localFilePath, req.URL.String()); err != nil {
+					return err
+				}
+				logrus.Infof("Successfully downloaded %s", fileName)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newRIRecommendCommand(o *Options) *cobra.Command {
+	var (
+		term          string
+		offeringClass string
+		pricesFile    string
+		minAge        time.Duration
+	)
+
+	c := &cobra.Command{
+		Use:   "ri-recommend",
+		Short: "Recommend EC2 and RDS reservations to buy for instances which have been running without one",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := oneOf("term", term, pricing.Terms); err != nil {
+				return err
+			}
+			if err := oneOf("offering class", offeringClass, pricing.OfferingClasses); err != nil {
+				return err
+			}
+
+			var prices *pricing.Table
+			if pricesFile != "" {
+				var err error
+				if prices, err = pricing.Load(pricesFile); err != nil {
+					return err
+				}
+			}
+
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ris, err := models.Collect(invs, (*models.Inventory).ReservedInstances)
+			if err = o.warnScopeErrors(c, len(ris), err); err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: false}))
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			dbRIs, err := models.Collect(invs, (*models.Inventory).ReservedDBInstances)
+			if err = o.warnScopeErrors(c, len(dbRIs), err); err != nil {
+				return err
+			}
+
+			dbs, err := models.Collect(invs, runningDBInstances(models.RunningDBInstancesOpts{}))
+			if err = o.warnScopeErrors(c, len(dbs), err); err != nil {
+				return err
+			}
+
+			if err = dropIncompleteReservationScopes(ris, instances, dbRIs, dbs); err != nil {
+				return err
+			}
+
+			cutoff := time.Now().Add(-minAge)
+			sustainedInstances := createdBefore(instances, cutoff, func(i *ec2.Instance) *time.Time { return i.LaunchTime })
+			sustainedDBs := createdBefore(dbs, cutoff, func(db *rds.DBInstance) *time.Time { return db.InstanceCreateTime })
+
+			ec2Opts := views.ReservationUtilizationOptions{NormalizedUnits: o.normalizedUnits}
+			rdsOpts := views.RDSReservationUtilizationOptions{NormalizedUnits: o.normalizedUnits}
+			v := views.NewRIRecommendations(
+				views.NewReservationUtilization(instances, ris, ec2Opts),
+				views.NewReservationUtilization(sustainedInstances, ris, ec2Opts),
+				views.NewRDSReservationUtilization(dbs, dbRIs, rdsOpts),
+				views.NewRDSReservationUtilization(sustainedDBs, dbRIs, rdsOpts),
+				views.RIRecommendationOptions{
+					Term:            term,
+					OfferingClass:   offeringClass,
+					Prices:          prices,
+					NormalizedUnits: o.normalizedUnits,
+				},
+			)
+			return o.render(c, v)
+		},
+	}
+	c.Flags().StringVar(&term, "term", pricing.OneYear,
+		fmt.Sprintf("The term of the reservations: %s.", strings.Join(pricing.Terms, ", ")))
+	c.Flags().StringVar(&offeringClass, "offering-class", pricing.Standard,
+		fmt.Sprintf("The offering class of EC2 reservations: %s.", strings.Join(pricing.OfferingClasses, ", ")))
+	c.Flags().StringVar(&pricesFile, "prices", "",
+		"A YAML or JSON price table, used to estimate the annual savings and to only recommend sizes with a price.")
+	c.Flags().DurationVar(&minAge, "min-age", 30*24*time.Hour,
+		"Only recommend reservations for instances which have been running for at least this long.")
+	return c
+}
+
+// oneOf returns an error if value isn't one of the allowed values.
+func oneOf(name, value string, allowed []string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q, must be one of: %s", name, value, strings.Join(allowed, ", "))
+}
+
+// createdBefore returns the resources which were created before the cutoff.
+func createdBefore[T any](resources map[models.Scope][]T, cutoff time.Time, created func(T) *time.Time) map[models.Scope][]T {
+	filtered := make(map[models.Scope][]T)
+	for scope, rs := range resources {
+		for _, r := range rs {
+			if t := created(r); t != nil && aws.TimeValue(t).Before(cutoff) {
+				filtered[scope] = append(filtered[scope], r)
+			}
+		}
+	}
+	return filtered
+}
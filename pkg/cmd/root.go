@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Options are the global flags shared by all of the commands.
+type Options struct {
+	Profile         string
+	Region          string
+	Regions         string
+	Accounts        string
+	RoleName        string
+	FromSnapshot    string
+	Output          string
+	FailOn          string
+	Concurrency     int
+	LogLevel        string
+	NormalizedUnits string
+
+	formatter       views.Formatter
+	failOn          policy.Severity
+	pool            *models.Pool
+	normalizedUnits *views.NormalizedUnits
+
+	// NewInventories creates the inventories the commands query. It defaults
+	// to fanning out across the accounts and regions selected by the flags and
+	// can be replaced in tests.
+	NewInventories func(o *Options) ([]*models.Inventory, error)
+}
+
+// Session creates the session selected by --profile and --region.
+func (o *Options) Session() (*session.Session, error) {
+	return models.NewSession(o.Profile, o.Region)
+}
+
+// Inventories returns an inventory for each account and region selected by
+// the flags.
+func (o *Options) Inventories() ([]*models.Inventory, error) {
+	if o.NewInventories != nil {
+		return o.NewInventories(o)
+	}
+	if o.FromSnapshot != "" {
+		return o.snapshotInventories()
+	}
+
+	sess, err := o.Session()
+	if err != nil {
+		return nil, err
+	}
+	regions, err := models.Regions(sess, o.Regions)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := strings.TrimSpace(o.Accounts)
+	if spec == "" {
+		return models.InitRegions(sess, regions), nil
+	}
+
+	var accounts []string
+	if spec == models.OrganizationAccounts {
+		accounts, err = models.ListOrganizationAccounts(sess)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, a := range strings.Split(spec, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				accounts = append(accounts, a)
+			}
+		}
+	}
+	return models.InitAccounts(sess, accounts, o.RoleName, regions), nil
+}
+
+// snapshotInventories returns the inventories of the snapshot selected by
+// --from-snapshot. Unlike when querying AWS, every scope in the snapshot is
+// used unless --regions or --accounts narrow it down.
+func (o *Options) snapshotInventories() ([]*models.Inventory, error) {
+	snap, err := models.LoadSnapshot(o.FromSnapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := splitList(o.Regions, models.AllRegions)
+	accounts := splitList(o.Accounts, models.OrganizationAccounts)
+
+	invs := make([]*models.Inventory, 0)
+	for _, inv := range snap.Inventories() {
+		if regions != nil && !regions[inv.Region] {
+			continue
+		}
+		if accounts != nil && !accounts[inv.Account] {
+			continue
+		}
+		invs = append(invs, inv)
+	}
+	if len(invs) == 0 {
+		return nil, fmt.Errorf("snapshot %s has no inventory for the selected regions and accounts", o.FromSnapshot)
+	}
+	return invs, nil
+}
+
+// splitList returns the set of items in a comma separated list, or nil if the
+// list is empty or is the given wildcard.
+func splitList(spec, wildcard string) map[string]bool {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == wildcard {
+		return nil
+	}
+	items := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items[item] = true
+		}
+	}
+	return items
+}
+
+// Inventory returns a single inventory for commands which operate on one
+// region, ignoring --regions and --accounts. When reading from a snapshot it
+// is the first inventory selected by them instead.
+func (o *Options) Inventory() (*models.Inventory, error) {
+	if o.NewInventories != nil || o.FromSnapshot != "" {
+		invs, err := o.Inventories()
+		if err != nil {
+			return nil, err
+		}
+		if len(invs) == 0 {
+			return nil, errors.New("no inventory available")
+		}
+		return invs[0], nil
+	}
+
+	sess, err := o.Session()
+	if err != nil {
+		return nil, err
+	}
+	return models.Init(sess), nil
+}
+
+// render writes the view to the command's output in the format selected by
+// --output. If --fail-on is set it then returns a *policy.Violation if the
+// view has findings at or above that severity.
+func (o *Options) render(c *cobra.Command, v views.View) error {
+	if err := o.formatter.Format(c.OutOrStdout(), v); err != nil {
+		return err
+	}
+	if o.failOn == 0 {
+		return nil
+	}
+
+	auditor, ok := v.(policy.Auditor)
+	if !ok {
+		return fmt.Errorf("%s doesn't report findings, so --fail-on can't be used with it", c.CommandPath())
+	}
+	return policy.Evaluate(auditor.Findings(), o.failOn)
+}
+
+// NewRootCommand creates the aws-audit command with all of its subcommands.
+func NewRootCommand(o *Options) *cobra.Command {
+	root := &cobra.Command{
+		Use:   "aws-audit",
+		Short: "Audit AWS resources across accounts and regions",
+		Long: `aws-audit queries your AWS accounts and reports on reservations, networking,
+snapshots, access keys and more.
+
+By default only the account and region from your shared AWS config are
+queried. Use --regions and --accounts to fan out across several.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			level, err := logrus.ParseLevel(o.LogLevel)
+			if err != nil {
+				return err
+			}
+			logrus.SetLevel(level)
+			logrus.SetOutput(cmd.ErrOrStderr())
+			o.pool = models.NewPool(o.Concurrency)
+
+			o.formatter, err = views.NewFormatter(o.Output)
+			if err != nil {
+				return err
+			}
+
+			if o.NormalizedUnits != "" {
+				if o.normalizedUnits, err = views.LoadNormalizedUnits(o.NormalizedUnits); err != nil {
+					return err
+				}
+			}
+
+			if o.FailOn != "" {
+				o.failOn, err = policy.ParseSeverity(o.FailOn)
+			}
+			return err
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&o.Profile, "profile", "", "The shared config profile to use.")
+	flags.StringVar(&o.Region, "region", "", "The region to use. Defaults to the region of the profile.")
+	flags.StringVar(&o.Regions, "regions", "",
+		`Comma separated list of regions to query, or "all" for every enabled region. Defaults to --region.`)
+	flags.StringVar(&o.Accounts, "accounts", "",
+		`Comma separated list of account IDs to query by assuming --role-name in each, or "organization" for every active account in the organization.`)
+	flags.StringVar(&o.RoleName, "role-name", models.DefaultRoleName, "The role to assume in each of the --accounts.")
+	flags.StringVar(&o.FromSnapshot, "from-snapshot", "",
+		"Read resources from a file written by the snapshot command instead of querying AWS.")
+	flags.StringVarP(&o.Output, "output", "o", "table",
+		fmt.Sprintf("The output format: %s.", strings.Join(views.Formats, ", ")))
+	flags.StringVar(&o.FailOn, "fail-on", "",
+		fmt.Sprintf("Exit with status %d if the audit has findings of this severity or higher: %s.",
+			ExitViolation, strings.Join(policy.Severities, ", ")))
+	flags.IntVar(&o.Concurrency, "concurrency", models.DefaultConcurrency,
+		"The maximum number of per-resource API calls, e.g. for each bucket or access key, to make at once.")
+	flags.StringVar(&o.LogLevel, "log-level", "info", "The log level: panic, fatal, error, warn, info, debug or trace.")
+	flags.StringVar(&o.NormalizedUnits, "normalized-units", "",
+		"A YAML or JSON file of normalized units for instance sizes which are missing or wrong in the built in table.")
+
+	_ = root.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return views.Formats, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = root.RegisterFlagCompletionFunc("fail-on", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return policy.Severities, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newRICommand(o),
+		newInstancesCommand(o),
+		newSpotIPCommand(o),
+		newVPCCommand(o),
+		newSGCommand(o),
+		newEBSCommand(o),
+		newAMIsCommand(o),
+		newRDSRICommand(o),
+		newRIExpiryCommand(o),
+		newRIRecommendCommand(o),
+		newSavingsPlansCommand(o),
+		newTagsCommand(o),
+		newRDSSnapshotsCommand(o),
+		newRDSLogsCommand(o),
+		newS3Command(o),
+		newIAMCommand(o),
+		newSnapshotCommand(o),
+		newDriftCommand(o),
+	)
+	return root
+}
+
+// newGroupCommand creates a command which only groups its subcommands.
+// Running it without a subcommand, or with one which doesn't exist, is an
+// error rather than printing the help, so typos don't pass silently.
+func newGroupCommand(use, short string, subcommands ...*cobra.Command) *cobra.Command {
+	c := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			return fmt.Errorf("%s requires a subcommand, see %s --help", c.CommandPath(), c.CommandPath())
+		},
+	}
+	c.AddCommand(subcommands...)
+	return c
+}
+
+// Execute runs the aws-audit command with the given arguments and exits with
+// a non-zero status if it fails.
+func Execute(args []string) {
+	root := NewRootCommand(&Options{})
+	root.SetArgs(args)
+	HandleError(root.Execute())
+}
+
+// Main runs the given aws-audit subcommand with the process arguments. It lets
+// the original single purpose binaries remain as thin shims.
+func Main(subcommand ...string) {
+	Execute(append(subcommand, os.Args[1:]...))
+}
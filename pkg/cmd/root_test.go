@@ -0,0 +1,899 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEC2 struct {
+	ec2iface.EC2API
+	subnets      []*ec2.Subnet
+	spotRequests []*ec2.SpotInstanceRequest
+	instances    []*ec2.Instance
+	subnetsErr   error
+}
+
+func (f *fakeEC2) DescribeSubnetsPages(input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool) error {
+	if f.subnetsErr != nil {
+		return f.subnetsErr
+	}
+	fn(&ec2.DescribeSubnetsOutput{Subnets: f.subnets}, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeVpcsPages(input *ec2.DescribeVpcsInput, fn func(*ec2.DescribeVpcsOutput, bool) bool) error {
+	fn(&ec2.DescribeVpcsOutput{}, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeSpotInstanceRequestsPages(input *ec2.DescribeSpotInstanceRequestsInput, fn func(*ec2.DescribeSpotInstanceRequestsOutput, bool) bool) error {
+	fn(&ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: f.spotRequests}, true)
+	return nil
+}
+
+func (f *fakeEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	fn(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: f.instances}},
+	}, true)
+	return nil
+}
+
+// run executes the root command against inventories using the fake clients
+// and returns what was written to stdout.
+func run(t *testing.T, clients map[models.Scope]*fakeEC2, args ...string) (string, error) {
+	t.Helper()
+
+	o := &Options{
+		NewInventories: func(o *Options) ([]*models.Inventory, error) {
+			invs := make([]*models.Inventory, 0)
+			for _, scope := range models.SortedScopes(clients) {
+				inv := models.NewInventory(clients[scope], nil, nil, nil)
+				inv.Scope = scope
+				invs = append(invs, inv)
+			}
+			return invs, nil
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	root := NewRootCommand(o)
+	root.SetArgs(args)
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	err := root.Execute()
+	return stdout.String(), err
+}
+
+func TestEmptySubnetsCommand(t *testing.T) {
+	out, err := run(t, map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: {subnets: []*ec2.Subnet{{
+			SubnetId:                aws.String("subnet-empty"),
+			CidrBlock:               aws.String("10.0.0.0/28"),
+			AvailableIpAddressCount: aws.Int64(11),
+		}}},
+		{Region: "us-west-2"}: {subnets: []*ec2.Subnet{{
+			SubnetId:                aws.String("subnet-used"),
+			CidrBlock:               aws.String("10.0.0.0/28"),
+			AvailableIpAddressCount: aws.Int64(3),
+		}}},
+	}, "vpc", "empty-subnets")
+
+	assert.Nil(t, err)
+	assert.Contains(t, out, "subnet-empty")
+	assert.Contains(t, out, "us-east-1")
+	assert.NotContains(t, out, "subnet-used")
+}
+
+func TestScopeErrors(t *testing.T) {
+	failing := &fakeEC2{subnetsErr: errors.New("UnauthorizedOperation")}
+
+	// There's nothing to show if every scope failed.
+	_, err := run(t, map[models.Scope]*fakeEC2{{Region: "us-east-1"}: failing}, "vpc", "empty-subnets")
+	assert.ErrorContains(t, err, "us-east-1: DescribeSubnets: UnauthorizedOperation")
+
+	// Otherwise the failures are only warned about.
+	out, err := run(t, map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: failing,
+		{Region: "us-west-2"}: {subnets: []*ec2.Subnet{{
+			SubnetId:                aws.String("subnet-empty"),
+			CidrBlock:               aws.String("10.0.0.0/28"),
+			AvailableIpAddressCount: aws.Int64(11),
+		}}},
+	}, "vpc", "empty-subnets")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "subnet-empty")
+}
+
+func TestFailOnScopeErrors(t *testing.T) {
+	// A scope which failed has no findings, so it mustn't pass --fail-on.
+	_, err := run(t, map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: {subnetsErr: errors.New("UnauthorizedOperation")},
+		{Region: "us-west-2"}: {},
+	}, "vpc", "empty-subnets", "--fail-on", "low")
+	assert.ErrorContains(t, err, "us-east-1: DescribeSubnets: UnauthorizedOperation")
+	var violation *policy.Violation
+	assert.False(t, errors.As(err, &violation))
+}
+
+func TestSpotIPCommand(t *testing.T) {
+	out, err := run(t, map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: {
+			spotRequests: []*ec2.SpotInstanceRequest{{InstanceId: aws.String("i-1")}},
+			instances:    []*ec2.Instance{{PrivateIpAddress: aws.String("10.1.132.15")}},
+		},
+	}, "spot-ip", "sir-kd4rbkim")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "10.1.132.15\n", out)
+
+	_, err = run(t, nil, "spot-ip")
+	assert.NotNil(t, err)
+}
+
+func TestGroupCommands(t *testing.T) {
+	_, err := run(t, nil, "instances", "without-tag", "--fail-on", "high")
+	assert.EqualError(t, err, `unknown command "without-tag" for "aws-audit instances"`)
+
+	_, err = run(t, nil, "vpc")
+	assert.EqualError(t, err, "aws-audit vpc requires a subcommand, see aws-audit vpc --help")
+}
+
+func TestUnsupportedOutput(t *testing.T) {
+	_, err := run(t, nil, "vpc", "empty-subnets", "--output", "xml")
+	assert.EqualError(t, err, `unsupported output format "xml", must be one of: table, json, csv, yaml, markdown`)
+}
+
+func TestJSONOutput(t *testing.T) {
+	out, err := run(t, map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: {subnets: []*ec2.Subnet{{
+			SubnetId:                aws.String("subnet-empty"),
+			CidrBlock:               aws.String("10.0.0.0/28"),
+			AvailableIpAddressCount: aws.Int64(11),
+		}}},
+	}, "vpc", "empty-subnets", "-o", "json")
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{
+		"account": "",
+		"region": "us-east-1",
+		"subnet_id": "subnet-empty",
+		"name": "",
+		"cidr": "10.0.0.0/28",
+		"available_ips": 11,
+		"subnet_size": 16,
+		"state": "",
+		"vpc_id": ""
+	}]`, out)
+}
+
+func TestFromSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json.gz")
+	snap := &models.Snapshot{Version: models.SnapshotVersion}
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		snap.Regional = append(snap.Regional, &models.RegionalSnapshot{
+			Region: region,
+			Subnets: []*ec2.Subnet{{
+				SubnetId:                aws.String("subnet-" + region),
+				CidrBlock:               aws.String("10.0.0.0/28"),
+				AvailableIpAddressCount: aws.Int64(11),
+			}},
+		})
+	}
+	assert.Nil(t, snap.Write(path))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"vpc", "empty-subnets", "--from-snapshot", path, "--regions", "us-west-2"})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	assert.Contains(t, stdout.String(), "subnet-us-west-2")
+	assert.NotContains(t, stdout.String(), "subnet-us-east-1")
+
+	root = NewRootCommand(&Options{})
+	root.SetArgs([]string{"vpc", "empty-subnets", "--from-snapshot", path, "--regions", "eu-west-1"})
+	assert.NotNil(t, root.Execute())
+}
+
+func TestFailOn(t *testing.T) {
+	clients := map[models.Scope]*fakeEC2{
+		{Region: "us-east-1"}: {subnets: []*ec2.Subnet{{
+			SubnetId:                aws.String("subnet-empty"),
+			CidrBlock:               aws.String("10.0.0.0/28"),
+			AvailableIpAddressCount: aws.Int64(11),
+		}}},
+	}
+
+	// Empty subnets are low severity findings.
+	_, err := run(t, clients, "vpc", "empty-subnets", "--fail-on", "medium")
+	assert.Nil(t, err)
+
+	out, err := run(t, clients, "vpc", "empty-subnets", "--fail-on", "low")
+	assert.Contains(t, out, "subnet-empty")
+	var violation *policy.Violation
+	assert.ErrorAs(t, err, &violation)
+	assert.Len(t, violation.Findings, 1)
+
+	_, err = run(t, clients, "vpc", "free-ranges", "--fail-on", "low")
+	assert.EqualError(t, err, "aws-audit vpc free-ranges doesn't report findings, so --fail-on can't be used with it")
+
+	_, err = run(t, clients, "vpc", "empty-subnets", "--fail-on", "urgent")
+	assert.NotNil(t, err)
+}
+
+func TestRIRecommend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	launched := aws.Time(time.Now().Add(-60 * 24 * time.Hour))
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	snap := &models.Snapshot{Version: models.SnapshotVersion, Regional: []*models.RegionalSnapshot{{
+		Region: "us-east-1",
+		Instances: []*ec2.Instance{
+			{InstanceId: aws.String("i-1"), InstanceType: aws.String("m5.large"), State: running, LaunchTime: launched},
+			{InstanceId: aws.String("i-2"), InstanceType: aws.String("m5.large"), State: running, LaunchTime: aws.Time(time.Now())},
+		},
+	}}}
+	assert.Nil(t, snap.Write(path))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"ri-recommend", "--from-snapshot", path, "-o", "csv"})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	// Only the instance which has been running for longer than --min-age is
+	// recommended for.
+	assert.Contains(t, stdout.String(), ",us-east-1,ec2,m5.large,Linux/UNIX,,1,1yr,standard,\n")
+
+	root = NewRootCommand(&Options{})
+	root.SetArgs([]string{"ri-recommend", "--from-snapshot", path, "--term", "2yr"})
+	assert.EqualError(t, root.Execute(), `unknown term "2yr", must be one of: 1yr, 3yr`)
+}
+
+func TestSavingsPlans(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inventory.json")
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region:    "us-east-1",
+			Instances: []*ec2.Instance{{InstanceId: aws.String("i-1"), InstanceType: aws.String("m5.large"), State: running}},
+		}},
+		Global: []*models.GlobalSnapshot{{
+			SavingsPlans: []*savingsplans.SavingsPlan{{
+				SavingsPlanType: aws.String(savingsplans.SavingsPlanTypeCompute),
+				Commitment:      aws.String("0.05"),
+			}},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+	prices := filepath.Join(dir, "prices.yaml")
+	assert.Nil(t, os.WriteFile(prices, []byte(`
+prices:
+  - {service: ec2, region: us-east-1, instance_type: m5.large, on_demand: 0.1}
+`), 0o644))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"savings-plans", "--from-snapshot", path, "--prices", prices, "-o", "csv"})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	assert.Contains(t, stdout.String(), ",0.10,0.00,0.05,0.05,0.05,0.05,0\n")
+
+	root = NewRootCommand(&Options{})
+	root.SetArgs([]string{"savings-plans", "--from-snapshot", path})
+	assert.ErrorContains(t, root.Execute(), "--prices is required")
+}
+
+func TestSavingsPlansIncompleteAccount(t *testing.T) {
+	dir := t.TempDir()
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	snap := &models.Snapshot{Version: models.SnapshotVersion}
+	for _, account := range []string{"111111111111", "222222222222"} {
+		for _, region := range []string{"us-east-1", "us-west-2"} {
+			snap.Regional = append(snap.Regional, &models.RegionalSnapshot{
+				Account: account,
+				Region:  region,
+				Instances: []*ec2.Instance{{
+					InstanceId:   aws.String("i-" + account + "-" + region),
+					InstanceType: aws.String("m5.large"),
+					State:        running,
+				}},
+			})
+		}
+		snap.Global = append(snap.Global, &models.GlobalSnapshot{Account: account})
+	}
+	prices := filepath.Join(dir, "prices.yaml")
+	assert.Nil(t, os.WriteFile(prices, []byte(`
+prices:
+  - {service: ec2, region: us-east-1, instance_type: m5.large, on_demand: 0.1}
+  - {service: ec2, region: us-west-2, instance_type: m5.large, on_demand: 0.1}
+`), 0o644))
+	o := &Options{NewInventories: func(o *Options) ([]*models.Inventory, error) {
+		invs := snap.Inventories()
+		// The reservations of one of the first account's regions can't be
+		// listed.
+		invs[1].EC2 = &failingEC2{EC2API: invs[1].EC2, op: "DescribeReservedInstances"}
+		return invs, nil
+	}}
+
+	var stdout, stderr bytes.Buffer
+	root := NewRootCommand(o)
+	root.SetArgs([]string{"savings-plans", "--prices", prices, "-o", "csv"})
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	assert.Nil(t, root.Execute())
+	assert.NotContains(t, stdout.String(), "111111111111")
+	assert.Contains(t, stdout.String(), "222222222222,0.20,")
+	assert.Contains(t, stderr.String(), "WARNING: 111111111111/us-west-2: DescribeReservedInstances: Throttling")
+
+	snap.Regional = snap.Regional[:2]
+	root = NewRootCommand(o)
+	root.SetArgs([]string{"savings-plans", "--prices", prices})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	assert.ErrorContains(t, root.Execute(), "no account has complete results")
+}
+
+// failingEC2 fails the operation op with a throttling error and answers
+// every other call with the wrapped client.
+type failingEC2 struct {
+	ec2iface.EC2API
+	op string
+}
+
+var errThrottling = errors.New("Throttling")
+
+func (f *failingEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	if f.op == "DescribeInstances" {
+		return errThrottling
+	}
+	return f.EC2API.DescribeInstancesPages(input, fn)
+}
+
+func (f *failingEC2) DescribeReservedInstances(input *ec2.DescribeReservedInstancesInput) (*ec2.DescribeReservedInstancesOutput, error) {
+	if f.op == "DescribeReservedInstances" {
+		return nil, errThrottling
+	}
+	return f.EC2API.DescribeReservedInstances(input)
+}
+
+func (f *failingEC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	if f.op == "DescribeVolumes" {
+		return errThrottling
+	}
+	return f.EC2API.DescribeVolumesPages(input, fn)
+}
+
+func (f *failingEC2) DescribeSubnetsPages(input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool) error {
+	if f.op == "DescribeSubnets" {
+		return errThrottling
+	}
+	return f.EC2API.DescribeSubnetsPages(input, fn)
+}
+
+func (f *failingEC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	if f.op == "DescribeNetworkInterfaces" {
+		return errThrottling
+	}
+	return f.EC2API.DescribeNetworkInterfacesPages(input, fn)
+}
+
+// failingRDS fails the operation op with a throttling error and answers
+// every other call with the wrapped client.
+type failingRDS struct {
+	rdsiface.RDSAPI
+	op string
+}
+
+func (f *failingRDS) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	if f.op == "DescribeDBInstances" {
+		return errThrottling
+	}
+	return f.RDSAPI.DescribeDBInstancesPages(input, fn)
+}
+
+func (f *failingRDS) DescribeReservedDBInstancesPages(input *rds.DescribeReservedDBInstancesInput, fn func(*rds.DescribeReservedDBInstancesOutput, bool) bool) error {
+	if f.op == "DescribeReservedDBInstances" {
+		return errThrottling
+	}
+	return f.RDSAPI.DescribeReservedDBInstancesPages(input, fn)
+}
+
+// regionSnapshot returns the resources of a region which every audit which
+// joins several kinds of resources reports on.
+func regionSnapshot(region string) *models.RegionalSnapshot {
+	old := aws.Time(time.Now().Add(-90 * 24 * time.Hour))
+	return &models.RegionalSnapshot{
+		Region: region,
+		Instances: []*ec2.Instance{
+			{
+				InstanceId:   aws.String("i-running-" + region),
+				InstanceType: aws.String("m5.large"),
+				SubnetId:     aws.String("subnet-" + region),
+				State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+				LaunchTime:   old,
+			},
+			{
+				InstanceId:            aws.String("i-stopped-" + region),
+				State:                 &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)},
+				StateTransitionReason: aws.String("User initiated (2020-01-01 00:00:00 GMT)"),
+			},
+		},
+		ReservedInstances: []*ec2.ReservedInstances{{
+			ReservedInstancesId: aws.String("ri-" + region),
+			InstanceType:        aws.String("m5.large"),
+			InstanceCount:       aws.Int64(1),
+			ProductDescription:  aws.String(ec2.RIProductDescriptionLinuxUnix),
+			State:               aws.String("active"),
+			End:                 aws.Time(time.Now().Add(30 * 24 * time.Hour)),
+		}},
+		VPCs: []*ec2.Vpc{{VpcId: aws.String("vpc-" + region), CidrBlock: aws.String("10.0.0.0/16")}},
+		Subnets: []*ec2.Subnet{{
+			SubnetId:  aws.String("subnet-" + region),
+			VpcId:     aws.String("vpc-" + region),
+			CidrBlock: aws.String("10.0.0.0/24"),
+		}},
+		SecurityGroups: []*ec2.SecurityGroup{{GroupId: aws.String("sg-" + region)}},
+		NetworkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String("eni-" + region),
+			Groups:             []*ec2.GroupIdentifier{{GroupId: aws.String("sg-" + region)}},
+		}},
+		Volumes: []*ec2.Volume{{
+			VolumeId:    aws.String("vol-" + region),
+			State:       aws.String("in-use"),
+			Size:        aws.Int64(10),
+			Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("i-running-" + region)}},
+		}},
+		DBInstances: []*rds.DBInstance{{
+			DBInstanceIdentifier: aws.String("db-" + region),
+			DBInstanceClass:      aws.String("db.m5.large"),
+			DBInstanceStatus:     aws.String("available"),
+			Engine:               aws.String("postgres"),
+			InstanceCreateTime:   old,
+		}},
+		ReservedDBInstances: []*rds.ReservedDBInstance{{
+			ReservedDBInstanceId: aws.String("rdsri-" + region),
+			DBInstanceClass:      aws.String("db.m5.large"),
+			DBInstanceCount:      aws.Int64(1),
+			ProductDescription:   aws.String("postgresql"),
+			State:                aws.String("active"),
+			StartTime:            old,
+			Duration:             aws.Int64(365 * 24 * 60 * 60),
+		}},
+		DBSnapshots: []*rds.DBSnapshot{{
+			DBSnapshotIdentifier: aws.String("dbsnap-" + region),
+			DBInstanceIdentifier: aws.String("db-" + region),
+		}},
+	}
+}
+
+// TestIncompleteScopes checks that the audits which join several kinds of
+// resources leave out the scopes where listing any of them failed, rather
+// than reporting on what they did list.
+func TestIncompleteScopes(t *testing.T) {
+	cases := []struct {
+		args  []string
+		ec2Op string
+		rdsOp string
+		// absent is left out of the output, every mention of the failing
+		// region if empty.
+		absent string
+	}{
+		{args: []string{"ri"}, ec2Op: "DescribeInstances"},
+		{args: []string{"ri"}, ec2Op: "DescribeReservedInstances"},
+		{args: []string{"rds-ri"}, rdsOp: "DescribeDBInstances"},
+		{args: []string{"rds-ri"}, rdsOp: "DescribeReservedDBInstances"},
+		{args: []string{"rds-snapshots"}, rdsOp: "DescribeDBInstances"},
+		{args: []string{"sg", "audit"}, ec2Op: "DescribeNetworkInterfaces"},
+		{args: []string{"ri-expiry"}, ec2Op: "DescribeInstances", absent: `"ri-us-east-1"`},
+		{args: []string{"ri-expiry"}, rdsOp: "DescribeDBInstances", absent: `"rdsri-us-east-1"`},
+		{args: []string{"ri-recommend"}, ec2Op: "DescribeReservedInstances"},
+		{args: []string{"ri-recommend"}, rdsOp: "DescribeReservedDBInstances"},
+		{args: []string{"vpc", "free-ranges"}, ec2Op: "DescribeSubnets"},
+		{args: []string{"instances", "by-subnet"}, ec2Op: "DescribeSubnets"},
+		{args: []string{"instances", "stopped"}, ec2Op: "DescribeVolumes"},
+		{args: []string{"ebs", "volumes"}, ec2Op: "DescribeInstances"},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " ")+"/"+c.ec2Op+c.rdsOp, func(t *testing.T) {
+			snap := &models.Snapshot{Version: models.SnapshotVersion, Regional: []*models.RegionalSnapshot{
+				regionSnapshot("us-east-1"),
+				regionSnapshot("us-west-2"),
+			}}
+			o := &Options{NewInventories: func(o *Options) ([]*models.Inventory, error) {
+				invs := snap.Inventories()
+				invs[0].EC2 = &failingEC2{EC2API: invs[0].EC2, op: c.ec2Op}
+				invs[0].RDS = &failingRDS{RDSAPI: invs[0].RDS, op: c.rdsOp}
+				return invs, nil
+			}}
+
+			var stdout, stderr bytes.Buffer
+			root := NewRootCommand(o)
+			root.SetArgs(append(c.args, "-o", "json"))
+			root.SetOut(&stdout)
+			root.SetErr(&stderr)
+			assert.Nil(t, root.Execute())
+			assert.Contains(t, stderr.String(), "WARNING: us-east-1: ")
+			absent := c.absent
+			if absent == "" {
+				absent = "us-east-1"
+			}
+			assert.NotContains(t, stdout.String(), absent)
+		})
+	}
+}
+
+func TestTagsAudit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inventory.json")
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			Volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-1"), Tags: []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("prod")}}},
+				{VolumeId: aws.String("vol-2")},
+			},
+		}},
+		Global: []*models.GlobalSnapshot{{
+			Buckets:    []*s3.Bucket{{Name: aws.String("logs")}},
+			BucketTags: map[string][]*s3.Tag{"logs": {{Key: aws.String("env"), Value: aws.String("qa")}}},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+	tags := filepath.Join(dir, "tags.yaml")
+	assert.Nil(t, os.WriteFile(tags, []byte(`
+rules:
+  - {key: env, values: [prod, dev], severity: high}
+`), 0o644))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"tags", "audit", "--from-snapshot", path, "--policy", tags, "-o", "csv", "--fail-on", "high"})
+	root.SetOut(&stdout)
+	var violation *policy.Violation
+	assert.ErrorAs(t, root.Execute(), &violation)
+	assert.Len(t, violation.Findings, 2)
+	assert.Contains(t, stdout.String(), ",us-east-1,volume,vol-2,,env,missing,,\"env in (prod, dev)\"")
+	assert.Contains(t, stdout.String(), ",,bucket,logs,,env,invalid,qa,\"env in (prod, dev)\"")
+
+	root = NewRootCommand(&Options{})
+	root.SetArgs([]string{"tags", "audit", "--from-snapshot", path})
+	assert.ErrorContains(t, root.Execute(), "--policy is required")
+}
+
+// taggingEC2 records the tags set through it and answers every other call
+// with the wrapped client.
+type taggingEC2 struct {
+	ec2iface.EC2API
+	tagged []string
+}
+
+func (f *taggingEC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	for _, id := range input.Resources {
+		for _, tag := range input.Tags {
+			f.tagged = append(f.tagged, aws.StringValue(id)+" "+aws.StringValue(tag.Key)+"="+aws.StringValue(tag.Value))
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func TestTagsAuditRemediate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inventory.json")
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			Subnets: []*ec2.Subnet{{
+				SubnetId: aws.String("subnet-1"),
+				Tags:     []*ec2.Tag{{Key: aws.String("cost"), Value: aws.String("platform")}},
+			}},
+			Instances: []*ec2.Instance{
+				{InstanceId: aws.String("i-1"), SubnetId: aws.String("subnet-1")},
+				{InstanceId: aws.String("i-2"), SubnetId: aws.String("subnet-1")},
+			},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+	tags := filepath.Join(dir, "tags.yaml")
+	assert.Nil(t, os.WriteFile(tags, []byte("rules: [{key: cost}]\n"), 0o644))
+	remediation := filepath.Join(dir, "remediation.yaml")
+	assert.Nil(t, os.WriteFile(remediation, []byte("tags: [{key: cost, inherit: [subnet]}]\n"), 0o644))
+	auditLog := filepath.Join(dir, "audit.log")
+
+	ec2Client := &taggingEC2{}
+	o := &Options{NewInventories: func(o *Options) ([]*models.Inventory, error) {
+		invs := snap.Inventories()
+		ec2Client.EC2API = invs[0].EC2
+		invs[0].EC2 = ec2Client
+		return invs, nil
+	}}
+	execute := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		root := NewRootCommand(o)
+		root.SetArgs(append([]string{"tags", "audit", "--policy", tags, "--audit-log", auditLog, "-o", "csv"}, args...))
+		root.SetOut(&stdout)
+		root.SetErr(&stderr)
+		err := root.Execute()
+		return stdout.String(), err
+	}
+
+	// Without --apply only the plan is printed.
+	out, err := execute("--remediate", remediation)
+	assert.Nil(t, err)
+	assert.Contains(t, out, ",us-east-1,instance,i-1,,cost,platform,subnet subnet-1\n")
+	assert.Empty(t, ec2Client.tagged)
+	assert.NoFileExists(t, auditLog)
+
+	_, err = execute("--remediate", remediation, "--apply")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1 cost=platform", "i-2 cost=platform"}, ec2Client.tagged)
+	logged, err := os.ReadFile(auditLog)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, bytes.Count(logged, []byte("\n")))
+	assert.Contains(t, string(logged), `"resource":"i-2","name":"","key":"cost","value":"platform","source":"subnet subnet-1"}`)
+
+	// Snapshots can't be tagged.
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"tags", "audit", "--from-snapshot", path, "--policy", tags,
+		"--remediate", remediation, "--apply", "--audit-log", auditLog})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	assert.EqualError(t, root.Execute(), "--apply can't be used with --from-snapshot")
+
+	// Instances would get defaults instead of the tags of their subnets in
+	// scopes where the subnets couldn't be listed.
+	o.NewInventories = func(o *Options) ([]*models.Inventory, error) {
+		snap := &models.Snapshot{Version: models.SnapshotVersion, Regional: append(snap.Regional,
+			&models.RegionalSnapshot{Region: "us-west-2"})}
+		invs := snap.Inventories()
+		ec2Client.EC2API = invs[0].EC2
+		invs[0].EC2 = &failingEC2{EC2API: ec2Client, op: "DescribeSubnets"}
+		return invs, nil
+	}
+	_, err = execute("--remediate", remediation)
+	assert.Nil(t, err)
+	_, err = execute("--remediate", remediation, "--apply")
+	assert.ErrorContains(t, err, "not setting any tags: us-east-1: DescribeSubnets: Throttling")
+	assert.Len(t, ec2Client.tagged, 2)
+	logged, err = os.ReadFile(auditLog)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, bytes.Count(logged, []byte("\n")))
+
+	_, err = execute("--apply")
+	assert.EqualError(t, err, "--apply requires --remediate")
+}
+
+func TestEBSVolumes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inventory.json")
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			Instances: []*ec2.Instance{{
+				InstanceId: aws.String("i-1"),
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)},
+			}},
+			Volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-1"), State: aws.String("available"), Size: aws.Int64(100), VolumeType: aws.String("gp3")},
+				{
+					VolumeId:    aws.String("vol-2"),
+					State:       aws.String("in-use"),
+					Size:        aws.Int64(20),
+					VolumeType:  aws.String("gp3"),
+					Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("i-1")}},
+				},
+			},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+	prices := filepath.Join(dir, "prices.yaml")
+	assert.Nil(t, os.WriteFile(prices, []byte(`
+storage:
+  - {region: us-east-1, type: gp3, per_gb_month: 0.08}
+`), 0o644))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"ebs", "volumes", "--from-snapshot", path, "--prices", prices})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	assert.Contains(t, stdout.String(), "2 Unused Volumes: 120 GB\nEst. Monthly Cost: 9.60\n")
+	assert.Contains(t, stdout.String(), "vol-2")
+
+	snap.Regional[0].EBSSnapshots = []*ec2.Snapshot{
+		{SnapshotId: aws.String("snap-1"), VolumeId: aws.String("vol-1"), VolumeSize: aws.Int64(100)},
+		{SnapshotId: aws.String("snap-2"), VolumeId: aws.String("vol-deleted"), VolumeSize: aws.Int64(50)},
+	}
+	assert.Nil(t, snap.Write(path))
+
+	stdout.Reset()
+	root = NewRootCommand(&Options{})
+	root.SetArgs([]string{"ebs", "snapshots", "--from-snapshot", path, "-o", "json"})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	assert.Contains(t, stdout.String(), `"total_virtual_snapshot_storage_gb": 150,`)
+	assert.Contains(t, stdout.String(), `"snapshot_id": "snap-2",`)
+	assert.NotContains(t, stdout.String(), "snap-1")
+}
+
+func TestEBSSnapshotsIncompleteScope(t *testing.T) {
+	snap := &models.Snapshot{Version: models.SnapshotVersion}
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		snap.Regional = append(snap.Regional, &models.RegionalSnapshot{
+			Region: region,
+			EBSSnapshots: []*ec2.Snapshot{{
+				SnapshotId: aws.String("snap-" + region),
+				VolumeId:   aws.String("vol-" + region),
+				VolumeSize: aws.Int64(10),
+			}},
+		})
+	}
+	o := &Options{NewInventories: func(o *Options) ([]*models.Inventory, error) {
+		invs := snap.Inventories()
+		invs[0].EC2 = &failingEC2{EC2API: invs[0].EC2, op: "DescribeVolumes"}
+		return invs, nil
+	}}
+
+	var stdout, stderr bytes.Buffer
+	root := NewRootCommand(o)
+	root.SetArgs([]string{"ebs", "snapshots"})
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	assert.Nil(t, root.Execute())
+	// The volumes of us-east-1 couldn't be listed, so its snapshot's volume
+	// might still exist.
+	assert.NotContains(t, stdout.String(), "snap-us-east-1")
+	assert.Contains(t, stdout.String(), "snap-us-west-2")
+	assert.Contains(t, stderr.String(), "WARNING: us-east-1: DescribeVolumes: Throttling")
+}
+
+func TestAMIsUnused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			Images: []*ec2.Image{
+				{ImageId: aws.String("ami-used")},
+				{ImageId: aws.String("ami-unused")},
+			},
+			LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{{
+				LaunchTemplateData: &ec2.ResponseLaunchTemplateData{ImageId: aws.String("ami-used")},
+			}},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"amis", "unused", "--from-snapshot", path, "--fail-on", "low"})
+	root.SetOut(&stdout)
+	var violation *policy.Violation
+	assert.ErrorAs(t, root.Execute(), &violation)
+	assert.Len(t, violation.Findings, 1)
+	assert.Equal(t, "ami-unused", violation.Findings[0].Resource)
+	assert.Contains(t, stdout.String(), "1 of 2 Images Aren't Used")
+}
+
+// failingAutoScaling fails to describe launch configurations and answers
+// every other call with the wrapped client.
+type failingAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+}
+
+func (f *failingAutoScaling) DescribeLaunchConfigurationsPages(*autoscaling.DescribeLaunchConfigurationsInput, func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	return errors.New("Throttling")
+}
+
+func TestAMIsUnusedIncompleteScope(t *testing.T) {
+	snap := &models.Snapshot{Version: models.SnapshotVersion}
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		snap.Regional = append(snap.Regional, &models.RegionalSnapshot{
+			Region: region,
+			Images: []*ec2.Image{{ImageId: aws.String("ami-" + region)}},
+		})
+	}
+	o := &Options{NewInventories: func(o *Options) ([]*models.Inventory, error) {
+		invs := snap.Inventories()
+		invs[0].AutoScaling = &failingAutoScaling{invs[0].AutoScaling}
+		return invs, nil
+	}}
+
+	var stdout, stderr bytes.Buffer
+	root := NewRootCommand(o)
+	root.SetArgs([]string{"amis", "unused"})
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	assert.Nil(t, root.Execute())
+	// The launch configurations of us-east-1 couldn't be listed, so its images
+	// might be in use.
+	assert.NotContains(t, stdout.String(), "ami-us-east-1")
+	assert.Contains(t, stdout.String(), "ami-us-west-2")
+	assert.Contains(t, stderr.String(), "WARNING: us-east-1: DescribeLaunchConfigurations: Throttling")
+
+	snap.Regional = snap.Regional[:1]
+	root = NewRootCommand(o)
+	root.SetArgs([]string{"amis", "unused"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	assert.NotNil(t, root.Execute())
+}
+
+func TestVPCUnattached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{NetworkInterfaceId: aws.String("eni-1"), Status: aws.String(ec2.NetworkInterfaceStatusInUse)},
+				{NetworkInterfaceId: aws.String("eni-2"), Status: aws.String(ec2.NetworkInterfaceStatusAvailable)},
+			},
+			Addresses: []*ec2.Address{
+				{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1")},
+			},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"vpc", "unattached", "--from-snapshot", path, "--fail-on", "medium"})
+	root.SetOut(&stdout)
+	var violation *policy.Violation
+	assert.ErrorAs(t, root.Execute(), &violation)
+	assert.Len(t, violation.Findings, 1)
+	assert.Equal(t, "eipalloc-1", violation.Findings[0].Resource)
+	assert.Contains(t, stdout.String(), "eni-2")
+	assert.NotContains(t, stdout.String(), "eni-1")
+}
+
+func TestInstancesStopped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	stopped := func(id, reason string) *ec2.Instance {
+		return &ec2.Instance{
+			InstanceId:            aws.String(id),
+			State:                 &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)},
+			StateTransitionReason: aws.String(reason),
+		}
+	}
+	snap := &models.Snapshot{
+		Version: models.SnapshotVersion,
+		Regional: []*models.RegionalSnapshot{{
+			Region: "us-east-1",
+			Instances: []*ec2.Instance{
+				stopped("i-old", "User initiated (2020-01-01 00:00:00 GMT)"),
+				stopped("i-recent", "User initiated ("+time.Now().UTC().Format("2006-01-02 15:04:05")+" GMT)"),
+			},
+		}},
+	}
+	assert.Nil(t, snap.Write(path))
+
+	var stdout bytes.Buffer
+	root := NewRootCommand(&Options{})
+	root.SetArgs([]string{"instances", "stopped", "--from-snapshot", path, "--stopped-for", "720h", "-o", "json"})
+	root.SetOut(&stdout)
+	assert.Nil(t, root.Execute())
+	assert.Contains(t, stdout.String(), `"instance_id": "i-old",`)
+	assert.NotContains(t, stdout.String(), "i-recent")
+}
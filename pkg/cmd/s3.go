@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newS3Command(o *Options) *cobra.Command {
+	return newGroupCommand("s3", "Audit S3 buckets",
+		newS3ReplicationCommand(o))
+}
+
+func newS3ReplicationCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "replication",
+		Short: "Show the cross region replication destinations of each bucket",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			// Buckets are listed globally, so we only need to query each account once.
+			results, err := models.Collect(models.PerAccount(invs), func(inv *models.Inventory) (bucketReplications, error) {
+				return auditReplication(o.pool, inv)
+			})
+			if err = o.warnScopeErrors(c, len(results), err); err != nil {
+				return err
+			}
+
+			buckets := make(map[models.Scope][]*s3.Bucket)
+			replications := make(map[models.Scope][]*s3.GetBucketReplicationOutput)
+			for scope, result := range results {
+				buckets[scope] = result.buckets
+				replications[scope] = result.replications
+			}
+
+			audit := views.NewS3ReplicationAudit(buckets, replications)
+			return o.render(c, audit)
+		},
+	}
+}
+
+// bucketReplications holds the buckets of an account along with their
+// replication configuration, in the same order.
+type bucketReplications struct {
+	buckets      []*s3.Bucket
+	replications []*s3.GetBucketReplicationOutput
+}
+
+func auditReplication(pool *models.Pool, inv *models.Inventory) (bucketReplications, error) {
+	buckets, err := inv.ListBuckets()
+	if err != nil {
+		return bucketReplications{}, err
+	}
+
+	replications, err := models.Map(pool, buckets, func(bucket *s3.Bucket) (*s3.GetBucketReplicationOutput, error) {
+		replication, err := inv.GetBucketReplication(bucket)
+		if err != nil {
+			// Buckets without replication return an error, so only
+			// throttling is treated as a failure, which the pool retries.
+			if models.IsThrottle(err) {
+				return nil, err
+			}
+			return nil, nil
+		}
+		return replication, nil
+	})
+	if err != nil {
+		return bucketReplications{}, err
+	}
+	return bucketReplications{buckets: buckets, replications: replications}, nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newSavingsPlansCommand(o *Options) *cobra.Command {
+	var pricesFile string
+
+	c := &cobra.Command{
+		Use:   "savings-plans",
+		Short: "Show how much on demand EC2 spend is covered by reservations and savings plans",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if pricesFile == "" {
+				return errors.New("--prices is required to estimate the on demand spend of the running instances")
+			}
+			prices, err := pricing.Load(pricesFile)
+			if err != nil {
+				return err
+			}
+
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			ris, err := models.Collect(invs, (*models.Inventory).ReservedInstances)
+			if err = o.warnScopeErrors(c, len(ris), err); err != nil {
+				return err
+			}
+
+			instances, err := models.Collect(invs, runningInstances(models.RunningInstancesOpts{IncludeSpot: false}))
+			if err = o.warnScopeErrors(c, len(instances), err); err != nil {
+				return err
+			}
+
+			// Savings plans apply to the whole account, so we only need to
+			// query each account once.
+			plans, err := models.Collect(models.PerAccount(invs), (*models.Inventory).ActiveSavingsPlans)
+			if err = o.warnScopeErrors(c, len(plans), err); err != nil {
+				return err
+			}
+
+			if err = dropIncompleteAccounts(invs, instances, ris, plans); err != nil {
+				return err
+			}
+
+			v := views.NewSavingsPlansCoverage(instances, ris, plans, prices, views.SavingsPlansCoverageOptions{
+				NormalizedUnits: o.normalizedUnits,
+			})
+			return o.render(c, v)
+		},
+	}
+	c.Flags().StringVar(&pricesFile, "prices", "",
+		"A YAML or JSON price table with the on demand and savings plan prices of the instance types.")
+	return c
+}
+
+// dropIncompleteAccounts deletes every scope of the accounts where listing the
+// instances, reservations or savings plans of any scope failed. Coverage is
+// worked out per account, so an instance whose reservation is missing would
+// otherwise use up the savings plans of the account's other regions. An error
+// is returned if no account is left.
+func dropIncompleteAccounts(
+	invs []*models.Inventory,
+	instances map[models.Scope][]*ec2.Instance,
+	ris map[models.Scope][]*ec2.ReservedInstances,
+	plans map[models.Scope][]*savingsplans.SavingsPlan,
+) error {
+	incomplete := make(map[string]bool)
+	for _, inv := range invs {
+		_, haveInstances := instances[inv.Scope]
+		_, haveRIs := ris[inv.Scope]
+		if !haveInstances || !haveRIs {
+			incomplete[inv.Account] = true
+		}
+	}
+	for _, inv := range models.PerAccount(invs) {
+		if _, ok := plans[inv.Scope]; !ok {
+			incomplete[inv.Account] = true
+		}
+	}
+	if len(incomplete) == 0 {
+		return nil
+	}
+
+	deleteAccounts(instances, incomplete)
+	deleteAccounts(ris, incomplete)
+	deleteAccounts(plans, incomplete)
+	if len(instances) == 0 {
+		return errors.New("no account has complete results, as listing the instances, reservations or savings plans of one of its regions failed")
+	}
+	return nil
+}
+
+func deleteAccounts[T any](results map[models.Scope]T, accounts map[string]bool) {
+	for scope := range results {
+		if accounts[scope.Account] {
+			delete(results, scope)
+		}
+	}
+}
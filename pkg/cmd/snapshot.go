@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshot <file>",
+		Short: "Save the resources of the selected accounts and regions to a file",
+		Long: `Save the raw describe results used by the audits to a versioned JSON file,
+gzipped if the name ends in .gz. Any audit can then be rerun on it with
+--from-snapshot, without credentials.`,
+		Example: "  aws-audit snapshot --regions all inventory-2024-01.json.gz\n" +
+			"  aws-audit ri --from-snapshot inventory-2024-01.json.gz",
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			snap, err := models.Capture(o.pool, invs)
+			if err = o.warnScopeErrors(c, len(snap.Regional)+len(snap.Global), err); err != nil {
+				return err
+			}
+
+			if err := snap.Write(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.ErrOrStderr(), "Saved %d scopes and %d accounts to %s\n",
+				len(snap.Regional), len(snap.Global), args[0])
+			return nil
+		},
+	}
+}
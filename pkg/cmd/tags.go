@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/views"
+	"github.com/spf13/cobra"
+)
+
+func newTagsCommand(o *Options) *cobra.Command {
+	return newGroupCommand("tags", "Audit the tags of resources",
+		newTagsAuditCommand(o))
+}
+
+func newTagsAuditCommand(o *Options) *cobra.Command {
+	var policyFile, remediationFile, auditLog string
+	var apply bool
+
+	c := &cobra.Command{
+		Use:   "audit",
+		Short: "Find EC2 instances, volumes, RDS instances, security groups and buckets which don't comply with a tag policy",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if policyFile == "" {
+				return errors.New("--policy is required")
+			}
+			if apply && remediationFile == "" {
+				return errors.New("--apply requires --remediate")
+			}
+			if apply && o.FromSnapshot != "" {
+				return errors.New("--apply can't be used with --from-snapshot")
+			}
+			p, err := policy.LoadTagPolicy(policyFile)
+			if err != nil {
+				return err
+			}
+			var remediation *policy.TagRemediation
+			if remediationFile != "" {
+				if remediation, err = policy.LoadTagRemediation(remediationFile); err != nil {
+					return err
+				}
+			}
+
+			invs, err := o.Inventories()
+			if err != nil {
+				return err
+			}
+
+			resources, err := collectTaggableResources(c, o, invs)
+			if err != nil {
+				return err
+			}
+
+			v := views.NewTagCompliance(resources, p)
+			if remediation == nil {
+				return o.render(c, v)
+			}
+
+			if err := collectTagSources(c, o, invs, &resources, apply); err != nil {
+				return err
+			}
+			plan := views.NewTagRemediationPlan(resources, v, remediation)
+			if err := o.render(c, plan); err != nil || !apply {
+				return err
+			}
+			return applyTagRemediation(c.ErrOrStderr(), invs, plan, auditLog)
+		},
+	}
+	c.Flags().StringVar(&policyFile, "policy", "",
+		"A YAML or JSON tag policy with the rules the tags of the resources have to comply with.")
+	c.Flags().StringVar(&remediationFile, "remediate", "",
+		"A YAML or JSON file mapping missing tags to the resources they are inherited from or a default. "+
+			"The tags which would be set are printed instead of the violations.")
+	c.Flags().BoolVar(&apply, "apply", false, "Set the tags printed by --remediate.")
+	c.Flags().StringVar(&auditLog, "audit-log", "tag-remediation.log",
+		"The file every tag set by --apply is appended to as a line of JSON.")
+	return c
+}
+
+// collectTaggableResources describes every kind of resource a tag policy can
+// be evaluated against.
+func collectTaggableResources(c *cobra.Command, o *Options, invs []*models.Inventory) (views.TaggableResources, error) {
+	var resources views.TaggableResources
+	var err error
+
+	resources.Instances, err = models.Collect(invs, func(inv *models.Inventory) ([]*ec2.Instance, error) {
+		return inv.Instances(nil)
+	})
+	if err = o.warnScopeErrors(c, len(resources.Instances), err); err != nil {
+		return resources, err
+	}
+
+	resources.Volumes, err = models.Collect(invs, (*models.Inventory).Volumes)
+	if err = o.warnScopeErrors(c, len(resources.Volumes), err); err != nil {
+		return resources, err
+	}
+
+	// Stopped DB instances have to be tagged too, even though they aren't
+	// billed.
+	statuses := append([]string{"starting", "stopped", "stopping"}, models.DefaultBillableDBInstanceStatuses...)
+	resources.DBInstances, err = models.Collect(invs, runningDBInstances(models.RunningDBInstancesOpts{Statuses: statuses}))
+	if err = o.warnScopeErrors(c, len(resources.DBInstances), err); err != nil {
+		return resources, err
+	}
+
+	resources.SecurityGroups, err = models.Collect(invs, (*models.Inventory).SecurityGroups)
+	if err = o.warnScopeErrors(c, len(resources.SecurityGroups), err); err != nil {
+		return resources, err
+	}
+
+	// Buckets are listed globally, so we only need to query each account once.
+	results, err := models.Collect(models.PerAccount(invs), func(inv *models.Inventory) (bucketTags, error) {
+		return collectBucketTags(o.pool, inv)
+	})
+	if err = o.warnScopeErrors(c, len(results), err); err != nil {
+		return resources, err
+	}
+	resources.Buckets = make(map[models.Scope][]*s3.Bucket)
+	resources.BucketTags = make(map[models.Scope]map[string][]*s3.Tag)
+	for scope, result := range results {
+		resources.Buckets[scope] = result.buckets
+		resources.BucketTags[scope] = result.tags
+	}
+	return resources, nil
+}
+
+// bucketTags holds the buckets of an account along with their tags, keyed by
+// bucket name.
+type bucketTags struct {
+	buckets []*s3.Bucket
+	tags    map[string][]*s3.Tag
+}
+
+func collectBucketTags(pool *models.Pool, inv *models.Inventory) (bucketTags, error) {
+	buckets, err := inv.ListBuckets()
+	if err != nil {
+		return bucketTags{}, err
+	}
+
+	tags, err := models.Map(pool, buckets, inv.GetBucketTagging)
+	if err != nil {
+		return bucketTags{}, err
+	}
+	result := bucketTags{buckets: buckets, tags: make(map[string][]*s3.Tag)}
+	for i, bucket := range buckets {
+		result.tags[aws.StringValue(bucket.Name)] = tags[i]
+	}
+	return result, nil
+}
+
+// collectTagSources describes the resources missing tags can be inherited
+// from. When the tags are going to be set, a scope whose sources couldn't be
+// described fails the audit, as its resources would get the defaults instead
+// of the tags they inherit.
+func collectTagSources(c *cobra.Command, o *Options, invs []*models.Inventory, resources *views.TaggableResources, apply bool) error {
+	check := func(succeeded int, err error) error {
+		if apply && err != nil {
+			return fmt.Errorf("not setting any tags: %w", err)
+		}
+		return o.warnScopeErrors(c, succeeded, err)
+	}
+
+	var err error
+	resources.VPCs, err = models.Collect(invs, (*models.Inventory).VPCs)
+	if err = check(len(resources.VPCs), err); err != nil {
+		return err
+	}
+	resources.Subnets, err = models.Collect(invs, (*models.Inventory).Subnets)
+	if err = check(len(resources.Subnets), err); err != nil {
+		return err
+	}
+	resources.AutoScalingGroups, err = models.Collect(invs, (*models.Inventory).AutoScalingGroups)
+	return check(len(resources.AutoScalingGroups), err)
+}
+
+// maxCreateTagsResources is the most resources CreateTags accepts at once.
+const maxCreateTagsResources = 1000
+
+// tagAuditEntry is a line of the audit log of --apply.
+type tagAuditEntry struct {
+	Time time.Time `json:"time"`
+	views.TagChangeRecord
+	// Error is set when the tag couldn't be set.
+	Error string `json:"error,omitempty"`
+}
+
+// applyTagRemediation sets the tags of the plan and appends each of them to
+// the audit log. The resources of a scope which get the same tag are tagged
+// with a single call. Failed calls don't stop the others from being made.
+func applyTagRemediation(w io.Writer, invs []*models.Inventory, plan *views.TagRemediationPlan, auditLog string) error {
+	f, err := os.OpenFile(auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log := json.NewEncoder(f)
+
+	byScope := make(map[models.Scope]*models.Inventory)
+	for _, inv := range invs {
+		byScope[inv.Scope] = inv
+	}
+
+	type batch struct {
+		scope      models.Scope
+		key, value string
+		changes    []views.TagChange
+	}
+	batches := make([]*batch, 0)
+	byTag := make(map[string]*batch)
+	for _, change := range plan.Changes {
+		k := change.Resource.Scope.String() + "/" + change.Key + "=" + change.Value
+		b, ok := byTag[k]
+		if !ok || len(b.changes) == maxCreateTagsResources {
+			b = &batch{scope: change.Resource.Scope, key: change.Key, value: change.Value}
+			byTag[k] = b
+			batches = append(batches, b)
+		}
+		b.changes = append(b.changes, change)
+	}
+
+	failed := 0
+	for _, b := range batches {
+		ids := make([]string, 0, len(b.changes))
+		for _, change := range b.changes {
+			ids = append(ids, change.Resource.ID)
+		}
+		err := byScope[b.scope].CreateTags(ids, b.key, b.value)
+		for _, change := range b.changes {
+			entry := tagAuditEntry{Time: time.Now().UTC(), TagChangeRecord: change.Record()}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if err := log.Encode(entry); err != nil {
+				return fmt.Errorf("writing audit log %s: %w", auditLog, err)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(w, "WARNING: %s: %s\n", b.scope, err)
+			failed += len(b.changes)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tags couldn't be set, see %s", failed, len(plan.Changes), auditLog)
+	}
+	fmt.Fprintf(w, "Set %d tags, see %s\n", len(plan.Changes), auditLog)
+	return nil
+}
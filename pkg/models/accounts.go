@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/organizations"
+)
+
+// OrganizationAccounts can be passed as the account list to use every active
+// account in the organization.
+const OrganizationAccounts = "organization"
+
+// DefaultRoleName is the role created in member accounts by AWS Organizations.
+const DefaultRoleName = "OrganizationAccountAccessRole"
+
+// ListOrganizationAccounts returns the IDs of the active accounts in the
+// session's organization. This must be called from the management account or
+// a delegated administrator.
+func ListOrganizationAccounts(s *session.Session) ([]string, error) {
+	accounts := make([]string, 0)
+	err := organizations.New(s).ListAccountsPages(&organizations.ListAccountsInput{},
+		func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+			for _, a := range page.Accounts {
+				if aws.StringValue(a.Status) == organizations.AccountStatusActive {
+					accounts = append(accounts, aws.StringValue(a.Id))
+				}
+			}
+			return !lastPage
+		})
+	sort.Strings(accounts)
+	return accounts, err
+}
+
+// RoleARN returns the ARN of the named role in the account, using the
+// partition of the given region.
+func RoleARN(accountID, roleName, region string) string {
+	partition := endpoints.AwsPartitionID
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		partition = p.ID()
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, accountID, roleName)
+}
+
+// InitAccounts creates an Inventory for each account and region pair. The
+// inventories use credentials from assuming roleName in each of the accounts
+// with the given session. Roles are only assumed once the inventory is first
+// used, so a missing role shows up as an error from that account's queries.
+func InitAccounts(s *session.Session, accountIDs []string, roleName string, regions []string) []*Inventory {
+	invs := make([]*Inventory, 0, len(accountIDs)*len(regions))
+	for _, accountID := range accountIDs {
+		arn := RoleARN(accountID, roleName, aws.StringValue(s.Config.Region))
+		creds := stscreds.NewCredentials(s, arn)
+		for _, region := range regions {
+			inv := Init(s.Copy(&aws.Config{
+				Credentials: creds,
+				Region:      aws.String(region),
+			}))
+			inv.Account = accountID
+			invs = append(invs, inv)
+		}
+	}
+	return invs
+}
+
+// PerAccount returns a single inventory for each account, which is useful for
+// querying global services such as IAM and S3 only once per account. The
+// returned inventories have no region in their scope.
+func PerAccount(invs []*Inventory) []*Inventory {
+	seen := make(map[string]bool)
+	filtered := make([]*Inventory, 0)
+	for _, inv := range invs {
+		if seen[inv.Account] {
+			continue
+		}
+		seen[inv.Account] = true
+
+		global := *inv
+		global.Region = ""
+		filtered = append(filtered, &global)
+	}
+	return filtered
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleARN(t *testing.T) {
+	assert.Equal(t, "arn:aws:iam::123456789012:role/Audit", RoleARN("123456789012", "Audit", "us-west-2"))
+	assert.Equal(t, "arn:aws-us-gov:iam::123456789012:role/Audit", RoleARN("123456789012", "Audit", "us-gov-west-1"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/Audit", RoleARN("123456789012", "Audit", ""))
+}
+
+func TestPerAccount(t *testing.T) {
+	invs := make([]*Inventory, 0)
+	for _, scope := range []Scope{
+		{Account: "111111111111", Region: "us-east-1"},
+		{Account: "111111111111", Region: "us-west-2"},
+		{Account: "222222222222", Region: "us-east-1"},
+	} {
+		inv := NewInventory(nil, nil, nil, nil)
+		inv.Scope = scope
+		invs = append(invs, inv)
+	}
+
+	global := PerAccount(invs)
+	assert.Len(t, global, 2)
+	assert.Equal(t, Scope{Account: "111111111111"}, global[0].Scope)
+	assert.Equal(t, Scope{Account: "222222222222"}, global[1].Scope)
+	// The original inventories are left untouched.
+	assert.Equal(t, "us-east-1", invs[0].Region)
+}
+
+func TestScopeErrors(t *testing.T) {
+	errs := ScopeErrors{
+		{Account: "222222222222", Region: "us-east-1"}: assert.AnError,
+		{Account: "111111111111", Region: "us-west-2"}: assert.AnError,
+	}
+	assert.Equal(t,
+		"111111111111/us-west-2: "+assert.AnError.Error()+"; 222222222222/us-east-1: "+assert.AnError.Error(),
+		errs.Error())
+}
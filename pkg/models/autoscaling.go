@@ -0,0 +1,38 @@
+package models
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+// AutoScalingGroups returns a list of auto scaling groups along with their
+// tags.
+func (inv *Inventory) AutoScalingGroups() ([]*autoscaling.Group, error) {
+	groups := make([]*autoscaling.Group, 0)
+	params := &autoscaling.DescribeAutoScalingGroupsInput{}
+
+	err := inv.AutoScaling.DescribeAutoScalingGroupsPages(params,
+		func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			groups = append(groups, page.AutoScalingGroups...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeAutoScalingGroups", err)
+	}
+	return groups, nil
+}
+
+// LaunchConfigurations returns a list of launch configurations.
+func (inv *Inventory) LaunchConfigurations() ([]*autoscaling.LaunchConfiguration, error) {
+	configs := make([]*autoscaling.LaunchConfiguration, 0)
+	params := &autoscaling.DescribeLaunchConfigurationsInput{}
+
+	err := inv.AutoScaling.DescribeLaunchConfigurationsPages(params,
+		func(page *autoscaling.DescribeLaunchConfigurationsOutput, lastPage bool) bool {
+			configs = append(configs, page.LaunchConfigurations...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeLaunchConfigurations", err)
+	}
+	return configs, nil
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ChangeType is how a resource changed between two snapshots.
+type ChangeType string
+
+// The types of changes.
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+)
+
+// FieldChange is a change to a single field of a resource. Fields are named
+// by their path in the describe result, e.g. "State.Name" or
+// "SecurityGroups[0].GroupId". Tags are named by their key, e.g. "Tags.cost".
+// Old is empty for new fields and New is empty for removed fields.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// ResourceChange is a resource which was added, removed or modified between
+// two snapshots. Kind is the type of resource, e.g. "instance" or "subnet",
+// and ID is its identifier within the scope.
+type ResourceChange struct {
+	Scope
+	Kind   string
+	ID     string
+	Type   ChangeType
+	Fields []FieldChange
+}
+
+type resourceKey struct {
+	Scope Scope
+	Kind  string
+	ID    string
+}
+
+func (k resourceKey) less(other resourceKey) bool {
+	if k.Scope != other.Scope {
+		return k.Scope.Less(other.Scope)
+	}
+	if k.Kind != other.Kind {
+		return k.Kind < other.Kind
+	}
+	return k.ID < other.ID
+}
+
+// Diff compares two snapshots and returns the resources which were added,
+// removed or modified in newer, sorted by scope, kind and ID. Resources are
+// matched by their ID within their scope. Sections only one of the snapshots
+// has are not compared, as their resources would all look added or removed.
+func Diff(older, newer *Snapshot) ([]ResourceChange, error) {
+	version := older.Version
+	if newer.Version < version {
+		version = newer.Version
+	}
+	before, err := snapshotResources(older, version)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshotResources(newer, version)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]resourceKey, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	changes := make([]ResourceChange, 0)
+	for _, key := range keys {
+		change := ResourceChange{Scope: key.Scope, Kind: key.Kind, ID: key.ID}
+		prev, inBefore := before[key]
+		next, inAfter := after[key]
+		switch {
+		case !inBefore:
+			change.Type = Added
+		case !inAfter:
+			change.Type = Removed
+		default:
+			change.Type = Modified
+			change.Fields = diffFields(prev, next)
+			if len(change.Fields) == 0 {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// snapshotResources flattens every resource in the sections snapshots of the
+// version have, keyed by its scope, kind and ID.
+func snapshotResources(snap *Snapshot, version int) (map[resourceKey]map[string]string, error) {
+	resources := make(map[resourceKey]map[string]string)
+	var err error
+	add := func(scope Scope, kind string, id *string, v interface{}) {
+		if err != nil {
+			return
+		}
+		key := resourceKey{Scope: scope, Kind: kind, ID: aws.StringValue(id)}
+		resources[key], err = flatten(v)
+	}
+
+	for _, r := range snap.Regional {
+		scope := Scope{Account: r.Account, Region: r.Region}
+		for _, i := range r.Instances {
+			add(scope, "instance", i.InstanceId, i)
+		}
+		for _, ri := range r.ReservedInstances {
+			add(scope, "reserved_instance", ri.ReservedInstancesId, ri)
+		}
+		for _, sir := range r.SpotInstanceRequests {
+			add(scope, "spot_instance_request", sir.SpotInstanceRequestId, sir)
+		}
+		for _, s := range r.Subnets {
+			add(scope, "subnet", s.SubnetId, s)
+		}
+		for _, vpc := range r.VPCs {
+			add(scope, "vpc", vpc.VpcId, vpc)
+		}
+		for _, ifc := range r.NetworkInterfaces {
+			add(scope, "network_interface", ifc.NetworkInterfaceId, ifc)
+		}
+		if hasSection(version, "addresses") {
+			for _, a := range r.Addresses {
+				add(scope, "address", a.AllocationId, a)
+			}
+		}
+		for _, sg := range r.SecurityGroups {
+			add(scope, "security_group", sg.GroupId, sg)
+		}
+		if hasSection(version, "volumes") {
+			for _, v := range r.Volumes {
+				add(scope, "volume", v.VolumeId, v)
+			}
+		}
+		if hasSection(version, "ebs_snapshots") {
+			for _, s := range r.EBSSnapshots {
+				add(scope, "ebs_snapshot", s.SnapshotId, s)
+			}
+		}
+		if hasSection(version, "images") {
+			for _, image := range r.Images {
+				add(scope, "image", image.ImageId, image)
+			}
+		}
+		if hasSection(version, "launch_template_versions") {
+			for _, v := range r.LaunchTemplateVersions {
+				id := fmt.Sprintf("%s:%d", aws.StringValue(v.LaunchTemplateId), aws.Int64Value(v.VersionNumber))
+				add(scope, "launch_template_version", aws.String(id), v)
+			}
+		}
+		if hasSection(version, "auto_scaling_groups") {
+			for _, g := range r.AutoScalingGroups {
+				add(scope, "auto_scaling_group", g.AutoScalingGroupName, g)
+			}
+		}
+		if hasSection(version, "launch_configurations") {
+			for _, lc := range r.LaunchConfigurations {
+				add(scope, "launch_configuration", lc.LaunchConfigurationName, lc)
+			}
+		}
+		for _, db := range r.DBInstances {
+			add(scope, "db_instance", db.DBInstanceIdentifier, db)
+		}
+		if hasSection(version, "db_clusters") {
+			for _, c := range r.DBClusters {
+				add(scope, "db_cluster", c.DBClusterIdentifier, c)
+			}
+		}
+		for _, ri := range r.ReservedDBInstances {
+			add(scope, "reserved_db_instance", ri.ReservedDBInstanceId, ri)
+		}
+		for _, s := range r.DBSnapshots {
+			add(scope, "db_snapshot", s.DBSnapshotIdentifier, s)
+		}
+	}
+
+	for _, g := range snap.Global {
+		scope := Scope{Account: g.Account}
+		for _, b := range g.Buckets {
+			add(scope, "bucket", b.Name, b)
+		}
+		for name, replication := range g.BucketReplications {
+			add(scope, "bucket_replication", aws.String(name), replication)
+		}
+		if hasSection(version, "bucket_tags") {
+			for name, tags := range g.BucketTags {
+				add(scope, "bucket_tags", aws.String(name), tags)
+			}
+		}
+		for _, u := range g.Users {
+			add(scope, "user", u.UserName, u)
+		}
+		for _, keys := range g.AccessKeys {
+			for _, k := range keys {
+				add(scope, "access_key", k.AccessKeyId, k)
+			}
+		}
+		if hasSection(version, "savings_plans") {
+			for _, p := range g.SavingsPlans {
+				add(scope, "savings_plan", p.SavingsPlanId, p)
+			}
+		}
+	}
+	return resources, err
+}
+
+// flatten converts a describe result into a map of field paths to values.
+func flatten(v interface{}) (map[string]string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	flattenInto(fields, "", decoded)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]string, path string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			flattenInto(fields, joinPath(path, k), e)
+		}
+	case []interface{}:
+		// Tags are keyed by their key so reordering them isn't a change.
+		if tags, ok := tagValues(v); ok {
+			for k, value := range tags {
+				fields[joinPath(path, k)] = value
+			}
+			return
+		}
+		for i, e := range v {
+			flattenInto(fields, fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	case string:
+		fields[path] = v
+	case float64:
+		fields[path] = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		fields[path] = strconv.FormatBool(v)
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// tagValues returns the values of a list of tags by their key, or false if
+// the list isn't a list of tags.
+func tagValues(list []interface{}) (map[string]string, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	tags := make(map[string]string)
+	for _, e := range list {
+		m, ok := e.(map[string]interface{})
+		if !ok || len(m) != 2 {
+			return nil, false
+		}
+		key, keyOK := m["Key"].(string)
+		value, valueOK := m["Value"].(string)
+		if !keyOK || !valueOK {
+			return nil, false
+		}
+		tags[key] = value
+	}
+	return tags, true
+}
+
+func diffFields(prev, next map[string]string) []FieldChange {
+	changes := make([]FieldChange, 0)
+	for field, value := range prev {
+		if nextValue, ok := next[field]; !ok || nextValue != value {
+			changes = append(changes, FieldChange{Field: field, Old: value, New: nextValue})
+		}
+	}
+	for field, value := range next {
+		if _, ok := prev[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
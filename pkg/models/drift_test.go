@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	older := testSnapshot()
+	newer := testSnapshot()
+	r := newer.Regional[0]
+
+	// i-3 was launched and the stopped i-2 was terminated.
+	r.Instances = []*ec2.Instance{r.Instances[0], makeInstance("i-3", "running", "")}
+	// The subnet's free IP count dropped.
+	older.Regional[0].Subnets[0].AvailableIpAddressCount = aws.Int64(20)
+	r.Subnets = []*ec2.Subnet{{SubnetId: aws.String("subnet-1"), AvailableIpAddressCount: aws.Int64(5)}}
+	// The reservation expired.
+	r.ReservedInstances = nil
+	// Reordering tags isn't a change, but changing one is.
+	older.Regional[0].VPCs[0].Tags = []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String("main")},
+		{Key: aws.String("cost"), Value: aws.String("platform")},
+	}
+	r.VPCs = []*ec2.Vpc{{VpcId: aws.String("vpc-1"), Tags: []*ec2.Tag{
+		{Key: aws.String("cost"), Value: aws.String("data")},
+		{Key: aws.String("Name"), Value: aws.String("main")},
+	}}}
+	// A user was removed from the account.
+	newer.Global[0].Users = nil
+
+	changes, err := Diff(older, newer)
+	assert.Nil(t, err)
+
+	regional := Scope{Account: "111111111111", Region: "us-east-1"}
+	global := Scope{Account: "111111111111"}
+	assert.Equal(t, []ResourceChange{
+		{Scope: global, Kind: "user", ID: "alice", Type: Removed},
+		{Scope: regional, Kind: "instance", ID: "i-2", Type: Removed},
+		{Scope: regional, Kind: "instance", ID: "i-3", Type: Added},
+		{Scope: regional, Kind: "reserved_instance", ID: "ri-1", Type: Removed},
+		{Scope: regional, Kind: "subnet", ID: "subnet-1", Type: Modified, Fields: []FieldChange{
+			{Field: "AvailableIpAddressCount", Old: "20", New: "5"},
+		}},
+		{Scope: regional, Kind: "vpc", ID: "vpc-1", Type: Modified, Fields: []FieldChange{
+			{Field: "Tags.cost", Old: "platform", New: "data"},
+		}},
+	}, changes)
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	changes, err := Diff(testSnapshot(), testSnapshot())
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffOlderVersion(t *testing.T) {
+	// Volumes weren't captured before version 4, so they aren't added.
+	older := testSnapshot()
+	older.Version = 3
+	older.Regional[0].Volumes = nil
+	changes, err := Diff(older, testSnapshot())
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestSnapshotFilter(t *testing.T) {
+	snap := testSnapshot()
+	assert.Equal(t, []Scope{
+		{Account: "111111111111", Region: "us-east-1"},
+		{Account: "111111111111"},
+	}, snap.Scopes())
+
+	filtered := snap.Filter([]Scope{{Account: "111111111111", Region: "us-east-1"}})
+	assert.Len(t, filtered.Regional, 1)
+	assert.Empty(t, filtered.Global)
+}
@@ -1,38 +1,37 @@
 package models
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
-var ec2Client *ec2.EC2
-
-// EC2Client sets the client to be used by the models.
-func EC2Client(client *ec2.EC2) {
-	ec2Client = client
-}
-
 // Instances retrieves a list of instances by their instance IDs and returns
 // an error if one occured.
-func Instances(IDs []string) (instances []*ec2.Instance, err error) {
-	var resp *ec2.DescribeInstancesOutput
-
+func (inv *Inventory) Instances(IDs []string) ([]*ec2.Instance, error) {
+	instances := make([]*ec2.Instance, 0)
 	params := &ec2.DescribeInstancesInput{
 		InstanceIds: aws.StringSlice(IDs),
 	}
-	resp, err = ec2Client.DescribeInstances(params)
+
+	err := inv.EC2.DescribeInstancesPages(params,
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, r := range page.Reservations {
+				instances = append(instances, r.Instances...)
+			}
+			return !lastPage
+		})
 	if err != nil {
-		return
-	}
-	instances = make([]*ec2.Instance, 0)
-	for _, r := range resp.Reservations {
-		instances = append(instances, r.Instances...)
+		return nil, opError("DescribeInstances", err)
 	}
-	return
+	return instances, nil
 }
 
-// ReservedInstances returns a slice of reserved instances.
-func ReservedInstances() ([]*ec2.ReservedInstances, error) {
+// ReservedInstances returns a slice of active reserved instances. The API
+// returns all of them in a single response.
+func (inv *Inventory) ReservedInstances() ([]*ec2.ReservedInstances, error) {
 	params := &ec2.DescribeReservedInstancesInput{
 		Filters: []*ec2.Filter{
 			{
@@ -43,8 +42,11 @@ func ReservedInstances() ([]*ec2.ReservedInstances, error) {
 			},
 		},
 	}
-	resp, err := ec2Client.DescribeReservedInstances(params)
-	return resp.ReservedInstances, err
+	resp, err := inv.EC2.DescribeReservedInstances(params)
+	if err != nil {
+		return nil, opError("DescribeReservedInstances", err)
+	}
+	return resp.ReservedInstances, nil
 }
 
 // RunningInstancesOpts are options that can be passed to the running instances
@@ -54,17 +56,20 @@ type RunningInstancesOpts struct {
 }
 
 // RunningInstances returns a slice of running instances
-func RunningInstances(opts RunningInstancesOpts) []*ec2.Instance {
+func (inv *Inventory) RunningInstances(opts RunningInstancesOpts) ([]*ec2.Instance, error) {
 	instances := make([]*ec2.Instance, 0)
 	params := &ec2.DescribeInstancesInput{
 		MaxResults: aws.Int64(1000),
 	}
 
-	ec2Client.DescribeInstancesPages(params,
+	err := inv.EC2.DescribeInstancesPages(params,
 		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
 			for _, r := range page.Reservations {
 				for _, i := range r.Instances {
-					if *i.State.Name != "running" {
+					if i.State == nil || aws.StringValue(i.State.Name) != "running" {
+						continue
+					}
+					if !opts.IncludeSpot && aws.StringValue(i.InstanceLifecycle) == "spot" {
 						continue
 					}
 					instances = append(instances, i)
@@ -72,42 +77,232 @@ func RunningInstances(opts RunningInstancesOpts) []*ec2.Instance {
 			}
 			return !lastPage
 		})
-
-	if !opts.IncludeSpot {
-		filtered := make([]*ec2.Instance, 0)
-		for _, i := range instances {
-			if aws.StringValue(i.InstanceLifecycle) != "spot" {
-				filtered = append(filtered, i)
-			}
-		}
-		instances = filtered
+	if err != nil {
+		return nil, opError("DescribeInstances", err)
 	}
-	return instances
+	return instances, nil
 }
 
 // SpotInstanceRequests returns a slice of spot instance requests by their IDs
 // and an error if one occurs.
-func SpotInstanceRequests(requestIDs []string) (reqs []*ec2.SpotInstanceRequest, err error) {
-	var resp *ec2.DescribeSpotInstanceRequestsOutput
-
+func (inv *Inventory) SpotInstanceRequests(requestIDs []string) ([]*ec2.SpotInstanceRequest, error) {
+	reqs := make([]*ec2.SpotInstanceRequest, 0)
 	params := &ec2.DescribeSpotInstanceRequestsInput{
 		SpotInstanceRequestIds: aws.StringSlice(requestIDs),
 	}
-	resp, err = ec2Client.DescribeSpotInstanceRequests(params)
-	reqs = resp.SpotInstanceRequests
-	return
+
+	err := inv.EC2.DescribeSpotInstanceRequestsPages(params,
+		func(page *ec2.DescribeSpotInstanceRequestsOutput, lastPage bool) bool {
+			reqs = append(reqs, page.SpotInstanceRequests...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeSpotInstanceRequests", err)
+	}
+	return reqs, nil
 }
 
 // Subnets returns a list of subnets
-func Subnets() ([]*ec2.Subnet, error) {
+func (inv *Inventory) Subnets() ([]*ec2.Subnet, error) {
+	subnets := make([]*ec2.Subnet, 0)
 	params := &ec2.DescribeSubnetsInput{}
-	resp, err := ec2Client.DescribeSubnets(params)
-	return resp.Subnets, err
+
+	err := inv.EC2.DescribeSubnetsPages(params,
+		func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+			subnets = append(subnets, page.Subnets...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeSubnets", err)
+	}
+	return subnets, nil
 }
 
 // VPCs returns a list of VPCs
-func VPCs() ([]*ec2.Vpc, error) {
+func (inv *Inventory) VPCs() ([]*ec2.Vpc, error) {
+	vpcs := make([]*ec2.Vpc, 0)
 	params := &ec2.DescribeVpcsInput{}
-	resp, err := ec2Client.DescribeVpcs(params)
-	return resp.Vpcs, err
+
+	err := inv.EC2.DescribeVpcsPages(params,
+		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+			vpcs = append(vpcs, page.Vpcs...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeVpcs", err)
+	}
+	return vpcs, nil
+}
+
+// Volumes returns a list of EBS volumes, in every state.
+func (inv *Inventory) Volumes() ([]*ec2.Volume, error) {
+	volumes := make([]*ec2.Volume, 0)
+	params := &ec2.DescribeVolumesInput{}
+
+	err := inv.EC2.DescribeVolumesPages(params,
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			volumes = append(volumes, page.Volumes...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeVolumes", err)
+	}
+	return volumes, nil
+}
+
+// CreateTags sets a tag on the EC2 resources with the given IDs, e.g.
+// instances, volumes or security groups, replacing its value where it is
+// already set.
+func (inv *Inventory) CreateTags(IDs []string, key, value string) error {
+	params := &ec2.CreateTagsInput{
+		Resources: aws.StringSlice(IDs),
+		Tags:      []*ec2.Tag{{Key: aws.String(key), Value: aws.String(value)}},
+	}
+
+	if _, err := inv.EC2.CreateTags(params); err != nil {
+		return opError("CreateTags", err)
+	}
+	return nil
+}
+
+// EBSSnapshots returns a list of the EBS snapshots owned by the account.
+// Public and shared snapshots aren't included.
+func (inv *Inventory) EBSSnapshots() ([]*ec2.Snapshot, error) {
+	snapshots := make([]*ec2.Snapshot, 0)
+	params := &ec2.DescribeSnapshotsInput{
+		OwnerIds: aws.StringSlice([]string{"self"}),
+	}
+
+	err := inv.EC2.DescribeSnapshotsPages(params,
+		func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+			snapshots = append(snapshots, page.Snapshots...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeSnapshots", err)
+	}
+	return snapshots, nil
+}
+
+// Images returns a list of the AMIs owned by the account.
+func (inv *Inventory) Images() ([]*ec2.Image, error) {
+	images := make([]*ec2.Image, 0)
+	params := &ec2.DescribeImagesInput{
+		Owners: aws.StringSlice([]string{"self"}),
+	}
+
+	err := inv.EC2.DescribeImagesPages(params,
+		func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+			images = append(images, page.Images...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeImages", err)
+	}
+	return images, nil
+}
+
+// LaunchTemplateVersions returns the latest and default versions of every
+// launch template, along with any other versions an auto scaling group uses.
+// Versions which nothing refers to aren't included.
+func (inv *Inventory) LaunchTemplateVersions() ([]*ec2.LaunchTemplateVersion, error) {
+	versions, err := inv.describeLaunchTemplateVersions(&ec2.DescribeLaunchTemplateVersionsInput{
+		Versions: aws.StringSlice([]string{"$Latest", "$Default"}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := inv.AutoScalingGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, params := range pinnedLaunchTemplateVersions(groups) {
+		pinned, err := inv.describeLaunchTemplateVersions(params)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, pinned...)
+	}
+
+	// A pinned version may also be the latest or default one.
+	seen := make(map[string]bool)
+	unique := make([]*ec2.LaunchTemplateVersion, 0, len(versions))
+	for _, v := range versions {
+		key := fmt.Sprintf("%s/%d", aws.StringValue(v.LaunchTemplateId), aws.Int64Value(v.VersionNumber))
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique, nil
+}
+
+func (inv *Inventory) describeLaunchTemplateVersions(params *ec2.DescribeLaunchTemplateVersionsInput) ([]*ec2.LaunchTemplateVersion, error) {
+	versions := make([]*ec2.LaunchTemplateVersion, 0)
+	err := inv.EC2.DescribeLaunchTemplateVersionsPages(params,
+		func(page *ec2.DescribeLaunchTemplateVersionsOutput, lastPage bool) bool {
+			versions = append(versions, page.LaunchTemplateVersions...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeLaunchTemplateVersions", err)
+	}
+	return versions, nil
+}
+
+// pinnedLaunchTemplateVersions returns the input to describe the versions of
+// each launch template which the groups pin, either directly or through a
+// mixed instances policy. Groups which use the latest or default version
+// are left out, as those are always described.
+func pinnedLaunchTemplateVersions(groups []*autoscaling.Group) []*ec2.DescribeLaunchTemplateVersionsInput {
+	specs := make([]*autoscaling.LaunchTemplateSpecification, 0)
+	for _, g := range groups {
+		specs = append(specs, g.LaunchTemplate)
+		if p := g.MixedInstancesPolicy; p != nil && p.LaunchTemplate != nil {
+			specs = append(specs, p.LaunchTemplate.LaunchTemplateSpecification)
+			for _, o := range p.LaunchTemplate.Overrides {
+				specs = append(specs, o.LaunchTemplateSpecification)
+			}
+		}
+	}
+
+	byTemplate := make(map[string]*ec2.DescribeLaunchTemplateVersionsInput)
+	keys := make([]string, 0)
+	pinned := make(map[string]bool)
+	for _, spec := range specs {
+		if spec == nil {
+			continue
+		}
+		version := aws.StringValue(spec.Version)
+		if version == "" || version == "$Latest" || version == "$Default" {
+			continue
+		}
+
+		key := "id:" + aws.StringValue(spec.LaunchTemplateId)
+		if spec.LaunchTemplateId == nil {
+			key = "name:" + aws.StringValue(spec.LaunchTemplateName)
+		}
+		params, ok := byTemplate[key]
+		if !ok {
+			params = &ec2.DescribeLaunchTemplateVersionsInput{}
+			if spec.LaunchTemplateId != nil {
+				params.LaunchTemplateId = spec.LaunchTemplateId
+			} else {
+				params.LaunchTemplateName = spec.LaunchTemplateName
+			}
+			byTemplate[key] = params
+			keys = append(keys, key)
+		}
+		if !pinned[key+"/"+version] {
+			pinned[key+"/"+version] = true
+			params.Versions = append(params.Versions, aws.String(version))
+		}
+	}
+
+	inputs := make([]*ec2.DescribeLaunchTemplateVersionsInput, 0, len(keys))
+	for _, key := range keys {
+		inputs = append(inputs, byTemplate[key])
+	}
+	return inputs
 }
@@ -5,47 +5,48 @@ import (
 	"github.com/aws/aws-sdk-go/service/iam"
 )
 
-var iamClient *iam.IAM
-
-// IAMClient sets the client to be used by the models.
-func IAMClient(client *iam.IAM) {
-	iamClient = client
-}
-
 // IAM Users returns the list of IAM users
-func IAMUsers() ([]*iam.User, error) {
-	var err error
+func (inv *Inventory) IAMUsers() ([]*iam.User, error) {
+	users := make([]*iam.User, 0)
 	input := &iam.ListUsersInput{}
 
-	users := make([]*iam.User, 0)
-	err = iamClient.ListUsersPages(input,
+	err := inv.IAM.ListUsersPages(input,
 		func(page *iam.ListUsersOutput, lastPage bool) bool {
 			users = append(users, page.Users...)
 			return !lastPage
 		})
-
-	return users, err
+	if err != nil {
+		return nil, opError("ListUsers", err)
+	}
+	return users, nil
 }
 
 // IAMAccessKeysMetadata returns all AccessKeyMetadata
-func IAMAccessKeysMeatadata(username string) ([]*iam.AccessKeyMetadata, error) {
-	var err error
+func (inv *Inventory) IAMAccessKeysMeatadata(username string) ([]*iam.AccessKeyMetadata, error) {
+	keys := make([]*iam.AccessKeyMetadata, 0)
 	input := &iam.ListAccessKeysInput{
 		UserName: aws.String(username),
 	}
 
-	keys := make([]*iam.AccessKeyMetadata, 0)
-	err = iamClient.ListAccessKeysPages(input,
+	err := inv.IAM.ListAccessKeysPages(input,
 		func(page *iam.ListAccessKeysOutput, lastPage bool) bool {
 			keys = append(keys, page.AccessKeyMetadata...)
 			return !lastPage
 		})
-	return keys, err
+	if err != nil {
+		return nil, opError("ListAccessKeys", err)
+	}
+	return keys, nil
 }
 
-func IAMAccessKeyLastUsed(accessKeyID string) (*iam.GetAccessKeyLastUsedOutput, error) {
+// IAMAccessKeyLastUsed returns when the access key was last used.
+func (inv *Inventory) IAMAccessKeyLastUsed(accessKeyID string) (*iam.GetAccessKeyLastUsedOutput, error) {
 	input := &iam.GetAccessKeyLastUsedInput{
 		AccessKeyId: aws.String(accessKeyID),
 	}
-	return iamClient.GetAccessKeyLastUsed(input)
+	output, err := inv.IAM.GetAccessKeyLastUsed(input)
+	if err != nil {
+		return nil, opError("GetAccessKeyLastUsed", err)
+	}
+	return output, nil
 }
@@ -1,13 +1,55 @@
 package models
 
 import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/aws/aws-sdk-go/service/savingsplans/savingsplansiface"
 )
 
+// Inventory queries AWS resources through the service clients it was
+// constructed with. Since the clients are interfaces, fakes can be swapped in
+// for testing and several inventories (e.g. one per account) can be used side
+// by side in the same process.
+type Inventory struct {
+	Scope
+
+	EC2 ec2iface.EC2API
+	RDS rdsiface.RDSAPI
+	S3  s3iface.S3API
+	IAM iamiface.IAMAPI
+	// SavingsPlans and AutoScaling are set by Init, but not by NewInventory,
+	// so inventories which need them have to set them themselves.
+	SavingsPlans savingsplansiface.SavingsPlansAPI
+	AutoScaling  autoscalingiface.AutoScalingAPI
+
+	// session is only set for inventories created from a session and is used
+	// for operations that need to sign requests themselves.
+	session *session.Session
+}
+
+// NewInventory creates an Inventory which uses the given clients.
+func NewInventory(ec2Client ec2iface.EC2API, rdsClient rdsiface.RDSAPI, s3Client s3iface.S3API, iamClient iamiface.IAMAPI) *Inventory {
+	return &Inventory{
+		EC2: ec2Client,
+		RDS: rdsClient,
+		S3:  s3Client,
+		IAM: iamClient,
+	}
+}
+
 // DefaultSession creates and initializes the underlying default session for
 // working with models.
 func DefaultSession() *session.Session {
@@ -20,10 +62,34 @@ func DefaultSession() *session.Session {
 	)
 }
 
-// Init initializes the clients used by the models.
-func Init(s *session.Session) {
-	EC2Client(ec2.New(s))
-	RDSClient(rds.New(s))
-	S3Client(s3.New(s))
-	IAMClient(iam.New(s))
+// NewSession creates a session from the shared config using the given
+// profile and region. Empty values fall back to the defaults of the shared
+// config and environment.
+func NewSession(profile, region string) (*session.Session, error) {
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           profile,
+	}
+	if region != "" {
+		opts.Config.Region = aws.String(region)
+	}
+	return session.NewSessionWithOptions(opts)
+}
+
+// Init creates an Inventory whose clients are all created from the given
+// session. The inventory is scoped to the session's region.
+func Init(s *session.Session) *Inventory {
+	inv := NewInventory(ec2.New(s), rds.New(s), s3.New(s), iam.New(s))
+	inv.SavingsPlans = savingsplans.New(s)
+	inv.AutoScaling = autoscaling.New(s)
+	inv.Region = aws.StringValue(s.Config.Region)
+	inv.session = s
+	return inv
+}
+
+// opError wraps an error returned by an API call with the name of the
+// operation, so it's clear which call failed when errors are reported for a
+// whole command.
+func opError(operation string, err error) error {
+	return fmt.Errorf("%s: %w", operation, err)
 }
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEC2 is an ec2iface.EC2API which returns canned pages. Calling a method
+// which isn't overridden panics, which is what we want in tests.
+type fakeEC2 struct {
+	ec2iface.EC2API
+	instancePages [][]*ec2.Instance
+
+	launchTemplateVersions []*ec2.LaunchTemplateVersion
+	launchTemplateCalls    []*ec2.DescribeLaunchTemplateVersionsInput
+}
+
+func (f *fakeEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	for i, instances := range f.instancePages {
+		page := &ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{Instances: instances}},
+		}
+		if !fn(page, i == len(f.instancePages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// DescribeLaunchTemplateVersionsPages returns the default versions if no
+// template is given, like $Latest and $Default would, and otherwise the
+// template's versions with the given numbers.
+func (f *fakeEC2) DescribeLaunchTemplateVersionsPages(input *ec2.DescribeLaunchTemplateVersionsInput, fn func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool) error {
+	f.launchTemplateCalls = append(f.launchTemplateCalls, input)
+	versions := make([]*ec2.LaunchTemplateVersion, 0)
+	for _, v := range f.launchTemplateVersions {
+		if input.LaunchTemplateId == nil && input.LaunchTemplateName == nil {
+			if aws.BoolValue(v.DefaultVersion) {
+				versions = append(versions, v)
+			}
+			continue
+		}
+		if aws.StringValue(v.LaunchTemplateId) != aws.StringValue(input.LaunchTemplateId) &&
+			aws.StringValue(v.LaunchTemplateName) != aws.StringValue(input.LaunchTemplateName) {
+			continue
+		}
+		for _, n := range input.Versions {
+			if aws.StringValue(n) == strconv.FormatInt(aws.Int64Value(v.VersionNumber), 10) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	fn(&ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: versions}, true)
+	return nil
+}
+
+type fakeAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	groups []*autoscaling.Group
+}
+
+func (f *fakeAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	fn(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: f.groups}, true)
+	return nil
+}
+
+type fakeRDS struct {
+	rdsiface.RDSAPI
+	instances    []*rds.DBInstance
+	reservations []*rds.ReservedDBInstance
+}
+
+func (f *fakeRDS) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	fn(&rds.DescribeDBInstancesOutput{DBInstances: f.instances}, true)
+	return nil
+}
+
+func (f *fakeRDS) DescribeReservedDBInstancesPages(input *rds.DescribeReservedDBInstancesInput, fn func(*rds.DescribeReservedDBInstancesOutput, bool) bool) error {
+	fn(&rds.DescribeReservedDBInstancesOutput{ReservedDBInstances: f.reservations}, true)
+	return nil
+}
+
+func makeInstance(id, state, lifecycle string) *ec2.Instance {
+	i := &ec2.Instance{
+		InstanceId: aws.String(id),
+		State:      &ec2.InstanceState{Name: aws.String(state)},
+	}
+	if lifecycle != "" {
+		i.InstanceLifecycle = aws.String(lifecycle)
+	}
+	return i
+}
+
+func dbIdentifiers(dbs []*rds.DBInstance) []string {
+	ids := make([]string, len(dbs))
+	for i, db := range dbs {
+		ids[i] = aws.StringValue(db.DBInstanceIdentifier)
+	}
+	return ids
+}
+
+func instanceIDs(instances []*ec2.Instance) []string {
+	ids := make([]string, len(instances))
+	for n, i := range instances {
+		ids[n] = aws.StringValue(i.InstanceId)
+	}
+	return ids
+}
+
+func TestRunningInstances(t *testing.T) {
+	inv := NewInventory(&fakeEC2{
+		instancePages: [][]*ec2.Instance{
+			{
+				makeInstance("i-1", "running", ""),
+				makeInstance("i-2", "stopped", ""),
+			},
+			{
+				makeInstance("i-3", "running", "spot"),
+				makeInstance("i-4", "running", ""),
+			},
+		},
+	}, nil, nil, nil)
+
+	all, err := inv.RunningInstances(RunningInstancesOpts{IncludeSpot: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1", "i-3", "i-4"}, instanceIDs(all))
+
+	onDemand, err := inv.RunningInstances(RunningInstancesOpts{IncludeSpot: false})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1", "i-4"}, instanceIDs(onDemand))
+}
+
+func TestLaunchTemplateVersions(t *testing.T) {
+	version := func(id, name string, n int64, isDefault bool) *ec2.LaunchTemplateVersion {
+		return &ec2.LaunchTemplateVersion{
+			LaunchTemplateId:   aws.String(id),
+			LaunchTemplateName: aws.String(name),
+			VersionNumber:      aws.Int64(n),
+			DefaultVersion:     aws.Bool(isDefault),
+		}
+	}
+	ec2Client := &fakeEC2{launchTemplateVersions: []*ec2.LaunchTemplateVersion{
+		version("lt-1", "web", 1, false),
+		version("lt-1", "web", 2, false),
+		version("lt-1", "web", 3, true),
+		version("lt-2", "batch", 1, false),
+		version("lt-2", "batch", 2, true),
+	}}
+	inv := NewInventory(ec2Client, nil, nil, nil)
+	inv.AutoScaling = &fakeAutoScaling{groups: []*autoscaling.Group{
+		{LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateId: aws.String("lt-1"),
+			Version:          aws.String("1"),
+		}},
+		{LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateId: aws.String("lt-1"),
+			Version:          aws.String("$Latest"),
+		}},
+		{MixedInstancesPolicy: &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{
+				LaunchTemplateSpecification: &autoscaling.LaunchTemplateSpecification{
+					LaunchTemplateName: aws.String("batch"),
+					Version:            aws.String("1"),
+				},
+			},
+		}},
+		// The default version is only returned once.
+		{LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateId: aws.String("lt-1"),
+			Version:          aws.String("3"),
+		}},
+	}}
+
+	versions, err := inv.LaunchTemplateVersions()
+	assert.Nil(t, err)
+	got := make([]string, len(versions))
+	for i, v := range versions {
+		got[i] = fmt.Sprintf("%s/%d", aws.StringValue(v.LaunchTemplateId), aws.Int64Value(v.VersionNumber))
+	}
+	assert.ElementsMatch(t, []string{"lt-1/3", "lt-2/2", "lt-1/1", "lt-2/1"}, got)
+	// The pinned versions of each template are described at once.
+	assert.Len(t, ec2Client.launchTemplateCalls, 3)
+}
+
+func TestRDSFiltering(t *testing.T) {
+	inv := NewInventory(nil, &fakeRDS{
+		instances: []*rds.DBInstance{
+			{DBInstanceIdentifier: aws.String("db-1"), DBInstanceStatus: aws.String("available")},
+			{DBInstanceIdentifier: aws.String("db-2"), DBInstanceStatus: aws.String("stopped")},
+			{DBInstanceIdentifier: aws.String("db-3"), DBInstanceStatus: aws.String("backing-up")},
+			{DBInstanceIdentifier: aws.String("db-4"), DBInstanceStatus: aws.String("modifying")},
+			{DBInstanceIdentifier: aws.String("db-5"), DBInstanceStatus: aws.String("creating")},
+		},
+		reservations: []*rds.ReservedDBInstance{
+			{ReservedDBInstanceId: aws.String("ri-1"), State: aws.String("active")},
+			{ReservedDBInstanceId: aws.String("ri-2"), State: aws.String("retired")},
+		},
+	}, nil, nil)
+
+	dbs, err := inv.RunningDBInstances(RunningDBInstancesOpts{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db-1", "db-3", "db-4"}, dbIdentifiers(dbs))
+
+	dbs, err = inv.RunningDBInstances(RunningDBInstancesOpts{Statuses: []string{"available", "stopped"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db-1", "db-2"}, dbIdentifiers(dbs))
+
+	ris, err := inv.ReservedDBInstances()
+	assert.Nil(t, err)
+	assert.Len(t, ris, 1)
+	assert.Equal(t, "ri-1", aws.StringValue(ris[0].ReservedDBInstanceId))
+}
+
+func TestGetRDSLogDownloadURLRequiresSession(t *testing.T) {
+	inv := NewInventory(nil, &fakeRDS{}, nil, nil)
+	_, err := inv.GetRDSLogDownloadURL("db-1", "error/postgresql.log")
+	assert.NotNil(t, err)
+}
@@ -5,20 +5,43 @@ import (
 )
 
 // NetworkInterfaces returns all of the network interfaces or an error if one occured.
-func NetworkInterfaces() ([]*ec2.NetworkInterface, error) {
+func (inv *Inventory) NetworkInterfaces() ([]*ec2.NetworkInterface, error) {
+	ifcs := make([]*ec2.NetworkInterface, 0)
 	params := &ec2.DescribeNetworkInterfacesInput{}
-	resp, err := ec2Client.DescribeNetworkInterfaces(params)
-	return resp.NetworkInterfaces, err
+
+	err := inv.EC2.DescribeNetworkInterfacesPages(params,
+		func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+			ifcs = append(ifcs, page.NetworkInterfaces...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeNetworkInterfaces", err)
+	}
+	return ifcs, nil
 }
 
 // SecurityGroups returns all of the security groups or an error if one occured.
-func SecurityGroups() ([]*ec2.SecurityGroup, error) {
+func (inv *Inventory) SecurityGroups() ([]*ec2.SecurityGroup, error) {
 	securityGroups := make([]*ec2.SecurityGroup, 0)
-	params := &ec2.DescribeSecurityGroupsInput{GroupIds: []*string{}}
-	err := ec2Client.DescribeSecurityGroupsPages(params,
+	params := &ec2.DescribeSecurityGroupsInput{}
+
+	err := inv.EC2.DescribeSecurityGroupsPages(params,
 		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
 			securityGroups = append(securityGroups, page.SecurityGroups...)
 			return !lastPage
 		})
-	return securityGroups, err
+	if err != nil {
+		return nil, opError("DescribeSecurityGroups", err)
+	}
+	return securityGroups, nil
+}
+
+// Addresses returns all of the Elastic IPs. The API returns all of them in a
+// single response.
+func (inv *Inventory) Addresses() ([]*ec2.Address, error) {
+	resp, err := inv.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, opError("DescribeAddresses", err)
+	}
+	return resp.Addresses, nil
 }
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/aws/aws-sdk-go/service/savingsplans/savingsplansiface"
+)
+
+// The offline clients answer the calls made by the Inventory accessors from a
+// snapshot. Filters are not applied, apart from the IDs the accessors look
+// resources up by, since the snapshot was captured with the same filters.
+// Calls answered from sections older versions of the snapshot don't have fail.
+// Calling any other method panics.
+
+type offlineEC2 struct {
+	ec2iface.EC2API
+	snap    *RegionalSnapshot
+	version int
+}
+
+func (c *offlineEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	ids := make(map[string]bool)
+	for _, id := range input.InstanceIds {
+		ids[aws.StringValue(id)] = true
+	}
+
+	instances := make([]*ec2.Instance, 0)
+	for _, i := range c.snap.Instances {
+		if len(ids) == 0 || ids[aws.StringValue(i.InstanceId)] {
+			instances = append(instances, i)
+		}
+	}
+	fn(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: instances}},
+	}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeReservedInstances(*ec2.DescribeReservedInstancesInput) (*ec2.DescribeReservedInstancesOutput, error) {
+	return &ec2.DescribeReservedInstancesOutput{ReservedInstances: c.snap.ReservedInstances}, nil
+}
+
+func (c *offlineEC2) DescribeSpotInstanceRequestsPages(input *ec2.DescribeSpotInstanceRequestsInput, fn func(*ec2.DescribeSpotInstanceRequestsOutput, bool) bool) error {
+	ids := make(map[string]bool)
+	for _, id := range input.SpotInstanceRequestIds {
+		ids[aws.StringValue(id)] = true
+	}
+
+	requests := make([]*ec2.SpotInstanceRequest, 0)
+	for _, r := range c.snap.SpotInstanceRequests {
+		if len(ids) == 0 || ids[aws.StringValue(r.SpotInstanceRequestId)] {
+			requests = append(requests, r)
+		}
+	}
+	fn(&ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: requests}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeSubnetsPages(input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool) error {
+	fn(&ec2.DescribeSubnetsOutput{Subnets: c.snap.Subnets}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeVpcsPages(input *ec2.DescribeVpcsInput, fn func(*ec2.DescribeVpcsOutput, bool) bool) error {
+	fn(&ec2.DescribeVpcsOutput{Vpcs: c.snap.VPCs}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: c.snap.NetworkInterfaces}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeAddresses(*ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	if err := requireSection(c.version, "addresses", "DescribeAddresses"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeAddressesOutput{Addresses: c.snap.Addresses}, nil
+}
+
+func (c *offlineEC2) DescribeSecurityGroupsPages(input *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+	fn(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: c.snap.SecurityGroups}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	if err := requireSection(c.version, "volumes", "DescribeVolumes"); err != nil {
+		return err
+	}
+	fn(&ec2.DescribeVolumesOutput{Volumes: c.snap.Volumes}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeSnapshotsPages(input *ec2.DescribeSnapshotsInput, fn func(*ec2.DescribeSnapshotsOutput, bool) bool) error {
+	if err := requireSection(c.version, "ebs_snapshots", "DescribeSnapshots"); err != nil {
+		return err
+	}
+	fn(&ec2.DescribeSnapshotsOutput{Snapshots: c.snap.EBSSnapshots}, true)
+	return nil
+}
+
+func (c *offlineEC2) DescribeImagesPages(input *ec2.DescribeImagesInput, fn func(*ec2.DescribeImagesOutput, bool) bool) error {
+	if err := requireSection(c.version, "images", "DescribeImages"); err != nil {
+		return err
+	}
+	fn(&ec2.DescribeImagesOutput{Images: c.snap.Images}, true)
+	return nil
+}
+
+// DescribeLaunchTemplateVersionsPages returns every version in the snapshot,
+// unless a template is given, in which case only its numbered versions are.
+func (c *offlineEC2) DescribeLaunchTemplateVersionsPages(input *ec2.DescribeLaunchTemplateVersionsInput, fn func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool) error {
+	if err := requireSection(c.version, "launch_template_versions", "DescribeLaunchTemplateVersions"); err != nil {
+		return err
+	}
+	if input.LaunchTemplateId == nil && input.LaunchTemplateName == nil {
+		fn(&ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: c.snap.LaunchTemplateVersions}, true)
+		return nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, v := range input.Versions {
+		wanted[aws.StringValue(v)] = true
+	}
+	versions := make([]*ec2.LaunchTemplateVersion, 0)
+	for _, v := range c.snap.LaunchTemplateVersions {
+		if input.LaunchTemplateId != nil && aws.StringValue(v.LaunchTemplateId) != aws.StringValue(input.LaunchTemplateId) {
+			continue
+		}
+		if input.LaunchTemplateName != nil && aws.StringValue(v.LaunchTemplateName) != aws.StringValue(input.LaunchTemplateName) {
+			continue
+		}
+		if wanted[strconv.FormatInt(aws.Int64Value(v.VersionNumber), 10)] {
+			versions = append(versions, v)
+		}
+	}
+	fn(&ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: versions}, true)
+	return nil
+}
+
+func (c *offlineEC2) CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return nil, errNotInSnapshot("CreateTags")
+}
+
+type offlineAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	snap    *RegionalSnapshot
+	version int
+}
+
+func (c *offlineAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	if err := requireSection(c.version, "auto_scaling_groups", "DescribeAutoScalingGroups"); err != nil {
+		return err
+	}
+	fn(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: c.snap.AutoScalingGroups}, true)
+	return nil
+}
+
+func (c *offlineAutoScaling) DescribeLaunchConfigurationsPages(input *autoscaling.DescribeLaunchConfigurationsInput, fn func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	if err := requireSection(c.version, "launch_configurations", "DescribeLaunchConfigurations"); err != nil {
+		return err
+	}
+	fn(&autoscaling.DescribeLaunchConfigurationsOutput{LaunchConfigurations: c.snap.LaunchConfigurations}, true)
+	return nil
+}
+
+type offlineRDS struct {
+	rdsiface.RDSAPI
+	snap    *RegionalSnapshot
+	version int
+}
+
+func (c *offlineRDS) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	fn(&rds.DescribeDBInstancesOutput{DBInstances: c.snap.DBInstances}, true)
+	return nil
+}
+
+func (c *offlineRDS) DescribeDBClustersPages(input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool) error {
+	if err := requireSection(c.version, "db_clusters", "DescribeDBClusters"); err != nil {
+		return err
+	}
+	fn(&rds.DescribeDBClustersOutput{DBClusters: c.snap.DBClusters}, true)
+	return nil
+}
+
+func (c *offlineRDS) DescribeReservedDBInstancesPages(input *rds.DescribeReservedDBInstancesInput, fn func(*rds.DescribeReservedDBInstancesOutput, bool) bool) error {
+	fn(&rds.DescribeReservedDBInstancesOutput{ReservedDBInstances: c.snap.ReservedDBInstances}, true)
+	return nil
+}
+
+func (c *offlineRDS) DescribeDBSnapshotsPages(input *rds.DescribeDBSnapshotsInput, fn func(*rds.DescribeDBSnapshotsOutput, bool) bool) error {
+	fn(&rds.DescribeDBSnapshotsOutput{DBSnapshots: c.snap.DBSnapshots}, true)
+	return nil
+}
+
+func (c *offlineRDS) DescribeDBLogFilesPages(*rds.DescribeDBLogFilesInput, func(*rds.DescribeDBLogFilesOutput, bool) bool) error {
+	return errNotInSnapshot("DescribeDBLogFiles")
+}
+
+type offlineS3 struct {
+	s3iface.S3API
+	snap    *GlobalSnapshot
+	version int
+}
+
+func (c *offlineS3) ListBuckets(*s3.ListBucketsInput) (*s3.ListBucketsOutput, error) {
+	return &s3.ListBucketsOutput{Buckets: c.snap.Buckets}, nil
+}
+
+func (c *offlineS3) GetBucketReplication(input *s3.GetBucketReplicationInput) (*s3.GetBucketReplicationOutput, error) {
+	replication, ok := c.snap.BucketReplications[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, awserr.New("ReplicationConfigurationNotFoundError", "The replication configuration was not found", nil)
+	}
+	return &s3.GetBucketReplicationOutput{ReplicationConfiguration: replication}, nil
+}
+
+func (c *offlineS3) GetBucketTagging(input *s3.GetBucketTaggingInput) (*s3.GetBucketTaggingOutput, error) {
+	if err := requireSection(c.version, "bucket_tags", "GetBucketTagging"); err != nil {
+		return nil, err
+	}
+	tags, ok := c.snap.BucketTags[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, awserr.New("NoSuchTagSet", "The TagSet does not exist", nil)
+	}
+	return &s3.GetBucketTaggingOutput{TagSet: tags}, nil
+}
+
+type offlineIAM struct {
+	iamiface.IAMAPI
+	snap *GlobalSnapshot
+}
+
+func (c *offlineIAM) ListUsersPages(input *iam.ListUsersInput, fn func(*iam.ListUsersOutput, bool) bool) error {
+	fn(&iam.ListUsersOutput{Users: c.snap.Users}, true)
+	return nil
+}
+
+func (c *offlineIAM) ListAccessKeysPages(input *iam.ListAccessKeysInput, fn func(*iam.ListAccessKeysOutput, bool) bool) error {
+	fn(&iam.ListAccessKeysOutput{AccessKeyMetadata: c.snap.AccessKeys[aws.StringValue(input.UserName)]}, true)
+	return nil
+}
+
+func (c *offlineIAM) GetAccessKeyLastUsed(input *iam.GetAccessKeyLastUsedInput) (*iam.GetAccessKeyLastUsedOutput, error) {
+	return &iam.GetAccessKeyLastUsedOutput{
+		AccessKeyLastUsed: c.snap.AccessKeysLastUsed[aws.StringValue(input.AccessKeyId)],
+	}, nil
+}
+
+type offlineSavingsPlans struct {
+	savingsplansiface.SavingsPlansAPI
+	snap    *GlobalSnapshot
+	version int
+}
+
+func (c *offlineSavingsPlans) DescribeSavingsPlans(*savingsplans.DescribeSavingsPlansInput) (*savingsplans.DescribeSavingsPlansOutput, error) {
+	if err := requireSection(c.version, "savings_plans", "DescribeSavingsPlans"); err != nil {
+		return nil, err
+	}
+	return &savingsplans.DescribeSavingsPlansOutput{SavingsPlans: c.snap.SavingsPlans}, nil
+}
@@ -0,0 +1,461 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/aws/aws-sdk-go/service/savingsplans/savingsplansiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// pager serves pages of the given sizes and then fails with err, like the
+// SDK's Pages methods do when fetching a later page fails. Operations which
+// aren't paginated return all of the items at once, or just err.
+type pager struct {
+	sizes []int
+	err   error
+}
+
+func (p pager) serve(fn func(start, n int, lastPage bool) bool) error {
+	start := 0
+	for i, n := range p.sizes {
+		if !fn(start, n, i == len(p.sizes)-1 && p.err == nil) {
+			return nil
+		}
+		start += n
+	}
+	return p.err
+}
+
+func (p pager) total() int {
+	total := 0
+	for _, n := range p.sizes {
+		total += n
+	}
+	return total
+}
+
+func ids(prefix string, start, n int) []*string {
+	ids := make([]*string, n)
+	for i := range ids {
+		ids[i] = aws.String(fmt.Sprintf("%s-%d", prefix, start+i))
+	}
+	return ids
+}
+
+type pagedEC2 struct {
+	ec2iface.EC2API
+	pager
+}
+
+func (f *pagedEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		instances := make([]*ec2.Instance, 0, n)
+		for _, id := range ids("i", start, n) {
+			instances = append(instances, &ec2.Instance{
+				InstanceId: id,
+				State:      &ec2.InstanceState{Name: aws.String("running")},
+			})
+		}
+		return fn(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: instances}}}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeReservedInstances(*ec2.DescribeReservedInstancesInput) (*ec2.DescribeReservedInstancesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	ris := make([]*ec2.ReservedInstances, 0)
+	for _, id := range ids("ri", 0, f.total()) {
+		ris = append(ris, &ec2.ReservedInstances{ReservedInstancesId: id})
+	}
+	return &ec2.DescribeReservedInstancesOutput{ReservedInstances: ris}, nil
+}
+
+func (f *pagedEC2) DescribeAddresses(*ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	addresses := make([]*ec2.Address, 0)
+	for _, id := range ids("eipalloc", 0, f.total()) {
+		addresses = append(addresses, &ec2.Address{AllocationId: id})
+	}
+	return &ec2.DescribeAddressesOutput{Addresses: addresses}, nil
+}
+
+func (f *pagedEC2) DescribeSpotInstanceRequestsPages(input *ec2.DescribeSpotInstanceRequestsInput, fn func(*ec2.DescribeSpotInstanceRequestsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		requests := make([]*ec2.SpotInstanceRequest, 0, n)
+		for _, id := range ids("sir", start, n) {
+			requests = append(requests, &ec2.SpotInstanceRequest{SpotInstanceRequestId: id})
+		}
+		return fn(&ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: requests}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeSubnetsPages(input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		subnets := make([]*ec2.Subnet, 0, n)
+		for _, id := range ids("subnet", start, n) {
+			subnets = append(subnets, &ec2.Subnet{SubnetId: id})
+		}
+		return fn(&ec2.DescribeSubnetsOutput{Subnets: subnets}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeVpcsPages(input *ec2.DescribeVpcsInput, fn func(*ec2.DescribeVpcsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		vpcs := make([]*ec2.Vpc, 0, n)
+		for _, id := range ids("vpc", start, n) {
+			vpcs = append(vpcs, &ec2.Vpc{VpcId: id})
+		}
+		return fn(&ec2.DescribeVpcsOutput{Vpcs: vpcs}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		ifcs := make([]*ec2.NetworkInterface, 0, n)
+		for _, id := range ids("eni", start, n) {
+			ifcs = append(ifcs, &ec2.NetworkInterface{NetworkInterfaceId: id})
+		}
+		return fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: ifcs}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeSecurityGroupsPages(input *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		sgs := make([]*ec2.SecurityGroup, 0, n)
+		for _, id := range ids("sg", start, n) {
+			sgs = append(sgs, &ec2.SecurityGroup{GroupId: id})
+		}
+		return fn(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: sgs}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		volumes := make([]*ec2.Volume, 0, n)
+		for _, id := range ids("vol", start, n) {
+			volumes = append(volumes, &ec2.Volume{VolumeId: id})
+		}
+		return fn(&ec2.DescribeVolumesOutput{Volumes: volumes}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeSnapshotsPages(input *ec2.DescribeSnapshotsInput, fn func(*ec2.DescribeSnapshotsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		snapshots := make([]*ec2.Snapshot, 0, n)
+		for _, id := range ids("snap", start, n) {
+			snapshots = append(snapshots, &ec2.Snapshot{SnapshotId: id})
+		}
+		return fn(&ec2.DescribeSnapshotsOutput{Snapshots: snapshots}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeImagesPages(input *ec2.DescribeImagesInput, fn func(*ec2.DescribeImagesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		images := make([]*ec2.Image, 0, n)
+		for _, id := range ids("ami", start, n) {
+			images = append(images, &ec2.Image{ImageId: id})
+		}
+		return fn(&ec2.DescribeImagesOutput{Images: images}, last)
+	})
+}
+
+func (f *pagedEC2) DescribeLaunchTemplateVersionsPages(input *ec2.DescribeLaunchTemplateVersionsInput, fn func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		versions := make([]*ec2.LaunchTemplateVersion, 0, n)
+		for _, id := range ids("lt", start, n) {
+			versions = append(versions, &ec2.LaunchTemplateVersion{LaunchTemplateId: id})
+		}
+		return fn(&ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: versions}, last)
+	})
+}
+
+func (f *pagedEC2) CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+type pagedRDS struct {
+	rdsiface.RDSAPI
+	pager
+}
+
+func (f *pagedRDS) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		dbs := make([]*rds.DBInstance, 0, n)
+		for _, id := range ids("db", start, n) {
+			dbs = append(dbs, &rds.DBInstance{DBInstanceIdentifier: id, DBInstanceStatus: aws.String("available")})
+		}
+		return fn(&rds.DescribeDBInstancesOutput{DBInstances: dbs}, last)
+	})
+}
+
+func (f *pagedRDS) DescribeDBClustersPages(input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		clusters := make([]*rds.DBCluster, 0, n)
+		for _, id := range ids("cluster", start, n) {
+			clusters = append(clusters, &rds.DBCluster{DBClusterIdentifier: id})
+		}
+		return fn(&rds.DescribeDBClustersOutput{DBClusters: clusters}, last)
+	})
+}
+
+func (f *pagedRDS) DescribeReservedDBInstancesPages(input *rds.DescribeReservedDBInstancesInput, fn func(*rds.DescribeReservedDBInstancesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		ris := make([]*rds.ReservedDBInstance, 0, n)
+		for _, id := range ids("rdsri", start, n) {
+			ris = append(ris, &rds.ReservedDBInstance{ReservedDBInstanceId: id, State: aws.String("active")})
+		}
+		return fn(&rds.DescribeReservedDBInstancesOutput{ReservedDBInstances: ris}, last)
+	})
+}
+
+func (f *pagedRDS) DescribeDBSnapshotsPages(input *rds.DescribeDBSnapshotsInput, fn func(*rds.DescribeDBSnapshotsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		snapshots := make([]*rds.DBSnapshot, 0, n)
+		for _, id := range ids("snap", start, n) {
+			snapshots = append(snapshots, &rds.DBSnapshot{DBSnapshotIdentifier: id})
+		}
+		return fn(&rds.DescribeDBSnapshotsOutput{DBSnapshots: snapshots}, last)
+	})
+}
+
+func (f *pagedRDS) DescribeDBLogFilesPages(input *rds.DescribeDBLogFilesInput, fn func(*rds.DescribeDBLogFilesOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		logs := make([]*rds.DescribeDBLogFilesDetails, 0, n)
+		for _, id := range ids("log", start, n) {
+			logs = append(logs, &rds.DescribeDBLogFilesDetails{LogFileName: id})
+		}
+		return fn(&rds.DescribeDBLogFilesOutput{DescribeDBLogFiles: logs}, last)
+	})
+}
+
+type pagedIAM struct {
+	iamiface.IAMAPI
+	pager
+}
+
+func (f *pagedIAM) ListUsersPages(input *iam.ListUsersInput, fn func(*iam.ListUsersOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		users := make([]*iam.User, 0, n)
+		for _, id := range ids("user", start, n) {
+			users = append(users, &iam.User{UserName: id})
+		}
+		return fn(&iam.ListUsersOutput{Users: users}, last)
+	})
+}
+
+func (f *pagedIAM) ListAccessKeysPages(input *iam.ListAccessKeysInput, fn func(*iam.ListAccessKeysOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		keys := make([]*iam.AccessKeyMetadata, 0, n)
+		for _, id := range ids("AKIA", start, n) {
+			keys = append(keys, &iam.AccessKeyMetadata{AccessKeyId: id, UserName: input.UserName})
+		}
+		return fn(&iam.ListAccessKeysOutput{AccessKeyMetadata: keys}, last)
+	})
+}
+
+func (f *pagedIAM) GetAccessKeyLastUsed(input *iam.GetAccessKeyLastUsedInput) (*iam.GetAccessKeyLastUsedOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &iam.GetAccessKeyLastUsedOutput{AccessKeyLastUsed: &iam.AccessKeyLastUsed{}}, nil
+}
+
+type pagedS3 struct {
+	s3iface.S3API
+	pager
+}
+
+func (f *pagedS3) ListBuckets(*s3.ListBucketsInput) (*s3.ListBucketsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	buckets := make([]*s3.Bucket, 0)
+	for _, name := range ids("bucket", 0, f.total()) {
+		buckets = append(buckets, &s3.Bucket{Name: name})
+	}
+	return &s3.ListBucketsOutput{Buckets: buckets}, nil
+}
+
+func (f *pagedS3) GetBucketReplication(*s3.GetBucketReplicationInput) (*s3.GetBucketReplicationOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.GetBucketReplicationOutput{ReplicationConfiguration: &s3.ReplicationConfiguration{}}, nil
+}
+
+type pagedAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	pager
+}
+
+func (f *pagedAutoScaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		groups := make([]*autoscaling.Group, 0, n)
+		for _, name := range ids("asg", start, n) {
+			groups = append(groups, &autoscaling.Group{AutoScalingGroupName: name})
+		}
+		return fn(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: groups}, last)
+	})
+}
+
+func (f *pagedAutoScaling) DescribeLaunchConfigurationsPages(input *autoscaling.DescribeLaunchConfigurationsInput, fn func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	return f.serve(func(start, n int, last bool) bool {
+		configs := make([]*autoscaling.LaunchConfiguration, 0, n)
+		for _, name := range ids("lc", start, n) {
+			configs = append(configs, &autoscaling.LaunchConfiguration{LaunchConfigurationName: name})
+		}
+		return fn(&autoscaling.DescribeLaunchConfigurationsOutput{LaunchConfigurations: configs}, last)
+	})
+}
+
+type pagedSavingsPlans struct {
+	savingsplansiface.SavingsPlansAPI
+	pager
+}
+
+// DescribeSavingsPlans serves the pages using NextToken, since the SDK has no
+// Pages method for it.
+func (f *pagedSavingsPlans) DescribeSavingsPlans(input *savingsplans.DescribeSavingsPlansInput) (*savingsplans.DescribeSavingsPlansOutput, error) {
+	page, start := 0, 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(aws.StringValue(input.NextToken))
+	}
+	if page >= len(f.sizes) {
+		if f.err != nil {
+			return nil, f.err
+		}
+		return &savingsplans.DescribeSavingsPlansOutput{}, nil
+	}
+	for _, n := range f.sizes[:page] {
+		start += n
+	}
+
+	out := &savingsplans.DescribeSavingsPlansOutput{}
+	for _, id := range ids("sp", start, f.sizes[page]) {
+		out.SavingsPlans = append(out.SavingsPlans, &savingsplans.SavingsPlan{SavingsPlanId: id})
+	}
+	if page < len(f.sizes)-1 || f.err != nil {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func pagedInventory(p pager) *Inventory {
+	inv := NewInventory(&pagedEC2{pager: p}, &pagedRDS{pager: p}, &pagedS3{pager: p}, &pagedIAM{pager: p})
+	inv.SavingsPlans = &pagedSavingsPlans{pager: p}
+	inv.AutoScaling = &pagedAutoScaling{pager: p}
+	return inv
+}
+
+func TestAccessorPagination(t *testing.T) {
+	accessors := []struct {
+		op   string
+		call func(inv *Inventory) (interface{}, error)
+	}{
+		{"DescribeInstances", func(inv *Inventory) (interface{}, error) { return inv.Instances(nil) }},
+		{"DescribeInstances", func(inv *Inventory) (interface{}, error) {
+			return inv.RunningInstances(RunningInstancesOpts{IncludeSpot: true})
+		}},
+		{"DescribeReservedInstances", func(inv *Inventory) (interface{}, error) { return inv.ReservedInstances() }},
+		{"DescribeSpotInstanceRequests", func(inv *Inventory) (interface{}, error) { return inv.SpotInstanceRequests(nil) }},
+		{"DescribeSubnets", func(inv *Inventory) (interface{}, error) { return inv.Subnets() }},
+		{"DescribeVpcs", func(inv *Inventory) (interface{}, error) { return inv.VPCs() }},
+		{"DescribeNetworkInterfaces", func(inv *Inventory) (interface{}, error) { return inv.NetworkInterfaces() }},
+		{"DescribeAddresses", func(inv *Inventory) (interface{}, error) { return inv.Addresses() }},
+		{"DescribeSecurityGroups", func(inv *Inventory) (interface{}, error) { return inv.SecurityGroups() }},
+		{"DescribeVolumes", func(inv *Inventory) (interface{}, error) { return inv.Volumes() }},
+		{"DescribeSnapshots", func(inv *Inventory) (interface{}, error) { return inv.EBSSnapshots() }},
+		{"DescribeImages", func(inv *Inventory) (interface{}, error) { return inv.Images() }},
+		{"DescribeLaunchTemplateVersions", func(inv *Inventory) (interface{}, error) { return inv.LaunchTemplateVersions() }},
+		{"DescribeAutoScalingGroups", func(inv *Inventory) (interface{}, error) { return inv.AutoScalingGroups() }},
+		{"DescribeLaunchConfigurations", func(inv *Inventory) (interface{}, error) { return inv.LaunchConfigurations() }},
+		{"DescribeDBInstances", func(inv *Inventory) (interface{}, error) {
+			return inv.RunningDBInstances(RunningDBInstancesOpts{})
+		}},
+		{"DescribeDBClusters", func(inv *Inventory) (interface{}, error) { return inv.DBClusters() }},
+		{"DescribeReservedDBInstances", func(inv *Inventory) (interface{}, error) { return inv.ReservedDBInstances() }},
+		{"DescribeDBSnapshots", func(inv *Inventory) (interface{}, error) { return inv.DBSnapshots() }},
+		{"DescribeDBLogFiles", func(inv *Inventory) (interface{}, error) { return inv.DescribeDBLogFiles("db-1") }},
+		{"ListBuckets", func(inv *Inventory) (interface{}, error) { return inv.ListBuckets() }},
+		{"ListUsers", func(inv *Inventory) (interface{}, error) { return inv.IAMUsers() }},
+		{"ListAccessKeys", func(inv *Inventory) (interface{}, error) { return inv.IAMAccessKeysMeatadata("user-1") }},
+		{"DescribeSavingsPlans", func(inv *Inventory) (interface{}, error) { return inv.ActiveSavingsPlans() }},
+	}
+
+	errThrottled := awserr.New("Throttling", "Rate exceeded", nil)
+	cases := []struct {
+		name  string
+		pager pager
+		want  int
+	}{
+		{name: "no pages", pager: pager{}, want: 0},
+		{name: "single page", pager: pager{sizes: []int{3}}, want: 3},
+		{name: "several pages", pager: pager{sizes: []int{2, 0, 3}}, want: 5},
+		{name: "error before any page", pager: pager{err: errThrottled}},
+		{name: "error after partial pages", pager: pager{sizes: []int{2, 2}, err: errThrottled}},
+	}
+
+	for _, a := range accessors {
+		for _, c := range cases {
+			t.Run(a.op+"/"+c.name, func(t *testing.T) {
+				result, err := a.call(pagedInventory(c.pager))
+				if c.pager.err != nil {
+					// Partial results are never returned along with an error.
+					assert.Nil(t, result)
+					assert.True(t, errors.Is(err, errThrottled))
+					assert.Contains(t, err.Error(), a.op+": ")
+					return
+				}
+				assert.Nil(t, err)
+				assert.Len(t, result, c.want)
+			})
+		}
+	}
+}
+
+func TestSingleResourceErrors(t *testing.T) {
+	errDenied := awserr.New("AccessDenied", "Access Denied", nil)
+	inv := pagedInventory(pager{err: errDenied})
+
+	replication, err := inv.GetBucketReplication(&s3.Bucket{Name: aws.String("bucket-1")})
+	assert.Nil(t, replication)
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, err.Error(), "GetBucketReplication: ")
+
+	lastUsed, err := inv.IAMAccessKeyLastUsed("AKIA-1")
+	assert.Nil(t, lastUsed)
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, err.Error(), "GetAccessKeyLastUsed: ")
+
+	err = inv.CreateTags([]string{"i-1"}, "cost", "platform")
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, err.Error(), "CreateTags: ")
+
+	inv = pagedInventory(pager{})
+	replication, err = inv.GetBucketReplication(&s3.Bucket{Name: aws.String("bucket-1")})
+	assert.Nil(t, err)
+	assert.NotNil(t, replication)
+	assert.Nil(t, inv.CreateTags([]string{"i-1"}, "cost", "platform"))
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Defaults for NewPool.
+const (
+	DefaultConcurrency = 8
+	DefaultMaxRetries  = 8
+	DefaultBaseDelay   = 200 * time.Millisecond
+	DefaultMaxDelay    = 20 * time.Second
+)
+
+// Pool bounds how many per-resource API calls are made at once, e.g. a
+// GetBucketReplication for each bucket. It is safe to share between
+// goroutines, so a single pool bounds the calls made across all of the
+// accounts and regions being queried. Calls which are throttled are retried
+// with exponential backoff.
+type Pool struct {
+	// MaxRetries is how many times a throttled call is retried before its
+	// error is returned.
+	MaxRetries int
+	// BaseDelay is how long to wait before the first retry. It doubles with
+	// each retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	slots chan struct{}
+	sleep func(time.Duration)
+}
+
+// NewPool creates a pool which makes up to concurrency calls at once.
+func NewPool(concurrency int) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pool{
+		MaxRetries: DefaultMaxRetries,
+		BaseDelay:  DefaultBaseDelay,
+		MaxDelay:   DefaultMaxDelay,
+		slots:      make(chan struct{}, concurrency),
+		sleep:      time.Sleep,
+	}
+}
+
+// Do calls fn once a slot in the pool is free, retrying it while it is
+// throttled.
+func (p *Pool) Do(fn func() error) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || !IsThrottle(err) || attempt >= p.MaxRetries {
+			return err
+		}
+		p.sleep(p.backoff(attempt))
+	}
+}
+
+// backoff returns how long to wait before the given retry, with jitter so
+// that concurrent calls don't retry in lockstep.
+func (p *Pool) backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 && p.BaseDelay<<attempt < p.MaxDelay {
+		delay = p.BaseDelay << attempt
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// IsThrottle returns whether err is an AWS error which means the request was
+// throttled and should be retried later.
+func IsThrottle(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	// S3 uses SlowDown, which the SDK doesn't treat as a throttling code.
+	return request.IsErrorThrottle(aerr) || aerr.Code() == "SlowDown"
+}
+
+// Map calls fn with each of the items using the pool and returns the results
+// in the same order as the items. If any of the calls fail, the items which
+// haven't started yet are skipped and the error of the earliest item which
+// failed is returned.
+func Map[In, Out any](p *Pool, items []In, fn func(In) (Out, error)) ([]Out, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  bool
+		results = make([]Out, len(items))
+		errs    = make([]error, len(items))
+	)
+
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item In) {
+			defer wg.Done()
+			err := p.Do(func() error {
+				mu.Lock()
+				skip := failed
+				mu.Unlock()
+				if skip {
+					return errSkipped
+				}
+
+				result, err := fn(item)
+				if err != nil {
+					return err
+				}
+				results[i] = result
+				return nil
+			})
+			if err != nil && err != errSkipped {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+			errs[i] = err
+		}(i, item)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && err != errSkipped {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+var errSkipped = errors.New("skipped after an earlier call failed")
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPool(concurrency int) *Pool {
+	p := NewPool(concurrency)
+	p.sleep = func(time.Duration) {}
+	return p
+}
+
+func TestMapPreservesOrder(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	results, err := Map(testPool(8), items, func(i int) (string, error) {
+		// Finish out of order.
+		time.Sleep(time.Duration(100-i) * time.Microsecond)
+		return fmt.Sprint(i), nil
+	})
+	assert.Nil(t, err)
+	for i, r := range results {
+		assert.Equal(t, fmt.Sprint(i), r)
+	}
+}
+
+func TestMapIsBounded(t *testing.T) {
+	var running, max int32
+	_, err := Map(testPool(3), make([]int, 30), func(int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return 0, nil
+	})
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, max, int32(3))
+}
+
+func TestMapRetriesThrottling(t *testing.T) {
+	for _, code := range []string{"Throttling", "RequestLimitExceeded", "SlowDown"} {
+		t.Run(code, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := make(map[int]int)
+			results, err := Map(testPool(2), []int{0, 1}, func(i int) (int, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts[i]++
+				if attempts[i] < 3 {
+					return 0, fmt.Errorf("call %d: %w", i, awserr.New(code, "slow down", nil))
+				}
+				return i * 10, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, []int{0, 10}, results)
+			assert.Equal(t, map[int]int{0: 3, 1: 3}, attempts)
+		})
+	}
+}
+
+func TestMapGivesUpAfterMaxRetries(t *testing.T) {
+	p := testPool(1)
+	p.MaxRetries = 2
+	var attempts int32
+	_, err := Map(p, []int{0}, func(int) (int, error) {
+		atomic.AddInt32(&attempts, 1)
+		return 0, awserr.New("Throttling", "Rate exceeded", nil)
+	})
+	assert.True(t, IsThrottle(err))
+	assert.Equal(t, int32(3), attempts)
+}
+
+func TestMapStopsAfterError(t *testing.T) {
+	var calls int32
+	_, err := Map(testPool(1), []int{0, 1, 2, 3}, func(i int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		if i >= 1 {
+			return 0, fmt.Errorf("item %d failed", i)
+		}
+		return i, nil
+	})
+	assert.NotNil(t, err)
+	// Items after the first failure are skipped, and errors aren't retried.
+	assert.Less(t, atomic.LoadInt32(&calls), int32(4))
+}
+
+func TestBackoff(t *testing.T) {
+	p := NewPool(1)
+	p.BaseDelay = 100 * time.Millisecond
+	p.MaxDelay = time.Second
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.LessOrEqual(t, d, max)
+	}
+	assert.LessOrEqual(t, p.backoff(100), time.Second)
+}
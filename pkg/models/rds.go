@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -11,28 +12,20 @@ import (
 	"github.com/aws/aws-sdk-go/service/rds"
 )
 
-var rdsClient *rds.RDS
-
-// RDSClient sets the client to be used by the models.
-func RDSClient(client *rds.RDS) {
-	rdsClient = client
-}
-
 // ReservedDBInstances returns a slice of active reserved db instances. We
 // have to do client side filtering since the API doesn't support filters at
 // this time.
-func ReservedDBInstances() ([]*rds.ReservedDBInstance, error) {
+func (inv *Inventory) ReservedDBInstances() ([]*rds.ReservedDBInstance, error) {
 	ris := make([]*rds.ReservedDBInstance, 0)
 	params := &rds.DescribeReservedDBInstancesInput{}
 
-	err := rdsClient.DescribeReservedDBInstancesPages(params,
+	err := inv.RDS.DescribeReservedDBInstancesPages(params,
 		func(page *rds.DescribeReservedDBInstancesOutput, lastPage bool) bool {
 			ris = append(ris, page.ReservedDBInstances...)
 			return !lastPage
 		})
-
 	if err != nil {
-		return ris, err
+		return nil, opError("DescribeReservedDBInstances", err)
 	}
 
 	filtered := make([]*rds.ReservedDBInstance, 0)
@@ -41,53 +34,109 @@ func ReservedDBInstances() ([]*rds.ReservedDBInstance, error) {
 			filtered = append(filtered, ri)
 		}
 	}
-	return filtered, err
+	return filtered, nil
+}
+
+// DefaultBillableDBInstanceStatuses are the statuses of DB instances which
+// are billed, and so use reservations. Instances which are being modified or
+// upgraded still count, while stopped, creating and deleted ones don't.
+var DefaultBillableDBInstanceStatuses = []string{
+	"available",
+	"backing-up",
+	"configuring-enhanced-monitoring",
+	"configuring-iam-database-auth",
+	"configuring-log-exports",
+	"maintenance",
+	"modifying",
+	"rebooting",
+	"renaming",
+	"resetting-master-credentials",
+	"storage-optimization",
+	"upgrading",
+}
+
+// RunningDBInstancesOpts are options that can be passed to the running db
+// instances call.
+type RunningDBInstancesOpts struct {
+	// Statuses are the statuses of the instances to return. When empty,
+	// DefaultBillableDBInstanceStatuses is used.
+	Statuses []string
 }
 
 // RunningDBInstances returns a slice of running db instances.
-func RunningDBInstances() ([]*rds.DBInstance, error) {
+func (inv *Inventory) RunningDBInstances(opts RunningDBInstancesOpts) ([]*rds.DBInstance, error) {
 	instances := make([]*rds.DBInstance, 0)
 	params := &rds.DescribeDBInstancesInput{}
 
-	err := rdsClient.DescribeDBInstancesPages(params,
+	err := inv.RDS.DescribeDBInstancesPages(params,
 		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
 			instances = append(instances, page.DBInstances...)
 			return !lastPage
 		})
-
 	if err != nil {
-		return instances, err
+		return nil, opError("DescribeDBInstances", err)
+	}
+
+	statuses := opts.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultBillableDBInstanceStatuses
+	}
+	billable := make(map[string]bool)
+	for _, s := range statuses {
+		billable[s] = true
 	}
 
 	filtered := make([]*rds.DBInstance, 0)
 	for _, i := range instances {
-		status := aws.StringValue(i.DBInstanceStatus)
-		if status == "available" || status == "backing-up" {
+		if billable[aws.StringValue(i.DBInstanceStatus)] {
 			filtered = append(filtered, i)
 		}
 	}
-	return filtered, err
+	return filtered, nil
+}
+
+// DBClusters returns a slice of Aurora and Multi-AZ DB clusters, in every
+// status.
+func (inv *Inventory) DBClusters() ([]*rds.DBCluster, error) {
+	clusters := make([]*rds.DBCluster, 0)
+	params := &rds.DescribeDBClustersInput{}
+
+	err := inv.RDS.DescribeDBClustersPages(params,
+		func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, page.DBClusters...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeDBClusters", err)
+	}
+	return clusters, nil
 }
 
 // DBSnapshots returns a slice of RDS Snapshots.
-func DBSnapshots() ([]*rds.DBSnapshot, error) {
+func (inv *Inventory) DBSnapshots() ([]*rds.DBSnapshot, error) {
 	snapshots := make([]*rds.DBSnapshot, 0)
 	params := &rds.DescribeDBSnapshotsInput{}
 
-	err := rdsClient.DescribeDBSnapshotsPages(params,
+	err := inv.RDS.DescribeDBSnapshotsPages(params,
 		func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
 			snapshots = append(snapshots, page.DBSnapshots...)
 			return !lastPage
 		})
-	return snapshots, err
+	if err != nil {
+		return nil, opError("DescribeDBSnapshots", err)
+	}
+	return snapshots, nil
 }
 
 // GetRDSLogDownloadURL returns a signed request for the given DB Instance identifier
-// and filename
-func GetRDSLogDownloadURL(dbInstanceIdentifier string, fileName string) (*http.Request, error) {
-	sess := DefaultSession()
-	signer := v4.NewSigner(sess.Config.Credentials)
-	region := aws.StringValue(sess.Config.Region)
+// and filename. The inventory must have been created with Init since the
+// request is signed with the session's credentials.
+func (inv *Inventory) GetRDSLogDownloadURL(dbInstanceIdentifier string, fileName string) (*http.Request, error) {
+	if inv.session == nil {
+		return nil, errors.New("signing RDS log download URLs requires a session")
+	}
+	signer := v4.NewSigner(inv.session.Config.Credentials)
+	region := aws.StringValue(inv.session.Config.Region)
 	url := fmt.Sprintf(
 		"https://rds.%s.amazonaws.com/v13/downloadCompleteLogFile/%s/%s",
 		region,
@@ -95,23 +144,30 @@ func GetRDSLogDownloadURL(dbInstanceIdentifier string, fileName string) (*http.R
 		fileName,
 	)
 
-	request, _ := http.NewRequest("GET", url, nil)
-	_, err := signer.Presign(request, nil, endpoints.RdsServiceID, region, 1*time.Hour, time.Now())
-	return request, err
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := signer.Presign(request, nil, endpoints.RdsServiceID, region, 1*time.Hour, time.Now()); err != nil {
+		return nil, opError("DownloadCompleteDBLogFile", err)
+	}
+	return request, nil
 }
 
 // DescribeDBLogFiles returns details about the log files for a given DB Instance identifier
-func DescribeDBLogFiles(dbInstanceIdentifier string) ([]*rds.DescribeDBLogFilesDetails, error) {
+func (inv *Inventory) DescribeDBLogFiles(dbInstanceIdentifier string) ([]*rds.DescribeDBLogFilesDetails, error) {
 	params := &rds.DescribeDBLogFilesInput{
 		DBInstanceIdentifier: aws.String(dbInstanceIdentifier),
 	}
 	logFiles := make([]*rds.DescribeDBLogFilesDetails, 0)
 
-	err := rdsClient.DescribeDBLogFilesPages(params,
+	err := inv.RDS.DescribeDBLogFilesPages(params,
 		func(page *rds.DescribeDBLogFilesOutput, lastPage bool) bool {
 			logFiles = append(logFiles, page.DescribeDBLogFiles...)
 			return !lastPage
 		})
-
-	return logFiles, err
+	if err != nil {
+		return nil, opError("DescribeDBLogFiles", err)
+	}
+	return logFiles, nil
 }
@@ -1,35 +1,56 @@
 package models
 
 import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-var s3Client *s3.S3
-
-// S3Client sets the client to be used by the models.
-func S3Client(client *s3.S3) {
-	s3Client = client
-}
-
-// ListBuckets returns a list of buckets
-func ListBuckets() ([]*s3.Bucket, error) {
-	resp, err := s3Client.ListBuckets(&s3.ListBucketsInput{})
+// ListBuckets returns a list of buckets. The API returns all of them in a
+// single response.
+func (inv *Inventory) ListBuckets() ([]*s3.Bucket, error) {
+	resp, err := inv.S3.ListBuckets(&s3.ListBucketsInput{})
 	if err != nil {
-		return []*s3.Bucket{}, err
+		return nil, opError("ListBuckets", err)
 	}
-	return resp.Buckets, err
+	return resp.Buckets, nil
 }
 
 // GetBucketLocation gets the bucket's location
 // func GetBucketLocation(bucket *s3.Bucket) (*s3.GetBucketLocationOutput, error) {
 // 	input := &s3.GetBucketLocationInput{Bucket: bucket.Name}
-// 	output, err := s3Client.GetBucketLocation(input)
+// 	output, err := inv.S3.GetBucketLocation(input)
 // 	return output, err
 // }
 
 // GetBucketReplication returns the Replication status of the bucket
-func GetBucketReplication(bucket *s3.Bucket) (*s3.GetBucketReplicationOutput, error) {
+func (inv *Inventory) GetBucketReplication(bucket *s3.Bucket) (*s3.GetBucketReplicationOutput, error) {
 	input := &s3.GetBucketReplicationInput{Bucket: bucket.Name}
-	output, err := s3Client.GetBucketReplication(input)
-	return output, err
+	output, err := inv.S3.GetBucketReplication(input)
+	if err != nil {
+		return nil, opError("GetBucketReplication", err)
+	}
+	return output, nil
+}
+
+// GetBucketTagging returns the tags of the bucket, which are empty when it
+// has none.
+func (inv *Inventory) GetBucketTagging(bucket *s3.Bucket) ([]*s3.Tag, error) {
+	input := &s3.GetBucketTaggingInput{Bucket: bucket.Name}
+	output, err := inv.S3.GetBucketTagging(input)
+	if err != nil {
+		// Buckets without tags return an error rather than an empty tag set.
+		if isErrorCode(err, "NoSuchTagSet") {
+			return make([]*s3.Tag, 0), nil
+		}
+		return nil, opError("GetBucketTagging", err)
+	}
+	return output.TagSet, nil
+}
+
+// isErrorCode reports whether err is an AWS error with the given code.
+func isErrorCode(err error, code string) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == code
 }
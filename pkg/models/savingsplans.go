@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+)
+
+// ActiveSavingsPlans returns the active savings plans of the account. Savings
+// plans apply to the whole account, so they only need to be queried once per
+// account.
+func (inv *Inventory) ActiveSavingsPlans() ([]*savingsplans.SavingsPlan, error) {
+	plans := make([]*savingsplans.SavingsPlan, 0)
+	input := &savingsplans.DescribeSavingsPlansInput{
+		States: aws.StringSlice([]string{savingsplans.SavingsPlanStateActive}),
+	}
+
+	// The SDK doesn't have a Pages method for DescribeSavingsPlans.
+	for {
+		resp, err := inv.SavingsPlans.DescribeSavingsPlans(input)
+		if err != nil {
+			return nil, opError("DescribeSavingsPlans", err)
+		}
+		plans = append(plans, resp.SavingsPlans...)
+		if aws.StringValue(resp.NextToken) == "" {
+			return plans, nil
+		}
+		input.NextToken = resp.NextToken
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// AllRegions can be passed to Regions to query every region enabled for the
+// account.
+const AllRegions = "all"
+
+// Scope identifies where an inventory's resources live. Account is empty
+// unless the inventory was created for a specific account with InitAccounts.
+type Scope struct {
+	Account string
+	Region  string
+}
+
+func (s Scope) String() string {
+	switch {
+	case s.Account == "":
+		return s.Region
+	case s.Region == "":
+		return s.Account
+	}
+	return s.Account + "/" + s.Region
+}
+
+// Less orders scopes by account and then region.
+func (s Scope) Less(other Scope) bool {
+	if s.Account != other.Account {
+		return s.Account < other.Account
+	}
+	return s.Region < other.Region
+}
+
+// ScopeErrors holds the error of each scope that failed.
+type ScopeErrors map[Scope]error
+
+func (e ScopeErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, scope := range SortedScopes(e) {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", scope, e[scope]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// SortedScopes returns the keys of m in a stable order.
+func SortedScopes[T any](m map[Scope]T) []Scope {
+	scopes := make([]Scope, 0, len(m))
+	for s := range m {
+		scopes = append(scopes, s)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i].Less(scopes[j]) })
+	return scopes
+}
+
+// Regions parses a comma separated list of regions. An empty spec returns the
+// region of the session and "all" returns every region enabled for the
+// account, as reported by DescribeRegions.
+func Regions(s *session.Session, spec string) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return []string{aws.StringValue(s.Config.Region)}, nil
+	case AllRegions:
+		resp, err := ec2.New(s).DescribeRegions(&ec2.DescribeRegionsInput{})
+		if err != nil {
+			return nil, err
+		}
+		regions := make([]string, 0, len(resp.Regions))
+		for _, r := range resp.Regions {
+			regions = append(regions, aws.StringValue(r.RegionName))
+		}
+		sort.Strings(regions)
+		return regions, nil
+	}
+
+	regions := make([]string, 0)
+	for _, r := range strings.Split(spec, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			regions = append(regions, r)
+		}
+	}
+	return regions, nil
+}
+
+// InitRegions creates an Inventory for each of the regions using copies of
+// the given session.
+func InitRegions(s *session.Session, regions []string) []*Inventory {
+	invs := make([]*Inventory, len(regions))
+	for i, region := range regions {
+		invs[i] = Init(s.Copy(&aws.Config{Region: aws.String(region)}))
+	}
+	return invs
+}
+
+// Collect calls fn with each of the inventories concurrently and returns the
+// results keyed by the inventory's scope. A failure in one scope doesn't stop
+// the others; if any fail, a ScopeErrors is returned along with the results
+// that did succeed.
+func Collect[T any](invs []*Inventory, fn func(*Inventory) (T, error)) (map[Scope]T, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[Scope]T, len(invs))
+		errs    = make(ScopeErrors)
+	)
+
+	for _, inv := range invs {
+		wg.Add(1)
+		go func(inv *Inventory) {
+			defer wg.Done()
+			result, err := fn(inv)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[inv.Scope] = err
+				return
+			}
+			results[inv.Scope] = result
+		}(inv)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegions(t *testing.T) {
+	regions, err := Regions(nil, "us-east-1, us-west-2,,eu-west-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"us-east-1", "us-west-2", "eu-west-1"}, regions)
+}
+
+func TestCollect(t *testing.T) {
+	invs := make([]*Inventory, 0)
+	for _, region := range []string{"us-west-2", "us-east-1", "eu-west-1"} {
+		inv := NewInventory(nil, nil, nil, nil)
+		inv.Region = region
+		invs = append(invs, inv)
+	}
+
+	results, err := Collect(invs, func(inv *Inventory) (string, error) {
+		if inv.Region == "us-east-1" {
+			return "", errors.New("denied")
+		}
+		return inv.Region + "!", nil
+	})
+
+	assert.EqualError(t, err, "us-east-1: denied")
+	assert.Equal(t, map[Scope]string{
+		{Region: "us-west-2"}: "us-west-2!",
+		{Region: "eu-west-1"}: "eu-west-1!",
+	}, results)
+	assert.Equal(t, []Scope{{Region: "eu-west-1"}, {Region: "us-west-2"}}, SortedScopes(results))
+}
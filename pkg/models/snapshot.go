@@ -0,0 +1,461 @@
+package models
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+)
+
+// SnapshotVersion is the version of the snapshot file format written by this
+// package. It is incremented whenever a change would prevent older versions
+// from reading the file correctly, or a section is added to it.
+const SnapshotVersion = 9
+
+// snapshotSectionVersions holds the version which added each section of the
+// snapshot, by its JSON name. Older snapshots don't have the section, so
+// reading it as if there were no resources would give wrong results.
+var snapshotSectionVersions = map[string]int{
+	"savings_plans":         2,
+	"db_clusters":           3,
+	"volumes":               4,
+	"bucket_tags":           4,
+	"auto_scaling_groups":   5,
+	"ebs_snapshots":         6,
+	"images":                6,
+	"launch_configurations": 7,
+	"addresses":             8,
+	// Version 7 added the section, but only version 9 has the versions auto
+	// scaling groups pin.
+	"launch_template_versions": 9,
+}
+
+// hasSection reports whether snapshots of the version have the section.
+func hasSection(version int, section string) bool {
+	return version >= snapshotSectionVersions[section]
+}
+
+// Snapshot is an offline copy of the raw describe results the models use, so
+// audits can be rerun later without calling AWS.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	CapturedAt time.Time `json:"captured_at"`
+	// Regional holds the results of regional services for each scope.
+	Regional []*RegionalSnapshot `json:"regional"`
+	// Global holds the results of global services, such as IAM and S3, for
+	// each account.
+	Global []*GlobalSnapshot `json:"global"`
+}
+
+// RegionalSnapshot holds the describe results of EC2, auto scaling and RDS in a
+// scope.
+type RegionalSnapshot struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+
+	// Instances holds instances in every state, not just running ones, while
+	// ReservedInstances only holds active reservations.
+	Instances            []*ec2.Instance            `json:"instances"`
+	ReservedInstances    []*ec2.ReservedInstances   `json:"reserved_instances"`
+	SpotInstanceRequests []*ec2.SpotInstanceRequest `json:"spot_instance_requests"`
+	Subnets              []*ec2.Subnet              `json:"subnets"`
+	VPCs                 []*ec2.Vpc                 `json:"vpcs"`
+	NetworkInterfaces    []*ec2.NetworkInterface    `json:"network_interfaces"`
+	Addresses            []*ec2.Address             `json:"addresses"`
+	SecurityGroups       []*ec2.SecurityGroup       `json:"security_groups"`
+	Volumes              []*ec2.Volume              `json:"volumes"`
+
+	// EBSSnapshots and Images only hold the ones owned by the account.
+	EBSSnapshots []*ec2.Snapshot `json:"ebs_snapshots"`
+	Images       []*ec2.Image    `json:"images"`
+	// LaunchTemplateVersions only holds the latest and default versions and
+	// those which auto scaling groups pin.
+	LaunchTemplateVersions []*ec2.LaunchTemplateVersion `json:"launch_template_versions"`
+
+	AutoScalingGroups    []*autoscaling.Group               `json:"auto_scaling_groups"`
+	LaunchConfigurations []*autoscaling.LaunchConfiguration `json:"launch_configurations"`
+
+	// DBInstances and ReservedDBInstances hold all instances and reservations,
+	// regardless of their status.
+	DBInstances         []*rds.DBInstance         `json:"db_instances"`
+	DBClusters          []*rds.DBCluster          `json:"db_clusters"`
+	ReservedDBInstances []*rds.ReservedDBInstance `json:"reserved_db_instances"`
+	DBSnapshots         []*rds.DBSnapshot         `json:"db_snapshots"`
+}
+
+// GlobalSnapshot holds the describe results of IAM and S3 in an account.
+type GlobalSnapshot struct {
+	Account string `json:"account"`
+
+	Buckets []*s3.Bucket `json:"buckets"`
+	// BucketReplications is keyed by bucket name and only has entries for
+	// buckets with replication configured.
+	BucketReplications map[string]*s3.ReplicationConfiguration `json:"bucket_replications"`
+	// BucketTags is keyed by bucket name and only has entries for buckets
+	// with tags.
+	BucketTags map[string][]*s3.Tag `json:"bucket_tags"`
+
+	Users []*iam.User `json:"users"`
+	// AccessKeys is keyed by user name.
+	AccessKeys map[string][]*iam.AccessKeyMetadata `json:"access_keys"`
+	// AccessKeysLastUsed is keyed by access key ID.
+	AccessKeysLastUsed map[string]*iam.AccessKeyLastUsed `json:"access_keys_last_used"`
+
+	// SavingsPlans only holds active savings plans.
+	SavingsPlans []*savingsplans.SavingsPlan `json:"savings_plans"`
+}
+
+// Capture describes the resources of each of the inventories and returns them
+// as a snapshot. Global services are only captured once per account. Like
+// Collect, failures in one scope don't stop the others and are returned as
+// ScopeErrors along with the partial snapshot. The calls made for each bucket,
+// user and access key of an account share the pool.
+func Capture(pool *Pool, invs []*Inventory) (*Snapshot, error) {
+	snap := &Snapshot{
+		Version:    SnapshotVersion,
+		CapturedAt: time.Now().UTC(),
+		Regional:   make([]*RegionalSnapshot, 0),
+		Global:     make([]*GlobalSnapshot, 0),
+	}
+	errs := make(ScopeErrors)
+
+	regional, err := Collect(invs, (*Inventory).captureRegional)
+	mergeScopeErrors(errs, err)
+	for _, scope := range SortedScopes(regional) {
+		snap.Regional = append(snap.Regional, regional[scope])
+	}
+
+	global, err := Collect(PerAccount(invs), func(inv *Inventory) (*GlobalSnapshot, error) {
+		return inv.captureGlobal(pool)
+	})
+	mergeScopeErrors(errs, err)
+	for _, scope := range SortedScopes(global) {
+		snap.Global = append(snap.Global, global[scope])
+	}
+
+	if len(errs) > 0 {
+		return snap, errs
+	}
+	return snap, nil
+}
+
+func mergeScopeErrors(errs ScopeErrors, err error) {
+	if scopeErrs, ok := err.(ScopeErrors); ok {
+		for scope, err := range scopeErrs {
+			errs[scope] = err
+		}
+	}
+}
+
+func (inv *Inventory) captureRegional() (*RegionalSnapshot, error) {
+	snap := &RegionalSnapshot{Account: inv.Account, Region: inv.Region}
+
+	instances := make([]*ec2.Instance, 0)
+	err := inv.EC2.DescribeInstancesPages(&ec2.DescribeInstancesInput{},
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, r := range page.Reservations {
+				instances = append(instances, r.Instances...)
+			}
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeInstances", err)
+	}
+	snap.Instances = instances
+
+	if snap.ReservedInstances, err = inv.ReservedInstances(); err != nil {
+		return nil, err
+	}
+	if snap.SpotInstanceRequests, err = inv.SpotInstanceRequests(nil); err != nil {
+		return nil, err
+	}
+	if snap.Subnets, err = inv.Subnets(); err != nil {
+		return nil, err
+	}
+	if snap.VPCs, err = inv.VPCs(); err != nil {
+		return nil, err
+	}
+	if snap.NetworkInterfaces, err = inv.NetworkInterfaces(); err != nil {
+		return nil, err
+	}
+	if snap.Addresses, err = inv.Addresses(); err != nil {
+		return nil, err
+	}
+	if snap.SecurityGroups, err = inv.SecurityGroups(); err != nil {
+		return nil, err
+	}
+	if snap.Volumes, err = inv.Volumes(); err != nil {
+		return nil, err
+	}
+	if snap.EBSSnapshots, err = inv.EBSSnapshots(); err != nil {
+		return nil, err
+	}
+	if snap.Images, err = inv.Images(); err != nil {
+		return nil, err
+	}
+	if snap.LaunchTemplateVersions, err = inv.LaunchTemplateVersions(); err != nil {
+		return nil, err
+	}
+	if snap.AutoScalingGroups, err = inv.AutoScalingGroups(); err != nil {
+		return nil, err
+	}
+	if snap.LaunchConfigurations, err = inv.LaunchConfigurations(); err != nil {
+		return nil, err
+	}
+
+	snap.DBInstances = make([]*rds.DBInstance, 0)
+	err = inv.RDS.DescribeDBInstancesPages(&rds.DescribeDBInstancesInput{},
+		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+			snap.DBInstances = append(snap.DBInstances, page.DBInstances...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeDBInstances", err)
+	}
+	if snap.DBClusters, err = inv.DBClusters(); err != nil {
+		return nil, err
+	}
+	snap.ReservedDBInstances = make([]*rds.ReservedDBInstance, 0)
+	err = inv.RDS.DescribeReservedDBInstancesPages(&rds.DescribeReservedDBInstancesInput{},
+		func(page *rds.DescribeReservedDBInstancesOutput, lastPage bool) bool {
+			snap.ReservedDBInstances = append(snap.ReservedDBInstances, page.ReservedDBInstances...)
+			return !lastPage
+		})
+	if err != nil {
+		return nil, opError("DescribeReservedDBInstances", err)
+	}
+	if snap.DBSnapshots, err = inv.DBSnapshots(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (inv *Inventory) captureGlobal(pool *Pool) (*GlobalSnapshot, error) {
+	snap := &GlobalSnapshot{
+		Account:            inv.Account,
+		BucketReplications: make(map[string]*s3.ReplicationConfiguration),
+		BucketTags:         make(map[string][]*s3.Tag),
+		AccessKeys:         make(map[string][]*iam.AccessKeyMetadata),
+		AccessKeysLastUsed: make(map[string]*iam.AccessKeyLastUsed),
+	}
+
+	var err error
+	if snap.Buckets, err = inv.ListBuckets(); err != nil {
+		return nil, err
+	}
+	replications, err := Map(pool, snap.Buckets, func(bucket *s3.Bucket) (*s3.ReplicationConfiguration, error) {
+		replication, err := inv.GetBucketReplication(bucket)
+		if err != nil {
+			// Buckets without replication return an error rather than an
+			// empty configuration, like those without tags do.
+			if isErrorCode(err, "ReplicationConfigurationNotFoundError") {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return replication.ReplicationConfiguration, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags, err := Map(pool, snap.Buckets, inv.GetBucketTagging)
+	if err != nil {
+		return nil, err
+	}
+	for i, bucket := range snap.Buckets {
+		if replications[i] != nil {
+			snap.BucketReplications[aws.StringValue(bucket.Name)] = replications[i]
+		}
+		if len(tags[i]) > 0 {
+			snap.BucketTags[aws.StringValue(bucket.Name)] = tags[i]
+		}
+	}
+
+	if snap.Users, err = inv.IAMUsers(); err != nil {
+		return nil, err
+	}
+	userKeys, err := Map(pool, snap.Users, func(user *iam.User) ([]*iam.AccessKeyMetadata, error) {
+		return inv.IAMAccessKeysMeatadata(aws.StringValue(user.UserName))
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*iam.AccessKeyMetadata, 0)
+	for i, user := range snap.Users {
+		snap.AccessKeys[aws.StringValue(user.UserName)] = userKeys[i]
+		keys = append(keys, userKeys[i]...)
+	}
+
+	lastUsed, err := Map(pool, keys, func(key *iam.AccessKeyMetadata) (*iam.AccessKeyLastUsed, error) {
+		resp, err := inv.IAMAccessKeyLastUsed(aws.StringValue(key.AccessKeyId))
+		if err != nil {
+			return nil, err
+		}
+		return resp.AccessKeyLastUsed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		snap.AccessKeysLastUsed[aws.StringValue(key.AccessKeyId)] = lastUsed[i]
+	}
+
+	if snap.SavingsPlans, err = inv.ActiveSavingsPlans(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Scopes returns the scopes captured in the snapshot. Global results are
+// scoped to their account, without a region.
+func (snap *Snapshot) Scopes() []Scope {
+	scopes := make([]Scope, 0, len(snap.Regional)+len(snap.Global))
+	for _, r := range snap.Regional {
+		scopes = append(scopes, Scope{Account: r.Account, Region: r.Region})
+	}
+	for _, g := range snap.Global {
+		scopes = append(scopes, Scope{Account: g.Account})
+	}
+	return scopes
+}
+
+// Filter returns a copy of the snapshot with only the given scopes, as
+// returned by Scopes.
+func (snap *Snapshot) Filter(scopes []Scope) *Snapshot {
+	keep := make(map[Scope]bool)
+	for _, scope := range scopes {
+		keep[scope] = true
+	}
+
+	filtered := &Snapshot{
+		Version:    snap.Version,
+		CapturedAt: snap.CapturedAt,
+		Regional:   make([]*RegionalSnapshot, 0),
+		Global:     make([]*GlobalSnapshot, 0),
+	}
+	for _, r := range snap.Regional {
+		if keep[Scope{Account: r.Account, Region: r.Region}] {
+			filtered.Regional = append(filtered.Regional, r)
+		}
+	}
+	for _, g := range snap.Global {
+		if keep[Scope{Account: g.Account}] {
+			filtered.Global = append(filtered.Global, g)
+		}
+	}
+	return filtered
+}
+
+// Inventories returns an inventory for each regional scope in the snapshot
+// which answers queries from the snapshot instead of calling AWS. Each one
+// also answers IAM and S3 queries with its account's global results. Queries
+// for sections the snapshot's version doesn't have fail.
+func (snap *Snapshot) Inventories() []*Inventory {
+	global := make(map[string]*GlobalSnapshot)
+	for _, g := range snap.Global {
+		global[g.Account] = g
+	}
+
+	invs := make([]*Inventory, 0, len(snap.Regional))
+	for _, r := range snap.Regional {
+		g, ok := global[r.Account]
+		if !ok {
+			g = &GlobalSnapshot{Account: r.Account}
+		}
+		inv := NewInventory(
+			&offlineEC2{snap: r, version: snap.Version},
+			&offlineRDS{snap: r, version: snap.Version},
+			&offlineS3{snap: g, version: snap.Version},
+			&offlineIAM{snap: g},
+		)
+		inv.SavingsPlans = &offlineSavingsPlans{snap: g, version: snap.Version}
+		inv.AutoScaling = &offlineAutoScaling{snap: r, version: snap.Version}
+		inv.Account = r.Account
+		inv.Region = r.Region
+		invs = append(invs, inv)
+	}
+	return invs
+}
+
+// Write writes the snapshot as JSON, gzipping it if the path ends in ".gz".
+func (snap *Snapshot) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// The gzip writer and file are closed explicitly, as failing to flush
+	// either would leave a truncated snapshot behind.
+	var gw *gzip.Writer
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		gw = gzip.NewWriter(f)
+		w = gw
+	}
+	err = json.NewEncoder(w).Encode(snap)
+	if gw != nil {
+		if closeErr := gw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadSnapshot reads a snapshot written by Write.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	snap := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snap); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", path, err)
+	}
+	if snap.Version < 1 || snap.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has unsupported version %d", path, snap.Version)
+	}
+	return snap, nil
+}
+
+// errNotInSnapshot is returned by the offline clients for data which isn't
+// captured in snapshots.
+func errNotInSnapshot(operation string) error {
+	return awserr.New("NotInSnapshot", operation+" is not available from a snapshot", nil)
+}
+
+// requireSection returns an error for an operation answered from a section
+// which snapshots of the version don't have.
+func requireSection(version int, section, operation string) error {
+	if hasSection(version, section) {
+		return nil
+	}
+	return awserr.New("NotInSnapshot",
+		fmt.Sprintf("%s is not available from a version %d snapshot, capture a new one", operation, version), nil)
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		Version: SnapshotVersion,
+		Regional: []*RegionalSnapshot{{
+			Account: "111111111111",
+			Region:  "us-east-1",
+			Instances: []*ec2.Instance{
+				makeInstance("i-1", "running", ""),
+				makeInstance("i-2", "stopped", ""),
+			},
+			ReservedInstances:    []*ec2.ReservedInstances{{ReservedInstancesId: aws.String("ri-1")}},
+			SpotInstanceRequests: []*ec2.SpotInstanceRequest{{SpotInstanceRequestId: aws.String("sir-1")}},
+			Subnets:              []*ec2.Subnet{{SubnetId: aws.String("subnet-1")}},
+			VPCs:                 []*ec2.Vpc{{VpcId: aws.String("vpc-1")}},
+			NetworkInterfaces:    []*ec2.NetworkInterface{{NetworkInterfaceId: aws.String("eni-1")}},
+			Addresses:            []*ec2.Address{{AllocationId: aws.String("eipalloc-1")}},
+			SecurityGroups:       []*ec2.SecurityGroup{{GroupId: aws.String("sg-1")}},
+			Volumes:              []*ec2.Volume{{VolumeId: aws.String("vol-1")}},
+			EBSSnapshots:         []*ec2.Snapshot{{SnapshotId: aws.String("snap-1"), VolumeId: aws.String("vol-1")}},
+			Images:               []*ec2.Image{{ImageId: aws.String("ami-1")}},
+			LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{{
+				LaunchTemplateId: aws.String("lt-1"),
+				VersionNumber:    aws.Int64(1),
+			}},
+			AutoScalingGroups:    []*autoscaling.Group{{AutoScalingGroupName: aws.String("asg-1")}},
+			LaunchConfigurations: []*autoscaling.LaunchConfiguration{{LaunchConfigurationName: aws.String("lc-1")}},
+			DBInstances: []*rds.DBInstance{
+				{DBInstanceIdentifier: aws.String("db-1"), DBInstanceStatus: aws.String("available")},
+				{DBInstanceIdentifier: aws.String("db-2"), DBInstanceStatus: aws.String("stopped")},
+			},
+			DBClusters: []*rds.DBCluster{{DBClusterIdentifier: aws.String("cluster-1")}},
+			ReservedDBInstances: []*rds.ReservedDBInstance{
+				{ReservedDBInstanceId: aws.String("rdsri-1"), State: aws.String("active")},
+				{ReservedDBInstanceId: aws.String("rdsri-2"), State: aws.String("retired")},
+			},
+			DBSnapshots: []*rds.DBSnapshot{{DBSnapshotIdentifier: aws.String("snap-1")}},
+		}},
+		Global: []*GlobalSnapshot{{
+			Account: "111111111111",
+			Buckets: []*s3.Bucket{{Name: aws.String("replicated")}, {Name: aws.String("local")}},
+			BucketReplications: map[string]*s3.ReplicationConfiguration{
+				"replicated": {Role: aws.String("arn:aws:iam::111111111111:role/replication")},
+			},
+			BucketTags: map[string][]*s3.Tag{
+				"replicated": {{Key: aws.String("env"), Value: aws.String("prod")}},
+			},
+			Users: []*iam.User{{UserName: aws.String("alice")}},
+			AccessKeys: map[string][]*iam.AccessKeyMetadata{
+				"alice": {{AccessKeyId: aws.String("AKIA1"), UserName: aws.String("alice")}},
+			},
+			AccessKeysLastUsed: map[string]*iam.AccessKeyLastUsed{
+				"AKIA1": {ServiceName: aws.String("s3")},
+			},
+			SavingsPlans: []*savingsplans.SavingsPlan{{
+				SavingsPlanId:   aws.String("sp-1"),
+				SavingsPlanType: aws.String(savingsplans.SavingsPlanTypeCompute),
+				Commitment:      aws.String("1.5"),
+			}},
+		}},
+	}
+}
+
+func TestSnapshotInventories(t *testing.T) {
+	invs := testSnapshot().Inventories()
+	assert.Len(t, invs, 1)
+	inv := invs[0]
+	assert.Equal(t, Scope{Account: "111111111111", Region: "us-east-1"}, inv.Scope)
+
+	running, err := inv.RunningInstances(RunningInstancesOpts{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1"}, instanceIDs(running))
+	instances, err := inv.Instances([]string{"i-2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-2"}, instanceIDs(instances))
+
+	dbs, err := inv.RunningDBInstances(RunningDBInstancesOpts{})
+	assert.Nil(t, err)
+	assert.Len(t, dbs, 1)
+	clusters, err := inv.DBClusters()
+	assert.Nil(t, err)
+	assert.Len(t, clusters, 1)
+	ris, err := inv.ReservedDBInstances()
+	assert.Nil(t, err)
+	assert.Len(t, ris, 1)
+
+	volumes, err := inv.Volumes()
+	assert.Nil(t, err)
+	assert.Len(t, volumes, 1)
+	addresses, err := inv.Addresses()
+	assert.Nil(t, err)
+	assert.Len(t, addresses, 1)
+	snapshots, err := inv.EBSSnapshots()
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+	images, err := inv.Images()
+	assert.Nil(t, err)
+	assert.Len(t, images, 1)
+	versions, err := inv.LaunchTemplateVersions()
+	assert.Nil(t, err)
+	assert.Len(t, versions, 1)
+	groups, err := inv.AutoScalingGroups()
+	assert.Nil(t, err)
+	assert.Len(t, groups, 1)
+	configs, err := inv.LaunchConfigurations()
+	assert.Nil(t, err)
+	assert.Len(t, configs, 1)
+	tags, err := inv.GetBucketTagging(&s3.Bucket{Name: aws.String("local")})
+	assert.Nil(t, err)
+	assert.Empty(t, tags)
+	tags, err = inv.GetBucketTagging(&s3.Bucket{Name: aws.String("replicated")})
+	assert.Nil(t, err)
+	assert.Len(t, tags, 1)
+
+	_, err = inv.GetBucketReplication(&s3.Bucket{Name: aws.String("local")})
+	assert.NotNil(t, err)
+	replication, err := inv.GetBucketReplication(&s3.Bucket{Name: aws.String("replicated")})
+	assert.Nil(t, err)
+	assert.NotNil(t, replication.ReplicationConfiguration)
+
+	keys, err := inv.IAMAccessKeysMeatadata("alice")
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+
+	_, err = inv.DescribeDBLogFiles("db-1")
+	assert.NotNil(t, err)
+	assert.NotNil(t, inv.CreateTags([]string{"i-1"}, "cost", "platform"))
+}
+
+// deniedS3 denies reading the replication of bucket and answers every other
+// call with the wrapped client.
+type deniedS3 struct {
+	s3iface.S3API
+	bucket string
+}
+
+func (f *deniedS3) GetBucketReplication(input *s3.GetBucketReplicationInput) (*s3.GetBucketReplicationOutput, error) {
+	if aws.StringValue(input.Bucket) == f.bucket {
+		return nil, awserr.New("AccessDenied", "Access Denied", nil)
+	}
+	return f.S3API.GetBucketReplication(input)
+}
+
+func TestCaptureBucketErrors(t *testing.T) {
+	inv := testSnapshot().Inventories()[0]
+
+	// Buckets without replication or tags are captured without them.
+	global, err := inv.captureGlobal(NewPool(1))
+	assert.Nil(t, err)
+	assert.Len(t, global.Buckets, 2)
+	assert.NotContains(t, global.BucketReplications, "local")
+	assert.NotContains(t, global.BucketTags, "local")
+
+	// Any other error fails the capture rather than losing the replication.
+	inv.S3 = &deniedS3{S3API: inv.S3, bucket: "replicated"}
+	_, err = inv.captureGlobal(NewPool(1))
+	assert.ErrorContains(t, err, "GetBucketReplication: AccessDenied")
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, name := range []string{"inventory.json", "inventory.json.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			assert.Nil(t, testSnapshot().Write(path))
+
+			loaded, err := LoadSnapshot(path)
+			assert.Nil(t, err)
+
+			// Capturing from the loaded snapshot should reproduce it.
+			captured, err := Capture(NewPool(4), loaded.Inventories())
+			assert.Nil(t, err)
+			captured.CapturedAt = loaded.CapturedAt
+			assert.Equal(t, loaded, captured)
+		})
+	}
+}
+
+func TestLoadSnapshotRejectsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	snap := testSnapshot()
+	snap.Version = SnapshotVersion + 1
+	assert.Nil(t, snap.Write(path))
+
+	_, err := LoadSnapshot(path)
+	assert.NotNil(t, err)
+}
+
+func TestLoadSnapshotFlagsMissingSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	snap := testSnapshot()
+	snap.Version = 3
+	assert.Nil(t, snap.Write(path))
+
+	loaded, err := LoadSnapshot(path)
+	assert.Nil(t, err)
+	inv := loaded.Inventories()[0]
+
+	// Version 3 has DB clusters, but not volumes, which would otherwise look
+	// like there were none.
+	_, err = inv.DBClusters()
+	assert.Nil(t, err)
+	_, err = inv.Volumes()
+	assert.EqualError(t, err,
+		"DescribeVolumes: NotInSnapshot: DescribeVolumes is not available from a version 3 snapshot, capture a new one")
+	_, err = inv.GetBucketTagging(&s3.Bucket{Name: aws.String("replicated")})
+	assert.ErrorContains(t, err, "not available from a version 3 snapshot")
+}
@@ -0,0 +1,95 @@
+// Package policy lets audits report findings with a severity, so commands can
+// fail when findings at or above a threshold exist.
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jonstacks/aws/pkg/models"
+)
+
+// Severity is how important a finding is. Higher severities are more
+// important.
+type Severity int
+
+// The severities, from least to most important.
+const (
+	Info Severity = iota + 1
+	Low
+	Medium
+	High
+	Critical
+)
+
+// Severities are the names of the severities, from least to most important.
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < Info || s > Critical {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return Severities[s-1]
+}
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, s := range Severities {
+		if strings.EqualFold(name, s) {
+			return Severity(i + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, must be one of: %s", name, strings.Join(Severities, ", "))
+}
+
+// Finding is an issue an audit found with a resource.
+type Finding struct {
+	Scope    models.Scope
+	Severity Severity
+	// Resource identifies the resource, e.g. an instance or subnet ID.
+	Resource string
+	Message  string
+}
+
+func (f Finding) String() string {
+	resource := f.Resource
+	if scope := f.Scope.String(); scope != "" {
+		resource = scope + " " + resource
+	}
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, resource, f.Message)
+}
+
+// Auditor is implemented by views which report findings.
+type Auditor interface {
+	Findings() []Finding
+}
+
+// Violation is the error returned when there are findings at or above the
+// threshold.
+type Violation struct {
+	Threshold Severity
+	Findings  []Finding
+}
+
+func (v *Violation) Error() string {
+	noun := "findings"
+	if len(v.Findings) == 1 {
+		noun = "finding"
+	}
+	return fmt.Sprintf("%d %s at or above %s", len(v.Findings), noun, v.Threshold)
+}
+
+// Evaluate returns a *Violation with the findings at or above the threshold,
+// or nil if there aren't any.
+func Evaluate(findings []Finding, threshold Severity) error {
+	failed := make([]Finding, 0)
+	for _, f := range findings {
+		if f.Severity >= threshold {
+			failed = append(failed, f)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &Violation{Threshold: threshold, Findings: failed}
+}
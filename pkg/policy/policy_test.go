@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for i, name := range Severities {
+		s, err := ParseSeverity(name)
+		assert.Nil(t, err)
+		assert.Equal(t, Severity(i+1), s)
+		assert.Equal(t, name, s.String())
+	}
+
+	s, err := ParseSeverity("HIGH")
+	assert.Nil(t, err)
+	assert.Equal(t, High, s)
+
+	_, err = ParseSeverity("urgent")
+	assert.EqualError(t, err, `unknown severity "urgent", must be one of: info, low, medium, high, critical`)
+}
+
+func TestEvaluate(t *testing.T) {
+	findings := []Finding{
+		{Severity: Low, Resource: "subnet-1", Message: "empty"},
+		{Scope: models.Scope{Region: "us-east-1"}, Severity: High, Resource: "i-1", Message: "untagged"},
+	}
+
+	assert.Nil(t, Evaluate(findings, Critical))
+	assert.Nil(t, Evaluate(nil, Info))
+
+	err := Evaluate(findings, Medium)
+	assert.EqualError(t, err, "1 finding at or above medium")
+	violation := err.(*Violation)
+	assert.Equal(t, "[high] us-east-1 i-1: untagged", violation.Findings[0].String())
+
+	assert.EqualError(t, Evaluate(findings, Low), "2 findings at or above low")
+}
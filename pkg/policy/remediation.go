@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jonstacks/aws/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// The resources a missing tag can be inherited from.
+const (
+	InheritVPC              = "vpc"
+	InheritSubnet           = "subnet"
+	InheritAutoScalingGroup = "asg"
+)
+
+// InheritSources are the resources a missing tag can be inherited from.
+var InheritSources = []string{InheritVPC, InheritSubnet, InheritAutoScalingGroup}
+
+// TagRemedy is where the value of a missing tag comes from.
+type TagRemedy struct {
+	Key string `yaml:"key"`
+	// Inherit are the resources the value is inherited from, in order of
+	// preference. Volumes inherit from the ones of the instance they're
+	// attached to.
+	Inherit []string `yaml:"inherit,omitempty"`
+	// Default is the value used when none of the resources has the tag.
+	Default string `yaml:"default,omitempty"`
+}
+
+// TagRemediation maps the keys of missing tags to their remedies, usually
+// loaded from a file.
+type TagRemediation struct {
+	Tags []*TagRemedy `yaml:"tags"`
+}
+
+// LoadTagRemediation reads a tag remediation from a YAML or JSON file.
+func LoadTagRemediation(path string) (*TagRemediation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &TagRemediation{}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("reading tag remediation %s: %w", path, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("reading tag remediation %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Validate checks a remediation which wasn't loaded from a file.
+func (r *TagRemediation) Validate() error {
+	if len(r.Tags) == 0 {
+		return errors.New("the remediation has no tags")
+	}
+	keys := make(map[string]bool)
+	for _, remedy := range r.Tags {
+		if remedy.Key == "" {
+			return errors.New("a tag has no key")
+		}
+		if keys[remedy.Key] {
+			return fmt.Errorf("tag %s is listed more than once", remedy.Key)
+		}
+		keys[remedy.Key] = true
+		if len(remedy.Inherit) == 0 && remedy.Default == "" {
+			return fmt.Errorf("tag %s: either inherit or default has to be set", remedy.Key)
+		}
+		for _, source := range remedy.Inherit {
+			if !utils.StringSliceContains(InheritSources, source) {
+				return fmt.Errorf("tag %s: unknown source %q, must be one of: %s",
+					remedy.Key, source, strings.Join(InheritSources, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// Remedy returns the remedy for a missing tag, or nil if there is none.
+func (r *TagRemediation) Remedy(key string) *TagRemedy {
+	for _, remedy := range r.Tags {
+		if remedy.Key == key {
+			return remedy
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestTagRemediation(t *testing.T, remediation string) (*TagRemediation, error) {
+	path := filepath.Join(t.TempDir(), "remediation.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(remediation), 0o644))
+	return LoadTagRemediation(path)
+}
+
+func TestLoadTagRemediation(t *testing.T) {
+	r, err := loadTestTagRemediation(t, `
+tags:
+  - key: cost
+    inherit: [asg, subnet, vpc]
+    default: unallocated
+  - key: env
+    inherit: [vpc]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, &TagRemedy{
+		Key:     "cost",
+		Inherit: []string{InheritAutoScalingGroup, InheritSubnet, InheritVPC},
+		Default: "unallocated",
+	}, r.Remedy("cost"))
+	assert.Equal(t, "env", r.Remedy("env").Key)
+	assert.Nil(t, r.Remedy("owner"))
+}
+
+func TestLoadTagRemediationInvalid(t *testing.T) {
+	cases := map[string]string{
+		"tags: [":              "reading tag remediation",
+		"tags: []":             "the remediation has no tags",
+		"tags: [{default: x}]": "a tag has no key",
+		"tags: [{key: cost}]":  "tag cost: either inherit or default has to be set",
+		"tags: [{key: cost, inherit: [account]}]":            `tag cost: unknown source "account"`,
+		"tags: [{key: a, default: x}, {key: a, default: y}]": "tag a is listed more than once",
+	}
+	for remediation, want := range cases {
+		t.Run(remediation, func(t *testing.T) {
+			_, err := loadTestTagRemediation(t, remediation)
+			assert.ErrorContains(t, err, want)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jonstacks/aws/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// The kinds of resources a tag policy can be evaluated against. They are the
+// same as the kinds of resources in a drift.
+const (
+	TagInstance      = "instance"
+	TagVolume        = "volume"
+	TagDBInstance    = "db_instance"
+	TagBucket        = "bucket"
+	TagSecurityGroup = "security_group"
+)
+
+// TagResourceKinds are the kinds of resources a tag policy can be evaluated
+// against.
+var TagResourceKinds = []string{TagInstance, TagVolume, TagDBInstance, TagBucket, TagSecurityGroup}
+
+// TagRule is a requirement on the value of a tag key. A tag with an empty
+// value is treated as missing.
+type TagRule struct {
+	// Name describes the rule in findings. It defaults to a description of
+	// the requirement, e.g. `env in (prod, staging)`.
+	Name string `yaml:"name,omitempty"`
+	Key  string `yaml:"key"`
+	// Values are the allowed values. Any value is allowed when both Values
+	// and Pattern are empty.
+	Values []string `yaml:"values,omitempty"`
+	// Pattern is a regular expression the whole value has to match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// Optional rules only check the value when the tag is set.
+	Optional bool `yaml:"optional,omitempty"`
+	// Resources are the kinds of resources the rule applies to, or every
+	// kind when empty.
+	Resources []string `yaml:"resources,omitempty"`
+	// Severity of the findings, which defaults to medium.
+	Severity string `yaml:"severity,omitempty"`
+
+	pattern  *regexp.Regexp
+	severity Severity
+}
+
+// compile validates the rule and prepares it for evaluation.
+func (r *TagRule) compile() error {
+	if r.Key == "" {
+		return errors.New("a rule has no key")
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("rule for %s: %w", r.Key, err)
+		}
+		r.pattern = pattern
+	}
+	for _, kind := range r.Resources {
+		if !utils.StringSliceContains(TagResourceKinds, kind) {
+			return fmt.Errorf("rule for %s: unknown resource %q, must be one of: %s",
+				r.Key, kind, strings.Join(TagResourceKinds, ", "))
+		}
+	}
+	r.severity = Medium
+	if r.Severity != "" {
+		severity, err := ParseSeverity(r.Severity)
+		if err != nil {
+			return fmt.Errorf("rule for %s: %w", r.Key, err)
+		}
+		r.severity = severity
+	}
+	return nil
+}
+
+func (r *TagRule) String() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case len(r.Values) > 0:
+		return fmt.Sprintf("%s in (%s)", r.Key, strings.Join(r.Values, ", "))
+	case r.Pattern != "":
+		return fmt.Sprintf("%s matches %s", r.Key, r.Pattern)
+	}
+	return fmt.Sprintf("%s is set", r.Key)
+}
+
+// Level returns the severity of the rule's findings.
+func (r *TagRule) Level() Severity {
+	if r.severity == 0 {
+		return Medium
+	}
+	return r.severity
+}
+
+// AppliesTo returns whether the rule applies to the kind of resource.
+func (r *TagRule) AppliesTo(kind string) bool {
+	return len(r.Resources) == 0 || utils.StringSliceContains(r.Resources, kind)
+}
+
+// Check returns the violation of the rule by the tags, or nil if they comply
+// with it.
+func (r *TagRule) Check(tags map[string]string) *TagViolation {
+	value := tags[r.Key]
+	if value == "" {
+		if r.Optional {
+			return nil
+		}
+		return &TagViolation{Rule: r, Missing: true}
+	}
+	if len(r.Values) > 0 && !utils.StringSliceContains(r.Values, value) {
+		return &TagViolation{Rule: r, Value: value}
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return &TagViolation{Rule: r, Value: value}
+	}
+	return nil
+}
+
+// TagViolation is a tag which is missing, or whose value isn't allowed by a
+// rule.
+type TagViolation struct {
+	Rule    *TagRule
+	Missing bool
+	Value   string
+}
+
+// Problem returns "missing" or "invalid".
+func (v *TagViolation) Problem() string {
+	if v.Missing {
+		return "missing"
+	}
+	return "invalid"
+}
+
+func (v *TagViolation) String() string {
+	if v.Missing {
+		return fmt.Sprintf("tag %q is missing (%s)", v.Rule.Key, v.Rule)
+	}
+	return fmt.Sprintf("tag %q has invalid value %q (%s)", v.Rule.Key, v.Value, v.Rule)
+}
+
+// TagPolicy is a set of rules resources have to be tagged by, usually loaded
+// from a file.
+type TagPolicy struct {
+	Rules []*TagRule `yaml:"rules"`
+}
+
+// LoadTagPolicy reads a tag policy from a YAML or JSON file.
+func LoadTagPolicy(path string) (*TagPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &TagPolicy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("reading tag policy %s: %w", path, err)
+	}
+	if err := p.Compile(); err != nil {
+		return nil, fmt.Errorf("reading tag policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Compile validates the rules of a policy which wasn't loaded from a file.
+func (p *TagPolicy) Compile() error {
+	if len(p.Rules) == 0 {
+		return errors.New("the policy has no rules")
+	}
+	for _, r := range p.Rules {
+		if err := r.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the violations of the rules which apply to the kind of
+// resource by its tags.
+func (p *TagPolicy) Evaluate(kind string, tags map[string]string) []*TagViolation {
+	violations := make([]*TagViolation, 0)
+	for _, r := range p.Rules {
+		if !r.AppliesTo(kind) {
+			continue
+		}
+		if v := r.Check(tags); v != nil {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tagPolicy = `
+rules:
+  - key: env
+    values: [prod, staging, dev]
+    severity: high
+  - key: owner
+    pattern: '[^@\s]+@example\.com'
+  - name: cost centers are numeric
+    key: cost-center
+    pattern: '[0-9]+'
+    optional: true
+    resources: [instance, volume]
+`
+
+func loadTestTagPolicy(t *testing.T, policy string) (*TagPolicy, error) {
+	path := filepath.Join(t.TempDir(), "tags.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(policy), 0o644))
+	return LoadTagPolicy(path)
+}
+
+func TestTagPolicyEvaluate(t *testing.T) {
+	p, err := loadTestTagPolicy(t, tagPolicy)
+	assert.NoError(t, err)
+
+	assert.Empty(t, p.Evaluate(TagInstance, map[string]string{
+		"env":   "prod",
+		"owner": "alice@example.com",
+	}))
+
+	violations := p.Evaluate(TagInstance, map[string]string{
+		"env":         "qa",
+		"owner":       "",
+		"cost-center": "marketing",
+	})
+	assert.Len(t, violations, 3)
+	assert.Equal(t, `tag "env" has invalid value "qa" (env in (prod, staging, dev))`, violations[0].String())
+	assert.Equal(t, High, violations[0].Rule.Level())
+	assert.Equal(t, "invalid", violations[0].Problem())
+	assert.Equal(t, `tag "owner" is missing (owner matches [^@\s]+@example\.com)`, violations[1].String())
+	assert.Equal(t, "missing", violations[1].Problem())
+	assert.Equal(t, Medium, violations[1].Rule.Level())
+	assert.Equal(t, `tag "cost-center" has invalid value "marketing" (cost centers are numeric)`, violations[2].String())
+
+	// The pattern has to match the whole value.
+	violations = p.Evaluate(TagInstance, map[string]string{"env": "dev", "owner": "alice@example.com.evil"})
+	assert.Len(t, violations, 1)
+
+	// The cost center rule only applies to instances and volumes.
+	violations = p.Evaluate(TagBucket, map[string]string{"env": "dev", "owner": "alice@example.com", "cost-center": "x"})
+	assert.Empty(t, violations)
+}
+
+func TestLoadTagPolicyInvalid(t *testing.T) {
+	cases := map[string]string{
+		"rules: [":                                 "reading tag policy",
+		"rules: []":                                "the policy has no rules",
+		"rules: [{values: [a]}]":                   "a rule has no key",
+		"rules: [{key: env, pattern: '('}]":        "rule for env: error parsing regexp",
+		"rules: [{key: env, resources: [lambda]}]": `rule for env: unknown resource "lambda"`,
+		"rules: [{key: env, severity: urgent}]":    `rule for env: unknown severity "urgent"`,
+	}
+	for policy, want := range cases {
+		t.Run(policy, func(t *testing.T) {
+			_, err := loadTestTagPolicy(t, policy)
+			assert.ErrorContains(t, err, want)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package pricing reads offline price tables, so audits can estimate costs
+// and savings without querying the AWS Price List API.
+package pricing
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HoursPerYear is used to turn hourly prices into annual ones.
+const HoursPerYear = 24 * 365
+
+// The reservation terms.
+const (
+	OneYear   = "1yr"
+	ThreeYear = "3yr"
+)
+
+// Terms are the reservation terms.
+var Terms = []string{OneYear, ThreeYear}
+
+// The offering classes of EC2 reservations. RDS reservations don't have an
+// offering class.
+const (
+	Standard    = "standard"
+	Convertible = "convertible"
+)
+
+// OfferingClasses are the offering classes of EC2 reservations.
+var OfferingClasses = []string{Standard, Convertible}
+
+// Price is the hourly price of an instance type in a region.
+type Price struct {
+	// Service is "ec2" or "rds".
+	Service      string `yaml:"service"`
+	Region       string `yaml:"region"`
+	InstanceType string `yaml:"instance_type"`
+	// Product is the platform for EC2, e.g. "Windows", or the engine for
+	// RDS, e.g. "postgresql". Prices without a product apply to any product
+	// without a price of its own.
+	Product  string  `yaml:"product,omitempty"`
+	OnDemand float64 `yaml:"on_demand"`
+	// Reserved holds the effective hourly price of a reservation, including
+	// any upfront payment, keyed by ReservedKey, e.g. "1yr/standard" or
+	// "3yr" for RDS.
+	Reserved map[string]float64 `yaml:"reserved,omitempty"`
+	// SavingsPlan is the hourly price when covered by a savings plan. When
+	// it isn't set, the on demand price is used.
+	SavingsPlan float64 `yaml:"savings_plan,omitempty"`
+}
+
+// SavingsPlanRate returns the hourly price when covered by a savings plan.
+func (p Price) SavingsPlanRate() float64 {
+	if p.SavingsPlan > 0 {
+		return p.SavingsPlan
+	}
+	return p.OnDemand
+}
+
+// ReservedKey returns the key of the reserved price for the term and
+// offering class, which is empty for RDS.
+func ReservedKey(term, offeringClass string) string {
+	if offeringClass == "" {
+		return term
+	}
+	return term + "/" + offeringClass
+}
+
+// AnnualSavings returns how much reserving count instances for the term and
+// offering class saves each year compared to running them on demand, and
+// whether there is a reserved price to compare with.
+func (p Price) AnnualSavings(term, offeringClass string, count float64) (float64, bool) {
+	reserved, ok := p.Reserved[ReservedKey(term, offeringClass)]
+	if !ok {
+		return 0, false
+	}
+	return (p.OnDemand - reserved) * HoursPerYear * count, true
+}
+
+// StoragePrice is the monthly price of EBS storage of a type in a region.
+type StoragePrice struct {
+	Region string `yaml:"region"`
+	// Type is the volume type, e.g. "gp3".
+	Type       string  `yaml:"type"`
+	PerGBMonth float64 `yaml:"per_gb_month"`
+	// PerIOPSMonth is the price of the provisioned IOPS above IncludedIOPS,
+	// e.g. for io2 volumes, or gp3 volumes with more than 3000 IOPS.
+	PerIOPSMonth float64 `yaml:"per_iops_month,omitempty"`
+	IncludedIOPS int64   `yaml:"included_iops,omitempty"`
+}
+
+// Monthly returns the monthly price of sizeGB of storage with iops
+// provisioned IOPS.
+func (p StoragePrice) Monthly(sizeGB, iops int64) float64 {
+	monthly := float64(sizeGB) * p.PerGBMonth
+	if iops > p.IncludedIOPS {
+		monthly += float64(iops-p.IncludedIOPS) * p.PerIOPSMonth
+	}
+	return monthly
+}
+
+// Table is a price table, usually loaded from a file.
+type Table struct {
+	Prices  []Price        `yaml:"prices"`
+	Storage []StoragePrice `yaml:"storage,omitempty"`
+}
+
+// Load reads a price table from a YAML or JSON file.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("reading price table %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Lookup returns the price of an instance type in a region, preferring a
+// price for the product over one without a product. It is safe to call on a
+// nil table, which has no prices.
+func (t *Table) Lookup(service, region, instanceType, product string) (Price, bool) {
+	if t == nil {
+		return Price{}, false
+	}
+	var fallback *Price
+	for i, p := range t.Prices {
+		if p.Service != service || p.Region != region || p.InstanceType != instanceType {
+			continue
+		}
+		if p.Product == product {
+			return p, true
+		}
+		if p.Product == "" && fallback == nil {
+			fallback = &t.Prices[i]
+		}
+	}
+	if fallback == nil {
+		return Price{}, false
+	}
+	return *fallback, true
+}
+
+// LookupStorage returns the price of a type of storage in a region. It is
+// safe to call on a nil table, which has no prices.
+func (t *Table) LookupStorage(region, storageType string) (StoragePrice, bool) {
+	if t == nil {
+		return StoragePrice{}, false
+	}
+	for _, p := range t.Storage {
+		if p.Region == region && p.Type == storageType {
+			return p, true
+		}
+	}
+	return StoragePrice{}, false
+}
@@ -0,0 +1,107 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const table = `
+prices:
+  - service: ec2
+    region: us-east-1
+    instance_type: m5.large
+    on_demand: 0.096
+    reserved:
+      1yr/standard: 0.06
+  - service: ec2
+    region: us-east-1
+    instance_type: m5.large
+    product: Windows
+    on_demand: 0.188
+  - service: rds
+    region: us-east-1
+    instance_type: db.m5.large
+    product: postgresql
+    on_demand: 0.178
+    reserved:
+      1yr: 0.116
+storage:
+  - {region: us-east-1, type: gp3, per_gb_month: 0.08, per_iops_month: 0.005, included_iops: 3000}
+  - {region: us-east-1, type: io2, per_gb_month: 0.125, per_iops_month: 0.065}
+`
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(table), 0o644))
+
+	prices, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, prices.Prices, 3)
+
+	linux, ok := prices.Lookup("ec2", "us-east-1", "m5.large", "Linux/UNIX")
+	assert.True(t, ok)
+	assert.Equal(t, 0.096, linux.OnDemand)
+
+	windows, ok := prices.Lookup("ec2", "us-east-1", "m5.large", "Windows")
+	assert.True(t, ok)
+	assert.Equal(t, 0.188, windows.OnDemand)
+
+	_, ok = prices.Lookup("ec2", "us-west-2", "m5.large", "")
+	assert.False(t, ok)
+	_, ok = prices.Lookup("rds", "us-east-1", "db.m5.large", "mysql")
+	assert.False(t, ok)
+
+	gp3, ok := prices.LookupStorage("us-east-1", "gp3")
+	assert.True(t, ok)
+	assert.Equal(t, 0.08, gp3.PerGBMonth)
+	_, ok = prices.LookupStorage("us-east-1", "st1")
+	assert.False(t, ok)
+}
+
+func TestStorageMonthly(t *testing.T) {
+	gp3 := StoragePrice{PerGBMonth: 0.08, PerIOPSMonth: 0.005, IncludedIOPS: 3000}
+	assert.InDelta(t, 8.0, gp3.Monthly(100, 3000), 1e-9)
+	assert.InDelta(t, 13.0, gp3.Monthly(100, 4000), 1e-9)
+
+	io2 := StoragePrice{PerGBMonth: 0.125, PerIOPSMonth: 0.065}
+	assert.InDelta(t, 12.5+65, io2.Monthly(100, 1000), 1e-9)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("prices: {"), 0o644))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "reading price table")
+}
+
+func TestAnnualSavings(t *testing.T) {
+	price := Price{OnDemand: 0.1, Reserved: map[string]float64{"1yr/standard": 0.06, "3yr": 0.04}}
+
+	savings, ok := price.AnnualSavings(OneYear, Standard, 2)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.04*HoursPerYear*2, savings, 1e-9)
+
+	savings, ok = price.AnnualSavings(ThreeYear, "", 1)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.06*HoursPerYear, savings, 1e-9)
+
+	_, ok = price.AnnualSavings(OneYear, Convertible, 1)
+	assert.False(t, ok)
+}
+
+func TestNilTable(t *testing.T) {
+	var prices *Table
+	_, ok := prices.Lookup("ec2", "us-east-1", "m5.large", "")
+	assert.False(t, ok)
+	_, ok = prices.LookupStorage("us-east-1", "gp3")
+	assert.False(t, ok)
+}
+
+func TestSavingsPlanRate(t *testing.T) {
+	assert.Equal(t, 0.07, Price{OnDemand: 0.1, SavingsPlan: 0.07}.SavingsPlanRate())
+	assert.Equal(t, 0.1, Price{OnDemand: 0.1}.SavingsPlanRate())
+}
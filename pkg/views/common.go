@@ -1,15 +1,72 @@
 package views
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+)
+
 // InstanceTypeReservationUtilization keeps track of how many of a particular
-// Instance type are running
+// Instance type are running in a scope
 type InstanceTypeReservationUtilization struct {
+	Scope        models.Scope
 	InstanceType string
 	NumReserved  float64
 	NumRunning   float64
+
+	// Platform, Tenancy and AvailabilityZone narrow down which instances the
+	// reservations apply to. They are empty for Linux/UNIX, default tenancy
+	// and regional reservations respectively.
+	Platform         string
+	Tenancy          string
+	AvailabilityZone string
+	// Uncovered holds the IDs of the running instances which aren't fully
+	// covered by a reservation.
+	Uncovered []string
+	// Unnormalized holds the instance classes of the running instances and
+	// reservations whose normalized units aren't known, so they aren't
+	// counted.
+	Unnormalized []string
+
+	// sizes holds the sizes of the running instances of a family, which are
+	// known to exist.
+	sizes map[string]bool
+}
+
+func (i *InstanceTypeReservationUtilization) addSize(size string) {
+	if i.sizes == nil {
+		i.sizes = make(map[string]bool)
+	}
+	i.sizes[size] = true
 }
 
+func (i *InstanceTypeReservationUtilization) addUnnormalized(class string) {
+	for _, c := range i.Unnormalized {
+		if c == class {
+			return
+		}
+	}
+	i.Unnormalized = append(i.Unnormalized, class)
+	sort.Strings(i.Unnormalized)
+}
+
+// String returns the instance type followed by any platform, tenancy or
+// availability zone the utilization is limited to, e.g.
+// "c5.large (Windows, us-east-1a)".
 func (i *InstanceTypeReservationUtilization) String() string {
-	return i.InstanceType
+	qualifiers := make([]string, 0)
+	for _, q := range []string{i.Platform, i.Tenancy, i.AvailabilityZone} {
+		if q != "" {
+			qualifiers = append(qualifiers, q)
+		}
+	}
+	if len(qualifiers) == 0 {
+		return i.InstanceType
+	}
+	return fmt.Sprintf("%s (%s)", i.InstanceType, strings.Join(qualifiers, ", "))
 }
 
 // HasUnused returns whether or not the instance type has reservations that
@@ -24,3 +81,102 @@ func (i *InstanceTypeReservationUtilization) HasUnused() bool {
 func (i *InstanceTypeReservationUtilization) Unreserved() float64 {
 	return i.NumRunning - i.NumReserved
 }
+
+// ReservationUtilizationRecord is the JSON representation of an
+// InstanceTypeReservationUtilization. For RDS, InstanceType is the
+// product/family, or product/class when it isn't size flexible.
+type ReservationUtilizationRecord struct {
+	Account          string   `json:"account" yaml:"account"`
+	Region           string   `json:"region" yaml:"region"`
+	InstanceType     string   `json:"instance_type" yaml:"instance_type"`
+	Platform         string   `json:"platform,omitempty" yaml:"platform,omitempty"`
+	Tenancy          string   `json:"tenancy,omitempty" yaml:"tenancy,omitempty"`
+	AvailabilityZone string   `json:"availability_zone,omitempty" yaml:"availability_zone,omitempty"`
+	Running          float64  `json:"running" yaml:"running"`
+	Reserved         float64  `json:"reserved" yaml:"reserved"`
+	HasUnused        bool     `json:"has_unused" yaml:"has_unused"`
+	Unreserved       float64  `json:"unreserved" yaml:"unreserved"`
+	Uncovered        []string `json:"uncovered,omitempty" yaml:"uncovered,omitempty"`
+	Unnormalized     []string `json:"unnormalized,omitempty" yaml:"unnormalized,omitempty"`
+}
+
+// Record returns the JSON representation of the utilization.
+func (i *InstanceTypeReservationUtilization) Record() ReservationUtilizationRecord {
+	return ReservationUtilizationRecord{
+		Account:          i.Scope.Account,
+		Region:           i.Scope.Region,
+		InstanceType:     i.InstanceType,
+		Platform:         i.Platform,
+		Tenancy:          i.Tenancy,
+		AvailabilityZone: i.AvailabilityZone,
+		Running:          i.NumRunning,
+		Reserved:         i.NumReserved,
+		HasUnused:        i.HasUnused(),
+		Unreserved:       i.Unreserved(),
+		Uncovered:        i.Uncovered,
+		Unnormalized:     i.Unnormalized,
+	}
+}
+
+// sortedUtilizations returns the utilizations ordered by scope and then
+// instance type.
+func sortedUtilizations(utilizations map[models.Scope]map[string]*InstanceTypeReservationUtilization, sortedTypes func(models.Scope) []string) []*InstanceTypeReservationUtilization {
+	sorted := make([]*InstanceTypeReservationUtilization, 0)
+	for _, scope := range models.SortedScopes(utilizations) {
+		for _, k := range sortedTypes(scope) {
+			sorted = append(sorted, utilizations[scope][k])
+		}
+	}
+	return sorted
+}
+
+// utilizationTable renders the utilizations as a table with the given
+// header, which must have 5 columns after the account & region.
+func utilizationTable(utilizations []*InstanceTypeReservationUtilization, header ...string) *Table {
+	table := NewTable(append([]string{"Account", "Region"}, header...)...)
+	for _, iru := range utilizations {
+		table.Append(utilizationRow(iru)...)
+	}
+	return table
+}
+
+func utilizationRow(iru *InstanceTypeReservationUtilization) []string {
+	extra := ""
+	if iru.HasUnused() {
+		extra = "X"
+	}
+	return []string{
+		iru.Scope.Account,
+		iru.Scope.Region,
+		iru.String(),
+		fmt.Sprintf("%.2f", iru.NumRunning),
+		fmt.Sprintf("%.2f", iru.NumReserved),
+		extra,
+		fmt.Sprintf("%.2f", iru.Unreserved()),
+	}
+}
+
+func utilizationRecords(utilizations []*InstanceTypeReservationUtilization) []ReservationUtilizationRecord {
+	records := make([]ReservationUtilizationRecord, len(utilizations))
+	for i, iru := range utilizations {
+		records[i] = iru.Record()
+	}
+	return records
+}
+
+// utilizationFindings reports reservations which aren't being used, since
+// they are paid for either way.
+func utilizationFindings(utilizations []*InstanceTypeReservationUtilization) []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, iru := range utilizations {
+		if iru.HasUnused() {
+			findings = append(findings, policy.Finding{
+				Scope:    iru.Scope,
+				Severity: policy.Medium,
+				Resource: iru.String(),
+				Message:  fmt.Sprintf("%.2f reserved but only %.2f running", iru.NumReserved, iru.NumRunning),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,109 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+)
+
+// DriftReport is a view of the resources which changed between two
+// inventory snapshots.
+type DriftReport struct {
+	changes []models.ResourceChange
+}
+
+// NewDriftReport creates a drift report from the changes returned by
+// models.Diff.
+func NewDriftReport(changes []models.ResourceChange) *DriftReport {
+	return &DriftReport{changes: changes}
+}
+
+// Count returns the number of resources with the given type of change.
+func (dr *DriftReport) Count(t models.ChangeType) int {
+	count := 0
+	for _, c := range dr.changes {
+		if c.Type == t {
+			count++
+		}
+	}
+	return count
+}
+
+// FieldChangeRecord is the JSON representation of a changed field.
+type FieldChangeRecord struct {
+	Field string `json:"field" yaml:"field"`
+	Old   string `json:"old" yaml:"old"`
+	New   string `json:"new" yaml:"new"`
+}
+
+// DriftRecord is the JSON representation of a resource which was added,
+// removed or modified.
+type DriftRecord struct {
+	Account string              `json:"account" yaml:"account"`
+	Region  string              `json:"region" yaml:"region"`
+	Kind    string              `json:"kind" yaml:"kind"`
+	ID      string              `json:"id" yaml:"id"`
+	Change  string              `json:"change" yaml:"change"`
+	Fields  []FieldChangeRecord `json:"fields" yaml:"fields"`
+}
+
+func (dr *DriftReport) records() []DriftRecord {
+	records := make([]DriftRecord, 0, len(dr.changes))
+	for _, c := range dr.changes {
+		fields := make([]FieldChangeRecord, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, FieldChangeRecord{Field: f.Field, Old: f.Old, New: f.New})
+		}
+		records = append(records, DriftRecord{
+			Account: c.Account,
+			Region:  c.Region,
+			Kind:    c.Kind,
+			ID:      c.ID,
+			Change:  string(c.Type),
+			Fields:  fields,
+		})
+	}
+	return records
+}
+
+// Table implements views.View. Modified resources have a row for each field
+// which changed.
+func (dr *DriftReport) Table() *Table {
+	table := NewTable("Account", "Region", "Kind", "ID", "Change", "Field", "Old", "New")
+	for _, r := range dr.records() {
+		if len(r.Fields) == 0 {
+			table.Append(r.Account, r.Region, r.Kind, r.ID, r.Change, "", "", "")
+			continue
+		}
+		for _, f := range r.Fields {
+			table.Append(r.Account, r.Region, r.Kind, r.ID, r.Change, f.Field, f.Old, f.New)
+		}
+	}
+	table.Summary = []string{
+		fmt.Sprintf("Added: %d", dr.Count(models.Added)),
+		fmt.Sprintf("Removed: %d", dr.Count(models.Removed)),
+		fmt.Sprintf("Modified: %d", dr.Count(models.Modified)),
+	}
+	return table
+}
+
+// Records implements views.View
+func (dr *DriftReport) Records() interface{} {
+	return dr.records()
+}
+
+// Findings implements policy.Auditor. Every change is informational, so
+// --fail-on=info fails when anything changed.
+func (dr *DriftReport) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0, len(dr.changes))
+	for _, c := range dr.changes {
+		findings = append(findings, policy.Finding{
+			Scope:    c.Scope,
+			Severity: policy.Info,
+			Resource: c.Kind + " " + c.ID,
+			Message:  string(c.Type),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,32 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftReport(t *testing.T) {
+	scope := models.Scope{Region: "us-east-1"}
+	report := NewDriftReport([]models.ResourceChange{
+		{Scope: scope, Kind: "instance", ID: "i-1", Type: models.Added},
+		{Scope: scope, Kind: "subnet", ID: "subnet-1", Type: models.Modified, Fields: []models.FieldChange{
+			{Field: "AvailableIpAddressCount", Old: "20", New: "5"},
+			{Field: "Tags.cost", New: "data"},
+		}},
+	})
+
+	table := report.Table()
+	assert.Equal(t, [][]string{
+		{"", "us-east-1", "instance", "i-1", "added", "", "", ""},
+		{"", "us-east-1", "subnet", "subnet-1", "modified", "AvailableIpAddressCount", "20", "5"},
+		{"", "us-east-1", "subnet", "subnet-1", "modified", "Tags.cost", "", "data"},
+	}, table.Rows)
+	assert.Equal(t, []string{"Added: 1", "Removed: 0", "Modified: 1"}, table.Summary)
+
+	records := report.Records().([]DriftRecord)
+	assert.Len(t, records, 2)
+	assert.Empty(t, records[0].Fields)
+	assert.Len(t, records[1].Fields, 2)
+}
@@ -0,0 +1,425 @@
+package views
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/jonstacks/aws/pkg/utils"
+)
+
+// The reasons a volume isn't used.
+const (
+	VolumeUnattached      = "unattached"
+	VolumeStoppedInstance = "stopped instance"
+)
+
+// UnusedVolumesOptions are the options of an unused volumes view.
+type UnusedVolumesOptions struct {
+	// Now is when the age of the volumes is measured. Defaults to the
+	// current time.
+	Now time.Time
+	// Prices estimate the monthly cost of the volumes. The cost of volumes
+	// without a storage price isn't estimated.
+	Prices *pricing.Table
+}
+
+// UnusedVolumes is a view of the EBS volumes which are in the "available"
+// state, or are only attached to stopped instances.
+type UnusedVolumes struct {
+	volumes   map[models.Scope][]*ec2.Volume
+	instances map[models.Scope][]*ec2.Instance
+	opts      UnusedVolumesOptions
+}
+
+// NewUnusedVolumes creates a new unused volumes view. The instances are
+// needed to tell which volumes are attached to stopped instances.
+func NewUnusedVolumes(volumes map[models.Scope][]*ec2.Volume, instances map[models.Scope][]*ec2.Instance, opts UnusedVolumesOptions) *UnusedVolumes {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	return &UnusedVolumes{volumes: volumes, instances: instances, opts: opts}
+}
+
+// UnusedVolumeRecord is the JSON representation of an unused volume.
+type UnusedVolumeRecord struct {
+	Account  string `json:"account" yaml:"account"`
+	Region   string `json:"region" yaml:"region"`
+	VolumeID string `json:"volume_id" yaml:"volume_id"`
+	Name     string `json:"name" yaml:"name"`
+	// Reason is VolumeUnattached or VolumeStoppedInstance.
+	Reason      string            `json:"reason" yaml:"reason"`
+	InstanceIDs []string          `json:"instance_ids" yaml:"instance_ids"`
+	SizeGB      int64             `json:"size_gb" yaml:"size_gb"`
+	Type        string            `json:"type" yaml:"type"`
+	IOPS        int64             `json:"iops" yaml:"iops"`
+	Created     *time.Time        `json:"created" yaml:"created"`
+	AgeDays     float64           `json:"age_days" yaml:"age_days"`
+	Tags        map[string]string `json:"tags" yaml:"tags"`
+	// MonthlyCost is nil when the price table has no price for the type.
+	MonthlyCost *float64 `json:"monthly_cost" yaml:"monthly_cost"`
+}
+
+// stoppedInstances returns the IDs of the stopped instances in each scope.
+func (uv *UnusedVolumes) stoppedInstances() map[models.Scope]map[string]bool {
+	stopped := make(map[models.Scope]map[string]bool)
+	for scope, instances := range uv.instances {
+		stopped[scope] = make(map[string]bool)
+		for _, i := range instances {
+			if i.State != nil && aws.StringValue(i.State.Name) == ec2.InstanceStateNameStopped {
+				stopped[scope][aws.StringValue(i.InstanceId)] = true
+			}
+		}
+	}
+	return stopped
+}
+
+// unusedReason returns why the volume isn't used, or an empty string if it
+// is used. Multi-attach volumes are only unused if all of their instances
+// are stopped.
+func unusedReason(v *ec2.Volume, stopped map[string]bool) string {
+	if aws.StringValue(v.State) == ec2.VolumeStateAvailable {
+		return VolumeUnattached
+	}
+	if aws.StringValue(v.State) != ec2.VolumeStateInUse || len(v.Attachments) == 0 {
+		return ""
+	}
+	for _, a := range v.Attachments {
+		if !stopped[aws.StringValue(a.InstanceId)] {
+			return ""
+		}
+	}
+	return VolumeStoppedInstance
+}
+
+func (uv *UnusedVolumes) records() []UnusedVolumeRecord {
+	stopped := uv.stoppedInstances()
+	records := make([]UnusedVolumeRecord, 0)
+	for _, scope := range models.SortedScopes(uv.volumes) {
+		for _, v := range uv.volumes[scope] {
+			reason := unusedReason(v, stopped[scope])
+			if reason == "" {
+				continue
+			}
+			r := UnusedVolumeRecord{
+				Account:     scope.Account,
+				Region:      scope.Region,
+				VolumeID:    aws.StringValue(v.VolumeId),
+				Name:        utils.GetTagValue(v.Tags, "Name"),
+				Reason:      reason,
+				InstanceIDs: make([]string, 0, len(v.Attachments)),
+				SizeGB:      aws.Int64Value(v.Size),
+				Type:        aws.StringValue(v.VolumeType),
+				IOPS:        aws.Int64Value(v.Iops),
+				Created:     v.CreateTime,
+				Tags:        ec2Tags(v.Tags),
+			}
+			for _, a := range v.Attachments {
+				r.InstanceIDs = append(r.InstanceIDs, aws.StringValue(a.InstanceId))
+			}
+			if v.CreateTime != nil {
+				r.AgeDays = uv.opts.Now.Sub(*v.CreateTime).Hours() / 24
+			}
+			if price, ok := uv.opts.Prices.LookupStorage(scope.Region, r.Type); ok {
+				r.MonthlyCost = aws.Float64(price.Monthly(r.SizeGB, r.IOPS))
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// formatTags formats tags as "key=value" pairs sorted by key.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// Table implements views.View
+func (uv *UnusedVolumes) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Volume ID",
+		"Name",
+		"Reason",
+		"Instances",
+		"Size(GB)",
+		"Type",
+		"IOPS",
+		"Age Days",
+		"Tags",
+		"Monthly Cost",
+	)
+	var sizeGB int64
+	var cost float64
+	unpriced := 0
+	records := uv.records()
+	for _, r := range records {
+		sizeGB += r.SizeGB
+		monthly := ""
+		if r.MonthlyCost != nil {
+			cost += *r.MonthlyCost
+			monthly = fmt.Sprintf("%.2f", *r.MonthlyCost)
+		} else {
+			unpriced++
+		}
+		table.Append(
+			r.Account,
+			r.Region,
+			r.VolumeID,
+			r.Name,
+			r.Reason,
+			strings.Join(r.InstanceIDs, ", "),
+			strconv.FormatInt(r.SizeGB, 10),
+			r.Type,
+			strconv.FormatInt(r.IOPS, 10),
+			fmt.Sprintf("%0.2f", r.AgeDays),
+			formatTags(r.Tags),
+			monthly,
+		)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("%d Unused Volumes: %d GB", len(records), sizeGB),
+		fmt.Sprintf("Est. Monthly Cost: %.2f", cost),
+	}
+	if unpriced > 0 {
+		table.Summary = append(table.Summary, fmt.Sprintf("%d Volumes Without a Price Aren't Included", unpriced))
+	}
+	return table
+}
+
+// Records implements views.View
+func (uv *UnusedVolumes) Records() interface{} {
+	return uv.records()
+}
+
+// Findings implements policy.Auditor. Unattached volumes are medium severity
+// findings, since nothing can be using them, while volumes of stopped
+// instances are low severity ones, since the instances may be started again.
+func (uv *UnusedVolumes) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range uv.records() {
+		f := policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Medium,
+			Resource: r.VolumeID,
+			Message:  "volume isn't attached to an instance",
+		}
+		if r.Reason == VolumeStoppedInstance {
+			f.Severity = policy.Low
+			f.Message = "volume is only attached to stopped instances"
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// EBSSnapshotAudit gives an overview of the EBS snapshots, with their volumes,
+// and how much storage is being used.
+type EBSSnapshotAudit struct {
+	Snapshots map[models.Scope][]*ec2.Snapshot
+	Volumes   map[models.Scope][]*ec2.Volume
+	// Images are the AMIs whose block device mappings keep snapshots in use.
+	Images map[models.Scope][]*ec2.Image
+}
+
+// NewEBSSnapshotAudit returns an audit view for comparing snapshots against
+// the existing volumes and the AMIs.
+func NewEBSSnapshotAudit(snapshots map[models.Scope][]*ec2.Snapshot, volumes map[models.Scope][]*ec2.Volume, images map[models.Scope][]*ec2.Image) *EBSSnapshotAudit {
+	return &EBSSnapshotAudit{Snapshots: snapshots, Volumes: volumes, Images: images}
+}
+
+// NumVolumes returns the number of volumes
+func (audit *EBSSnapshotAudit) NumVolumes() int {
+	n := 0
+	for _, volumes := range audit.Volumes {
+		n += len(volumes)
+	}
+	return n
+}
+
+// NumSnapshots returns the number of snapshots
+func (audit *EBSSnapshotAudit) NumSnapshots() int {
+	n := 0
+	for _, snapshots := range audit.Snapshots {
+		n += len(snapshots)
+	}
+	return n
+}
+
+// TotalVolumeStorageGB returns the total size of the volumes in GB
+func (audit *EBSSnapshotAudit) TotalVolumeStorageGB() int64 {
+	var storage int64
+	for _, volumes := range audit.Volumes {
+		for _, v := range volumes {
+			storage += aws.Int64Value(v.Size)
+		}
+	}
+	return storage
+}
+
+// TotalVirtualSnapshotStorageGB returns the total storage of snapshots in GB.
+// This is the "virtual" storage though as the snapshots only store deltas
+func (audit *EBSSnapshotAudit) TotalVirtualSnapshotStorageGB() int64 {
+	var storage int64
+	for _, snapshots := range audit.Snapshots {
+		for _, s := range snapshots {
+			storage += aws.Int64Value(s.VolumeSize)
+		}
+	}
+	return storage
+}
+
+// imageSnapshots returns the AMIs which use each snapshot in the scope.
+func (audit *EBSSnapshotAudit) imageSnapshots(scope models.Scope) map[string][]string {
+	used := make(map[string][]string)
+	for _, image := range audit.Images[scope] {
+		for _, m := range image.BlockDeviceMappings {
+			if m.Ebs == nil || m.Ebs.SnapshotId == nil {
+				continue
+			}
+			id := aws.StringValue(m.Ebs.SnapshotId)
+			used[id] = append(used[id], aws.StringValue(image.ImageId))
+		}
+	}
+	return used
+}
+
+// OldVolumesWithSnapshots returns a map whose keys are volume IDs which no
+// longer exist in the given scope. The values are slices of the snapshots of
+// the volume which aren't used by any AMI. Snapshots copied from other
+// snapshots have the volume ID "vol-ffffffff", which never exists.
+func (audit *EBSSnapshotAudit) OldVolumesWithSnapshots(scope models.Scope) map[string][]*ec2.Snapshot {
+	volumeIDs := make(map[string]bool)
+	for _, v := range audit.Volumes[scope] {
+		volumeIDs[aws.StringValue(v.VolumeId)] = true
+	}
+	used := audit.imageSnapshots(scope)
+
+	old := make(map[string][]*ec2.Snapshot)
+	for _, snap := range audit.Snapshots[scope] {
+		volumeID := aws.StringValue(snap.VolumeId)
+		if volumeIDs[volumeID] || len(used[aws.StringValue(snap.SnapshotId)]) > 0 {
+			continue
+		}
+		old[volumeID] = append(old[volumeID], snap)
+	}
+	return old
+}
+
+// EBSSnapshotAuditRecord is the JSON representation of the EBS snapshot
+// audit.
+type EBSSnapshotAuditRecord struct {
+	Volumes                       int                 `json:"volumes" yaml:"volumes"`
+	Snapshots                     int                 `json:"snapshots" yaml:"snapshots"`
+	TotalVolumeStorageGB          int64               `json:"total_volume_storage_gb" yaml:"total_volume_storage_gb"`
+	TotalVirtualSnapshotStorageGB int64               `json:"total_virtual_snapshot_storage_gb" yaml:"total_virtual_snapshot_storage_gb"`
+	OldSnapshots                  []EBSSnapshotRecord `json:"old_snapshots" yaml:"old_snapshots"`
+}
+
+// EBSSnapshotRecord is the JSON representation of a snapshot whose volume no
+// longer exists.
+type EBSSnapshotRecord struct {
+	Account     string    `json:"account" yaml:"account"`
+	Region      string    `json:"region" yaml:"region"`
+	VolumeID    string    `json:"volume_id" yaml:"volume_id"`
+	SnapshotID  string    `json:"snapshot_id" yaml:"snapshot_id"`
+	Description string    `json:"description" yaml:"description"`
+	CreatedTime time.Time `json:"created_time" yaml:"created_time"`
+	SizeGB      int64     `json:"size_gb" yaml:"size_gb"`
+}
+
+func (audit *EBSSnapshotAudit) oldSnapshotRecords() []EBSSnapshotRecord {
+	records := make([]EBSSnapshotRecord, 0)
+	for _, scope := range models.SortedScopes(audit.Snapshots) {
+		oldSnapMap := audit.OldVolumesWithSnapshots(scope)
+		volumeIDs := make([]string, 0, len(oldSnapMap))
+		for id := range oldSnapMap {
+			volumeIDs = append(volumeIDs, id)
+		}
+		sort.Strings(volumeIDs)
+
+		for _, id := range volumeIDs {
+			for _, snap := range oldSnapMap[id] {
+				records = append(records, EBSSnapshotRecord{
+					Account:     scope.Account,
+					Region:      scope.Region,
+					VolumeID:    id,
+					SnapshotID:  aws.StringValue(snap.SnapshotId),
+					Description: aws.StringValue(snap.Description),
+					CreatedTime: aws.TimeValue(snap.StartTime),
+					SizeGB:      aws.Int64Value(snap.VolumeSize),
+				})
+			}
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (audit *EBSSnapshotAudit) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Volume ID",
+		"Snapshot ID",
+		"Description",
+		"Created Time",
+		"Size",
+	)
+	for _, r := range audit.oldSnapshotRecords() {
+		table.Append(
+			r.Account,
+			r.Region,
+			r.VolumeID,
+			r.SnapshotID,
+			r.Description,
+			r.CreatedTime.Format(time.UnixDate),
+			strconv.FormatInt(r.SizeGB, 10),
+		)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("Number of Volumes: %d", audit.NumVolumes()),
+		fmt.Sprintf("Number of EBS Snapshots: %d", audit.NumSnapshots()),
+		fmt.Sprintf("Total Volume storage: %d GB", audit.TotalVolumeStorageGB()),
+		fmt.Sprintf("Total Virtual Snapshot storage: %d GB", audit.TotalVirtualSnapshotStorageGB()),
+	}
+	return table
+}
+
+// Records implements views.View
+func (audit *EBSSnapshotAudit) Records() interface{} {
+	return EBSSnapshotAuditRecord{
+		Volumes:                       audit.NumVolumes(),
+		Snapshots:                     audit.NumSnapshots(),
+		TotalVolumeStorageGB:          audit.TotalVolumeStorageGB(),
+		TotalVirtualSnapshotStorageGB: audit.TotalVirtualSnapshotStorageGB(),
+		OldSnapshots:                  audit.oldSnapshotRecords(),
+	}
+}
+
+// Findings implements policy.Auditor
+func (audit *EBSSnapshotAudit) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range audit.oldSnapshotRecords() {
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.SnapshotID,
+			Message:  fmt.Sprintf("snapshot of deleted volume %s isn't used by any AMI", r.VolumeID),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,146 @@
+package views
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeVolume(id, state string, sizeGB int64, instanceIDs ...string) *ec2.Volume {
+	v := &ec2.Volume{
+		VolumeId:   aws.String(id),
+		State:      aws.String(state),
+		Size:       aws.Int64(sizeGB),
+		VolumeType: aws.String("gp3"),
+		Iops:       aws.Int64(3000),
+	}
+	for _, id := range instanceIDs {
+		v.Attachments = append(v.Attachments, &ec2.VolumeAttachment{InstanceId: aws.String(id)})
+	}
+	return v
+}
+
+func TestUnusedVolumes(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	stopped := makeEC2Instance("i-stopped", "m5.large")
+	stopped.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)}
+	running := makeEC2Instance("i-running", "m5.large")
+	running.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+
+	available := makeVolume("vol-available", ec2.VolumeStateAvailable, 100)
+	available.CreateTime = aws.Time(now.Add(-10 * 24 * time.Hour))
+	available.Tags = []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String("scratch")},
+		{Key: aws.String("cost"), Value: aws.String("data")},
+	}
+	io2 := makeVolume("vol-io2", ec2.VolumeStateInUse, 50, "i-stopped")
+	io2.VolumeType = aws.String("io2")
+
+	uv := NewUnusedVolumes(
+		map[models.Scope][]*ec2.Volume{east: {
+			available,
+			io2,
+			makeVolume("vol-running", ec2.VolumeStateInUse, 10, "i-running"),
+			// Multi-attach volumes are used while any instance is running.
+			makeVolume("vol-multi", ec2.VolumeStateInUse, 10, "i-stopped", "i-running"),
+			makeVolume("vol-creating", ec2.VolumeStateCreating, 10),
+		}},
+		map[models.Scope][]*ec2.Instance{east: {stopped, running}},
+		UnusedVolumesOptions{
+			Now: now,
+			Prices: &pricing.Table{Storage: []pricing.StoragePrice{
+				{Region: "us-east-1", Type: "gp3", PerGBMonth: 0.08, PerIOPSMonth: 0.005, IncludedIOPS: 3000},
+			}},
+		},
+	)
+
+	records := uv.records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, UnusedVolumeRecord{
+		Account:     "111111111111",
+		Region:      "us-east-1",
+		VolumeID:    "vol-available",
+		Name:        "scratch",
+		Reason:      VolumeUnattached,
+		InstanceIDs: []string{},
+		SizeGB:      100,
+		Type:        "gp3",
+		IOPS:        3000,
+		Created:     available.CreateTime,
+		AgeDays:     10,
+		Tags:        map[string]string{"Name": "scratch", "cost": "data"},
+		MonthlyCost: aws.Float64(8),
+	}, records[0])
+	assert.Equal(t, VolumeStoppedInstance, records[1].Reason)
+	assert.Equal(t, []string{"i-stopped"}, records[1].InstanceIDs)
+	assert.Nil(t, records[1].MonthlyCost)
+
+	table := uv.Table()
+	assert.Equal(t, []string{
+		"2 Unused Volumes: 150 GB",
+		"Est. Monthly Cost: 8.00",
+		"1 Volumes Without a Price Aren't Included",
+	}, table.Summary)
+	assert.Equal(t, "Name=scratch, cost=data", table.Rows[0][10])
+
+	findings := uv.Findings()
+	assert.Len(t, findings, 2)
+	assert.Equal(t, policy.Medium, findings[0].Severity)
+	assert.Equal(t, policy.Low, findings[1].Severity)
+	assert.Equal(t, "volume is only attached to stopped instances", findings[1].Message)
+}
+
+func TestEBSSnapshotAudit(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	west := models.Scope{Account: "111111111111", Region: "us-west-2"}
+	snapshot := func(id, volumeID string, sizeGB int64) *ec2.Snapshot {
+		return &ec2.Snapshot{SnapshotId: aws.String(id), VolumeId: aws.String(volumeID), VolumeSize: aws.Int64(sizeGB)}
+	}
+
+	audit := NewEBSSnapshotAudit(
+		map[models.Scope][]*ec2.Snapshot{
+			east: {
+				snapshot("snap-live", "vol-1", 100),
+				snapshot("snap-old-2", "vol-deleted", 50),
+				snapshot("snap-ami", "vol-deleted", 50),
+				snapshot("snap-copy", "vol-ffffffff", 8),
+			},
+			// The volume only exists in another region.
+			west: {snapshot("snap-old-1", "vol-1", 100)},
+		},
+		map[models.Scope][]*ec2.Volume{east: {makeVolume("vol-1", ec2.VolumeStateInUse, 100, "i-1")}},
+		map[models.Scope][]*ec2.Image{east: {{
+			ImageId: aws.String("ami-1"),
+			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+				{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-ami")}},
+				{DeviceName: aws.String("/dev/sdb"), VirtualName: aws.String("ephemeral0")},
+			},
+		}}},
+	)
+
+	assert.Equal(t, 1, audit.NumVolumes())
+	assert.Equal(t, 5, audit.NumSnapshots())
+	assert.Equal(t, int64(100), audit.TotalVolumeStorageGB())
+	assert.Equal(t, int64(308), audit.TotalVirtualSnapshotStorageGB())
+
+	snapshots := make([]string, 0)
+	for _, r := range audit.oldSnapshotRecords() {
+		snapshots = append(snapshots, r.Region+" "+r.VolumeID+" "+r.SnapshotID)
+	}
+	assert.Equal(t, []string{
+		"us-east-1 vol-deleted snap-old-2",
+		"us-east-1 vol-ffffffff snap-copy",
+		"us-west-2 vol-1 snap-old-1",
+	}, snapshots)
+
+	findings := audit.Findings()
+	assert.Len(t, findings, 3)
+	assert.Equal(t, "snapshot of deleted volume vol-deleted isn't used by any AMI", findings[0].Message)
+}
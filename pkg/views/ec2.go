@@ -2,26 +2,121 @@ package views
 
 import (
 	"fmt"
-	"io"
 	"net"
-	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
 	"github.com/jonstacks/aws/pkg/utils"
 	"github.com/jonstacks/networktree"
-	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
 )
 
+// ec2InstanceType is an EC2 instance type such as "m5.large", which is the
+// "large" size of the "m5" family.
+type ec2InstanceType string
+
+func (t ec2InstanceType) family() string {
+	if i := strings.LastIndex(string(t), "."); i >= 0 {
+		return string(t[:i])
+	}
+	return string(t)
+}
+
+func (t ec2InstanceType) size() string {
+	if i := strings.LastIndex(string(t), "."); i >= 0 {
+		return string(t[i+1:])
+	}
+	return ""
+}
+
+func (t ec2InstanceType) normalizedUnits(u *NormalizedUnits) (float64, bool) {
+	return u.lookup(t.family(), t.size())
+}
+
+// reservationKey is what a reservation has to have in common with an instance
+// for it to cover the instance. AvailabilityZone is only set for zonal
+// reservations and InstanceType is the family for size flexible ones.
+type reservationKey struct {
+	Platform         string
+	Tenancy          string
+	AvailabilityZone string
+	InstanceType     string
+}
+
+// sizeFlexible returns whether regional reservations for the key apply to any
+// size in the family. This is only the case for Linux/UNIX with default
+// tenancy.
+func (k reservationKey) sizeFlexible(u *NormalizedUnits) bool {
+	_, ok := ec2InstanceType(k.InstanceType).normalizedUnits(u)
+	return ok && k.Platform == ec2.RIProductDescriptionLinuxUnix && k.Tenancy == ec2.TenancyDefault
+}
+
+// instanceKey returns the regional key of the instance. The platform is named
+// the way reservations describe it, e.g. "Windows" or "Red Hat Enterprise
+// Linux".
+func instanceKey(i *ec2.Instance) reservationKey {
+	key := reservationKey{
+		Platform:     aws.StringValue(i.PlatformDetails),
+		Tenancy:      ec2.TenancyDefault,
+		InstanceType: aws.StringValue(i.InstanceType),
+	}
+	if key.Platform == "" {
+		key.Platform = ec2.RIProductDescriptionLinuxUnix
+		if aws.StringValue(i.Platform) == ec2.PlatformValuesWindows {
+			key.Platform = ec2.RIProductDescriptionWindows
+		}
+	}
+	if i.Placement != nil && aws.StringValue(i.Placement.Tenancy) != "" {
+		key.Tenancy = aws.StringValue(i.Placement.Tenancy)
+	}
+	return key
+}
+
+// instanceZone returns the availability zone the instance is running in.
+func instanceZone(i *ec2.Instance) string {
+	if i.Placement == nil {
+		return ""
+	}
+	return aws.StringValue(i.Placement.AvailabilityZone)
+}
+
+// reservationKeyOf returns the key of the reservation, which is zonal when the
+// reservation is scoped to an availability zone.
+func reservationKeyOf(r *ec2.ReservedInstances) reservationKey {
+	key := reservationKey{
+		Platform:     strings.TrimSuffix(aws.StringValue(r.ProductDescription), " (Amazon VPC)"),
+		Tenancy:      aws.StringValue(r.InstanceTenancy),
+		InstanceType: aws.StringValue(r.InstanceType),
+	}
+	if key.Platform == "" {
+		key.Platform = ec2.RIProductDescriptionLinuxUnix
+	}
+	if key.Tenancy == "" {
+		key.Tenancy = ec2.TenancyDefault
+	}
+	if aws.StringValue(r.Scope) == ec2.ScopeAvailabilityZone {
+		key.AvailabilityZone = aws.StringValue(r.AvailabilityZone)
+	}
+	return key
+}
+
 // ReservationUtilization shows which instance types & families we are utilizing
-// instances in.
+// instances in. Reservations only apply to instances in their own scope.
+// Size flexible families are keyed by the family, e.g. "m5", and counted in
+// normalized units, while everything else is keyed by the instance type and
+// counted in instances. Keys include the platform, tenancy and availability
+// zone when they aren't the defaults, e.g. "c5.large (Windows)".
 type ReservationUtilization struct {
-	Running                             []*ec2.Instance
-	Reservations                        []*ec2.ReservedInstances
-	InstanceTypeReservationUtilizations map[string]*InstanceTypeReservationUtilization
+	Running                             map[models.Scope][]*ec2.Instance
+	Reservations                        map[models.Scope][]*ec2.ReservedInstances
+	InstanceTypeReservationUtilizations map[models.Scope]map[string]*InstanceTypeReservationUtilization
 
 	opts ReservationUtilizationOptions
 }
@@ -30,27 +125,37 @@ type ReservationUtilization struct {
 // ReservationUtilization view.
 type ReservationUtilizationOptions struct {
 	OnlyUnmatched bool
+	// NormalizedUnits are used on top of the built in normalized units to
+	// match size flexible reservations. It may be nil.
+	NormalizedUnits *NormalizedUnits
 }
 
 // NewReservationUtilization Creates a new view for the reserved utilization.
-func NewReservationUtilization(running []*ec2.Instance, reservations []*ec2.ReservedInstances, opts ReservationUtilizationOptions) *ReservationUtilization {
+//
+// Reservations are applied the way AWS bills them. A reservation only covers
+// instances with the same platform and tenancy, and zonal reservations are
+// applied first, to instances of exactly the same type in their availability
+// zone. Regional Linux/UNIX reservations with default tenancy are size
+// flexible, so they are counted per instance family in normalized units and
+// cover the smallest instances first. Other regional reservations cover
+// instances of exactly the same type.
+func NewReservationUtilization(running map[models.Scope][]*ec2.Instance, reservations map[models.Scope][]*ec2.ReservedInstances, opts ReservationUtilizationOptions) *ReservationUtilization {
 	ru := ReservationUtilization{
 		Running:                             running,
 		Reservations:                        reservations,
-		InstanceTypeReservationUtilizations: make(map[string]*InstanceTypeReservationUtilization),
+		InstanceTypeReservationUtilizations: make(map[models.Scope]map[string]*InstanceTypeReservationUtilization),
 		opts:                                opts,
 	}
 
-	for _, i := range ru.Running {
-		itype := aws.StringValue(i.InstanceType)
-		iru := ru.getOrInitializeITypeReservation(itype)
-		iru.NumRunning++
+	scopes := make(map[models.Scope]bool)
+	for scope := range running {
+		scopes[scope] = true
 	}
-
-	for _, r := range ru.Reservations {
-		itype := aws.StringValue(r.InstanceType)
-		iru := ru.getOrInitializeITypeReservation(itype)
-		iru.NumReserved += float64(*r.InstanceCount)
+	for scope := range reservations {
+		scopes[scope] = true
+	}
+	for scope := range scopes {
+		ru.match(scope, running[scope], reservations[scope])
 	}
 
 	if opts.OnlyUnmatched {
@@ -60,82 +165,185 @@ func NewReservationUtilization(running []*ec2.Instance, reservations []*ec2.Rese
 	return &ru
 }
 
-func (ru *ReservationUtilization) getOrInitializeITypeReservation(s string) *InstanceTypeReservationUtilization {
-	_, ok := ru.InstanceTypeReservationUtilizations[s]
-	if !ok {
-		ru.InstanceTypeReservationUtilizations[s] = &InstanceTypeReservationUtilization{
-			InstanceType: s,
+// match applies the reservations of a scope to its instances.
+func (ru *ReservationUtilization) match(scope models.Scope, instances []*ec2.Instance, reservations []*ec2.ReservedInstances) {
+	u := ru.opts.NormalizedUnits
+	// available is the number of instances, or normalized units for size
+	// flexible reservations, which haven't been applied yet.
+	available := make(map[reservationKey]float64)
+	for _, r := range reservations {
+		key := reservationKeyOf(r)
+		reserved := float64(aws.Int64Value(r.InstanceCount))
+		if key.AvailabilityZone == "" && key.sizeFlexible(u) {
+			itype := ec2InstanceType(key.InstanceType)
+			units, _ := itype.normalizedUnits(u)
+			key.InstanceType = itype.family()
+			reserved *= units
 		}
+		available[key] += reserved
+		ru.getOrInitializeITypeReservation(scope, key).NumReserved += reserved
+	}
+
+	sorted := make([]*ec2.Instance, len(instances))
+	copy(sorted, instances)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return aws.StringValue(sorted[a].InstanceId) < aws.StringValue(sorted[b].InstanceId)
+	})
+
+	regional := make([]*ec2.Instance, 0)
+	for _, i := range sorted {
+		key := instanceKey(i)
+		key.AvailabilityZone = instanceZone(i)
+		if available[key] >= 1 {
+			available[key]--
+			ru.getOrInitializeITypeReservation(scope, key).NumRunning++
+			continue
+		}
+		regional = append(regional, i)
+	}
+
+	flexible := make([]*ec2.Instance, 0)
+	for _, i := range regional {
+		key := instanceKey(i)
+		if key.sizeFlexible(u) {
+			flexible = append(flexible, i)
+			continue
+		}
+		iru := ru.getOrInitializeITypeReservation(scope, key)
+		iru.NumRunning++
+		if available[key] >= 1 {
+			available[key]--
+			continue
+		}
+		iru.Uncovered = append(iru.Uncovered, aws.StringValue(i.InstanceId))
+	}
+
+	// Size flexible reservations apply to the smallest instances first.
+	sort.SliceStable(flexible, func(a, b int) bool {
+		unitsA, _ := ec2InstanceType(aws.StringValue(flexible[a].InstanceType)).normalizedUnits(u)
+		unitsB, _ := ec2InstanceType(aws.StringValue(flexible[b].InstanceType)).normalizedUnits(u)
+		return unitsA < unitsB
+	})
+	for _, i := range flexible {
+		key := instanceKey(i)
+		itype := ec2InstanceType(key.InstanceType)
+		units, _ := itype.normalizedUnits(u)
+		key.InstanceType = itype.family()
+
+		iru := ru.getOrInitializeITypeReservation(scope, key)
+		iru.NumRunning += units
+		iru.addSize(itype.size())
+		if available[key] >= units {
+			available[key] -= units
+			continue
+		}
+		// A partially covered instance is still billed for the rest.
+		available[key] = 0
+		iru.Uncovered = append(iru.Uncovered, aws.StringValue(i.InstanceId))
 	}
-	return ru.InstanceTypeReservationUtilizations[s]
+}
+
+func (ru *ReservationUtilization) getOrInitializeITypeReservation(scope models.Scope, key reservationKey) *InstanceTypeReservationUtilization {
+	utilizations, ok := ru.InstanceTypeReservationUtilizations[scope]
+	if !ok {
+		utilizations = make(map[string]*InstanceTypeReservationUtilization)
+		ru.InstanceTypeReservationUtilizations[scope] = utilizations
+	}
+
+	iru := &InstanceTypeReservationUtilization{
+		Scope:            scope,
+		InstanceType:     key.InstanceType,
+		AvailabilityZone: key.AvailabilityZone,
+	}
+	// Only platforms and tenancies other than the defaults are shown.
+	if key.Platform != ec2.RIProductDescriptionLinuxUnix {
+		iru.Platform = key.Platform
+	}
+	if key.Tenancy != ec2.TenancyDefault {
+		iru.Tenancy = key.Tenancy
+	}
+	if existing, ok := utilizations[iru.String()]; ok {
+		return existing
+	}
+	utilizations[iru.String()] = iru
+	return iru
 }
 
 // pruneMatched removes all the keys where the running count = reserved count
 func (ru *ReservationUtilization) pruneMatched() {
-	for k, v := range ru.InstanceTypeReservationUtilizations {
-		// This instnace type is perfectly matched, lets delete it.
-		if v.Unreserved() == 0 {
-			delete(ru.InstanceTypeReservationUtilizations, k)
+	for _, utilizations := range ru.InstanceTypeReservationUtilizations {
+		for k, v := range utilizations {
+			// This instnace type is perfectly matched, lets delete it.
+			if v.Unreserved() == 0 {
+				delete(utilizations, k)
+			}
 		}
 	}
 }
 
-// SortedInstanceTypes returns a sorted slice of the instance types
-func (ru *ReservationUtilization) SortedInstanceTypes() []string {
+// SortedInstanceTypes returns a sorted slice of the instance types in the
+// given scope
+func (ru *ReservationUtilization) SortedInstanceTypes(scope models.Scope) []string {
 	types := make([]string, 0)
-	for k := range ru.InstanceTypeReservationUtilizations {
+	for k := range ru.InstanceTypeReservationUtilizations[scope] {
 		types = append(types, k)
 	}
 	sort.Strings(types)
 	return types
 }
 
-// Print prints the table to stdout
-func (ru *ReservationUtilization) Print() {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
-		"Instance Type",
+// Table implements views.View
+func (ru *ReservationUtilization) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Instance Type/Family",
 		"Running Count",
 		"Reserved Count",
 		"Has Unused",
 		"Should be reserved?",
-	})
-
-	for _, k := range ru.SortedInstanceTypes() {
-		iru := ru.getOrInitializeITypeReservation(k)
-		extra := ""
-		if iru.HasUnused() {
-			extra = "X"
-		}
-		table.Append([]string{
-			k,
-			fmt.Sprintf("%.2f", iru.NumRunning),
-			fmt.Sprintf("%.2f", iru.NumReserved),
-			extra,
-			fmt.Sprintf("%.2f", iru.Unreserved()),
-		})
+		"Uncovered Instances",
+	)
+	uncovered := 0
+	for _, iru := range sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes) {
+		uncovered += len(iru.Uncovered)
+		table.Append(append(utilizationRow(iru), strings.Join(iru.Uncovered, "\n"))...)
 	}
+	table.Summary = []string{fmt.Sprintf("%d Uncovered Instances", uncovered)}
+	return table
+}
+
+// Records implements views.View
+func (ru *ReservationUtilization) Records() interface{} {
+	return utilizationRecords(sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes))
+}
 
-	table.Render()
+// Findings implements policy.Auditor
+func (ru *ReservationUtilization) Findings() []policy.Finding {
+	return utilizationFindings(sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes))
 }
 
 // InstancesBySubnet is a view for showing the instances grouped by subnet.
 type InstancesBySubnet struct {
-	subnets   map[string]*ec2.Subnet
+	subnets   map[models.Scope][]*ec2.Subnet
 	instances map[string][]*ec2.Instance
 }
 
 // NewInstancesBySubnet creates a new view from the instances and subnets
-func NewInstancesBySubnet(instances []*ec2.Instance, subnets []*ec2.Subnet) *InstancesBySubnet {
+func NewInstancesBySubnet(instances map[models.Scope][]*ec2.Instance, subnets map[models.Scope][]*ec2.Subnet) *InstancesBySubnet {
 	ibs := &InstancesBySubnet{
-		subnets:   make(map[string]*ec2.Subnet),
+		subnets:   make(map[models.Scope][]*ec2.Subnet),
 		instances: make(map[string][]*ec2.Instance),
 	}
-	for _, i := range instances {
-		ibs.AddInstance(i)
+	for _, scopeInstances := range instances {
+		for _, i := range scopeInstances {
+			ibs.AddInstance(i)
+		}
 	}
-	for _, s := range subnets {
-		ibs.AddSubnet(s)
+	for scope, scopeSubnets := range subnets {
+		for _, s := range scopeSubnets {
+			ibs.AddSubnet(scope, s)
+		}
 	}
 	return ibs
 }
@@ -149,65 +357,144 @@ func (ibs *InstancesBySubnet) AddInstance(i *ec2.Instance) {
 	ibs.instances[subnetID] = append(ibs.instances[subnetID], i)
 }
 
-// AddSubnet adds a new subnet to the view
-func (ibs *InstancesBySubnet) AddSubnet(s *ec2.Subnet) {
-	subnetID := aws.StringValue(s.SubnetId)
-	ibs.subnets[subnetID] = s
+// AddSubnet adds a new subnet, found in the given scope, to the view
+func (ibs *InstancesBySubnet) AddSubnet(scope models.Scope, s *ec2.Subnet) {
+	ibs.subnets[scope] = append(ibs.subnets[scope], s)
 }
 
-// Print prints the InstancesBySubnet view
-func (ibs *InstancesBySubnet) Print() {
-	emptySubnets := make([]*ec2.Subnet, 0)
-	instanceCount := 0
+// SubnetRecord is the JSON representation of a subnet and the instances
+// running in it.
+type SubnetRecord struct {
+	Account   string           `json:"account" yaml:"account"`
+	Region    string           `json:"region" yaml:"region"`
+	SubnetID  string           `json:"subnet_id" yaml:"subnet_id"`
+	Name      string           `json:"name" yaml:"name"`
+	CIDR      string           `json:"cidr" yaml:"cidr"`
+	Instances []InstanceRecord `json:"instances" yaml:"instances"`
+}
+
+// InstanceRecord is the JSON representation of an instance.
+type InstanceRecord struct {
+	InstanceID string `json:"instance_id" yaml:"instance_id"`
+	Name       string `json:"name" yaml:"name"`
+}
 
-	subnetTemplate := func(s *ec2.Subnet) string {
-		subnetID := aws.StringValue(s.SubnetId)
-		name := utils.GetTagValue(s.Tags, "Name")
-		cidr := aws.StringValue(s.CidrBlock)
-		return fmt.Sprintf("%s [Name=%s][CIDR=%s]", subnetID, name, cidr)
+func (ibs *InstancesBySubnet) records() []SubnetRecord {
+	records := make([]SubnetRecord, 0)
+	for _, scope := range models.SortedScopes(ibs.subnets) {
+		for _, s := range ibs.subnets[scope] {
+			subnetID := aws.StringValue(s.SubnetId)
+			record := SubnetRecord{
+				Account:   scope.Account,
+				Region:    scope.Region,
+				SubnetID:  subnetID,
+				Name:      utils.GetTagValue(s.Tags, "Name"),
+				CIDR:      aws.StringValue(s.CidrBlock),
+				Instances: make([]InstanceRecord, 0),
+			}
+			for _, i := range ibs.instances[subnetID] {
+				record.Instances = append(record.Instances, InstanceRecord{
+					InstanceID: aws.StringValue(i.InstanceId),
+					Name:       utils.GetInstanceName(i),
+				})
+			}
+			records = append(records, record)
+		}
 	}
+	return records
+}
 
-	for subnetID, subnet := range ibs.subnets {
-		instances, ok := ibs.instances[subnetID]
-		if !ok {
-			// Subnet is Empty
-			emptySubnets = append(emptySubnets, subnet)
+// Table implements views.View. Each instance gets its own row, while empty
+// subnets get a row without an instance.
+func (ibs *InstancesBySubnet) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Subnet ID",
+		"Subnet Name",
+		"CIDR",
+		"Instance ID",
+		"Instance Name",
+	)
+
+	subnetCount, emptyCount, instanceCount := 0, 0, 0
+	for _, r := range ibs.records() {
+		subnetCount++
+		if len(r.Instances) == 0 {
+			emptyCount++
+			table.Append(r.Account, r.Region, r.SubnetID, r.Name, r.CIDR, "", "")
 			continue
 		}
-
-		fmt.Printf("--- %s ---\n", subnetTemplate(subnet))
-		for _, i := range instances {
+		for _, i := range r.Instances {
 			instanceCount++
-			fmt.Printf("   * %s (%s)\n", aws.StringValue(i.InstanceId), utils.GetTagValue(i.Tags, "Name"))
+			table.Append(r.Account, r.Region, r.SubnetID, r.Name, r.CIDR, i.InstanceID, i.Name)
 		}
-		fmt.Println()
 	}
 
-	fmt.Println("------ Empty Subnets ----")
-	for _, subnet := range emptySubnets {
-		fmt.Printf("%s\n", subnetTemplate(subnet))
+	table.Summary = []string{
+		fmt.Sprintf("%d Subnets", subnetCount),
+		fmt.Sprintf("%d Empty Subnets", emptyCount),
+		fmt.Sprintf("%d Total Instances", instanceCount),
 	}
+	return table
+}
 
-	fmt.Println("----- Summary -----")
-	fmt.Printf("%d Subnets\n", len(ibs.subnets))
-	fmt.Printf("%d Empty Subnets\n", len(emptySubnets))
-	fmt.Printf("%d Total Instances\n", instanceCount)
+// Records implements views.View
+func (ibs *InstancesBySubnet) Records() interface{} {
+	return ibs.records()
 }
 
 // EmptySubnets is a view which shows subnets that are empty
 type EmptySubnets struct {
-	subnets []*ec2.Subnet
+	subnets map[models.Scope][]*ec2.Subnet
 }
 
 // NewEmptySubnets creates a new empty subnets view
-func NewEmptySubnets(subnets []*ec2.Subnet) *EmptySubnets {
+func NewEmptySubnets(subnets map[models.Scope][]*ec2.Subnet) *EmptySubnets {
 	return &EmptySubnets{subnets: subnets}
 }
 
-// Render implements views.View
-func (es *EmptySubnets) Render(w io.Writer) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{
+// EmptySubnetRecord is the JSON representation of an empty subnet.
+type EmptySubnetRecord struct {
+	Account      string `json:"account" yaml:"account"`
+	Region       string `json:"region" yaml:"region"`
+	SubnetID     string `json:"subnet_id" yaml:"subnet_id"`
+	Name         string `json:"name" yaml:"name"`
+	CIDR         string `json:"cidr" yaml:"cidr"`
+	AvailableIPs int64  `json:"available_ips" yaml:"available_ips"`
+	SubnetSize   int    `json:"subnet_size" yaml:"subnet_size"`
+	State        string `json:"state" yaml:"state"`
+	VPCID        string `json:"vpc_id" yaml:"vpc_id"`
+}
+
+func (es *EmptySubnets) records() []EmptySubnetRecord {
+	records := make([]EmptySubnetRecord, 0)
+	for _, scope := range models.SortedScopes(es.subnets) {
+		for _, s := range es.subnets[scope] {
+			if utils.IsSubnetEmpty(s) {
+				subnetSize, _ := utils.SubnetSize(aws.StringValue(s.CidrBlock))
+				records = append(records, EmptySubnetRecord{
+					Account:      scope.Account,
+					Region:       scope.Region,
+					SubnetID:     aws.StringValue(s.SubnetId),
+					Name:         utils.GetTagValue(s.Tags, "Name"),
+					CIDR:         aws.StringValue(s.CidrBlock),
+					AvailableIPs: aws.Int64Value(s.AvailableIpAddressCount),
+					SubnetSize:   subnetSize,
+					State:        aws.StringValue(s.State),
+					VPCID:        aws.StringValue(s.VpcId),
+				})
+			}
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (es *EmptySubnets) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
 		"ID",
 		"Name",
 		"CIDR",
@@ -215,40 +502,61 @@ func (es *EmptySubnets) Render(w io.Writer) {
 		"Subnet Size",
 		"State",
 		"VPC ID",
-	})
-	for _, s := range es.subnets {
-		if utils.IsSubnetEmpty(s) {
-			subnetSize, _ := utils.SubnetSize(aws.StringValue(s.CidrBlock))
-			table.Append([]string{
-				aws.StringValue(s.SubnetId),
-				utils.GetTagValue(s.Tags, "Name"),
-				aws.StringValue(s.CidrBlock),
-				strconv.FormatInt(aws.Int64Value(s.AvailableIpAddressCount), 10),
-				strconv.Itoa(subnetSize),
-				aws.StringValue(s.State),
-				aws.StringValue(s.VpcId),
-			})
-		}
+	)
+	for _, r := range es.records() {
+		table.Append(
+			r.Account,
+			r.Region,
+			r.SubnetID,
+			r.Name,
+			r.CIDR,
+			strconv.FormatInt(r.AvailableIPs, 10),
+			strconv.Itoa(r.SubnetSize),
+			r.State,
+			r.VPCID,
+		)
 	}
+	return table
+}
 
-	table.Render()
+// Records implements views.View
+func (es *EmptySubnets) Records() interface{} {
+	return es.records()
+}
+
+// Findings implements policy.Auditor
+func (es *EmptySubnets) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range es.records() {
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.SubnetID,
+			Message:  "subnet has no network interfaces",
+		})
+	}
+	return findings
 }
 
 // VPCFreeSubnets gives you available subnet ranges for a VPC
 type VPCFreeSubnets struct {
+	vpcs         map[models.Scope][]*ec2.Vpc
 	vpcSubnetMap map[*ec2.Vpc][]*ec2.Subnet
 }
 
 // NewVPCFreeSubnets creates a new view for showing free subnets in each VPC
-func NewVPCFreeSubnets(vpcs []*ec2.Vpc, subnets []*ec2.Subnet) *VPCFreeSubnets {
+func NewVPCFreeSubnets(vpcs map[models.Scope][]*ec2.Vpc, subnets map[models.Scope][]*ec2.Subnet) *VPCFreeSubnets {
 	vfs := &VPCFreeSubnets{
+		vpcs:         vpcs,
 		vpcSubnetMap: make(map[*ec2.Vpc][]*ec2.Subnet),
 	}
 
-	for _, vpc := range vpcs {
-		for _, subnet := range subnets {
-			if aws.StringValue(subnet.VpcId) == aws.StringValue(vpc.VpcId) {
-				vfs.addSubnet(vpc, subnet)
+	for scope, scopeVPCs := range vpcs {
+		for _, vpc := range scopeVPCs {
+			for _, subnet := range subnets[scope] {
+				if aws.StringValue(subnet.VpcId) == aws.StringValue(vpc.VpcId) {
+					vfs.addSubnet(vpc, subnet)
+				}
 			}
 		}
 	}
@@ -263,48 +571,341 @@ func (vfs *VPCFreeSubnets) addSubnet(v *ec2.Vpc, s *ec2.Subnet) {
 	vfs.vpcSubnetMap[v] = append(vfs.vpcSubnetMap[v], s)
 }
 
-// Render renders the view and implements view
-func (vfs *VPCFreeSubnets) Render(w io.Writer) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{
+// VPCFreeSubnetsRecord is the JSON representation of the free ranges of a
+// VPC.
+type VPCFreeSubnetsRecord struct {
+	Account          string   `json:"account" yaml:"account"`
+	Region           string   `json:"region" yaml:"region"`
+	VPCID            string   `json:"vpc_id" yaml:"vpc_id"`
+	Name             string   `json:"name" yaml:"name"`
+	CIDR             string   `json:"cidr" yaml:"cidr"`
+	AvailableSubnets []string `json:"available_subnets" yaml:"available_subnets"`
+}
+
+func (vfs *VPCFreeSubnets) records() []VPCFreeSubnetsRecord {
+	records := make([]VPCFreeSubnetsRecord, 0)
+	for _, scope := range models.SortedScopes(vfs.vpcs) {
+		for _, vpc := range vfs.vpcs[scope] {
+			subnets, ok := vfs.vpcSubnetMap[vpc]
+			if !ok {
+				continue
+			}
+
+			cidr := aws.StringValue(vpc.CidrBlock)
+			tree, err := networktree.New(cidr)
+			if err != nil {
+				logrus.Warnf("Skipping %s: %s", aws.StringValue(vpc.VpcId), err)
+				continue
+			}
+			for _, subnet := range subnets {
+				subCidr := aws.StringValue(subnet.CidrBlock)
+				_, sn, err := net.ParseCIDR(subCidr)
+				if err != nil {
+					continue
+				}
+				subtree := tree.Find(sn)
+				if subtree != nil {
+					subtree.MarkUsed()
+				}
+			}
+
+			unusedRanges := tree.UnusedRanges()
+			unusedCIDRs := make([]string, len(unusedRanges))
+			for i, n := range unusedRanges {
+				unusedCIDRs[i] = n.String()
+			}
+
+			records = append(records, VPCFreeSubnetsRecord{
+				Account:          scope.Account,
+				Region:           scope.Region,
+				VPCID:            aws.StringValue(vpc.VpcId),
+				Name:             utils.GetTagValue(vpc.Tags, "Name"),
+				CIDR:             cidr,
+				AvailableSubnets: unusedCIDRs,
+			})
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (vfs *VPCFreeSubnets) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
 		"VPC ID",
 		"VPC Name",
 		"VPC CIDR",
 		"Available Subnets",
-	})
+	)
+	for _, r := range vfs.records() {
+		table.Append(
+			r.Account,
+			r.Region,
+			r.VPCID,
+			r.Name,
+			r.CIDR,
+			strings.Join(r.AvailableSubnets, "\n"),
+		)
+	}
+	return table
+}
 
-	for vpc, subnets := range vfs.vpcSubnetMap {
-		cidr := aws.StringValue(vpc.CidrBlock)
-		tree, err := networktree.New(cidr)
-		if err != nil {
-			fmt.Fprintf(w, "%s", err)
-			continue
+// Records implements views.View
+func (vfs *VPCFreeSubnets) Records() interface{} {
+	return vfs.records()
+}
+
+// InstancesWithoutTag is a view which shows instances that don't have a
+// non-empty value for a tag.
+type InstancesWithoutTag struct {
+	instances map[models.Scope][]*ec2.Instance
+	tag       string
+}
+
+// NewInstancesWithoutTag creates a view of the instances missing the tag.
+func NewInstancesWithoutTag(instances map[models.Scope][]*ec2.Instance, tag string) *InstancesWithoutTag {
+	return &InstancesWithoutTag{instances: instances, tag: tag}
+}
+
+// InstanceWithoutTagRecord is the JSON representation of an instance
+// missing a tag.
+type InstanceWithoutTagRecord struct {
+	Account    string `json:"account" yaml:"account"`
+	Region     string `json:"region" yaml:"region"`
+	InstanceID string `json:"instance_id" yaml:"instance_id"`
+	Name       string `json:"name" yaml:"name"`
+	Tag        string `json:"tag" yaml:"tag"`
+}
+
+func (v *InstancesWithoutTag) records() []InstanceWithoutTagRecord {
+	records := make([]InstanceWithoutTagRecord, 0)
+	for _, scope := range models.SortedScopes(v.instances) {
+		for _, i := range v.instances[scope] {
+			if v.rule().Check(ec2Tags(i.Tags)) != nil {
+				records = append(records, InstanceWithoutTagRecord{
+					Account:    scope.Account,
+					Region:     scope.Region,
+					InstanceID: aws.StringValue(i.InstanceId),
+					Name:       utils.GetInstanceName(i),
+					Tag:        v.tag,
+				})
+			}
 		}
-		for _, subnet := range subnets {
-			subCidr := aws.StringValue(subnet.CidrBlock)
-			_, sn, err := net.ParseCIDR(subCidr)
-			if err != nil {
+	}
+	return records
+}
+
+// rule is the tag policy rule the instances are checked against, which only
+// requires the tag to be set.
+func (v *InstancesWithoutTag) rule() *policy.TagRule {
+	return &policy.TagRule{Key: v.tag, Resources: []string{policy.TagInstance}}
+}
+
+// Table implements views.View
+func (v *InstancesWithoutTag) Table() *Table {
+	table := NewTable("Account", "Region", "Instance ID", "Name")
+	for _, r := range v.records() {
+		table.Append(r.Account, r.Region, r.InstanceID, r.Name)
+	}
+	return table
+}
+
+// Records implements views.View
+func (v *InstancesWithoutTag) Records() interface{} {
+	return v.records()
+}
+
+// Findings implements policy.Auditor
+func (v *InstancesWithoutTag) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range v.records() {
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Medium,
+			Resource: r.InstanceID,
+			Message:  fmt.Sprintf("instance has no %q tag", r.Tag),
+		})
+	}
+	return findings
+}
+
+// stoppedAtPattern matches the time in the state transition reason of a
+// stopped instance, e.g. "User initiated (2024-03-01 12:00:00 GMT)".
+var stoppedAtPattern = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// stoppedAt returns when the instance was stopped, parsed from its state
+// transition reason. It returns false when the reason has no time, which
+// AWS doesn't always include.
+func stoppedAt(i *ec2.Instance) (time.Time, bool) {
+	m := stoppedAtPattern.FindStringSubmatch(aws.StringValue(i.StateTransitionReason))
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+// StoppedInstancesOptions are the options of a stopped instances view.
+type StoppedInstancesOptions struct {
+	// Now is when the time the instances have been stopped for is measured.
+	// Defaults to the current time.
+	Now time.Time
+	// StoppedFor is the least time an instance has to have been stopped for
+	// to be shown. Instances whose stop time is unknown are always shown.
+	StoppedFor time.Duration
+}
+
+// StoppedInstances is a view of the stopped EC2 instances, which aren't
+// billed themselves but still pay for their EBS volumes and Elastic IPs.
+type StoppedInstances struct {
+	instances map[models.Scope][]*ec2.Instance
+	volumes   map[models.Scope][]*ec2.Volume
+	addresses map[models.Scope][]*ec2.Address
+	opts      StoppedInstancesOptions
+}
+
+// NewStoppedInstances creates a new stopped instances view. The volumes and
+// addresses are needed to tell how much storage and how many Elastic IPs the
+// instances keep.
+func NewStoppedInstances(instances map[models.Scope][]*ec2.Instance, volumes map[models.Scope][]*ec2.Volume, addresses map[models.Scope][]*ec2.Address, opts StoppedInstancesOptions) *StoppedInstances {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	return &StoppedInstances{instances: instances, volumes: volumes, addresses: addresses, opts: opts}
+}
+
+// StoppedInstanceRecord is the JSON representation of a stopped instance.
+type StoppedInstanceRecord struct {
+	Account      string `json:"account" yaml:"account"`
+	Region       string `json:"region" yaml:"region"`
+	InstanceID   string `json:"instance_id" yaml:"instance_id"`
+	Name         string `json:"name" yaml:"name"`
+	InstanceType string `json:"instance_type" yaml:"instance_type"`
+	// StoppedAt and StoppedDays are nil when the stop time is unknown.
+	StoppedAt   *time.Time `json:"stopped_at" yaml:"stopped_at"`
+	StoppedDays *float64   `json:"stopped_days" yaml:"stopped_days"`
+	VolumeIDs   []string   `json:"volume_ids" yaml:"volume_ids"`
+	// VolumeSizeGB is the size of the attached volumes.
+	VolumeSizeGB int64    `json:"volume_size_gb" yaml:"volume_size_gb"`
+	ElasticIPs   []string `json:"elastic_ips" yaml:"elastic_ips"`
+}
+
+func (si *StoppedInstances) records() []StoppedInstanceRecord {
+	records := make([]StoppedInstanceRecord, 0)
+	for _, scope := range models.SortedScopes(si.instances) {
+		sizes := make(map[string]int64)
+		for _, v := range si.volumes[scope] {
+			sizes[aws.StringValue(v.VolumeId)] = aws.Int64Value(v.Size)
+		}
+		eips := make(map[string][]string)
+		for _, a := range si.addresses[scope] {
+			if a.InstanceId != nil {
+				id := aws.StringValue(a.InstanceId)
+				eips[id] = append(eips[id], aws.StringValue(a.PublicIp))
+			}
+		}
+
+		for _, i := range si.instances[scope] {
+			if i.State == nil || aws.StringValue(i.State.Name) != ec2.InstanceStateNameStopped {
 				continue
 			}
-			subtree := tree.Find(sn)
-			if subtree != nil {
-				subtree.MarkUsed()
+			r := StoppedInstanceRecord{
+				Account:      scope.Account,
+				Region:       scope.Region,
+				InstanceID:   aws.StringValue(i.InstanceId),
+				Name:         utils.GetInstanceName(i),
+				InstanceType: aws.StringValue(i.InstanceType),
+				VolumeIDs:    make([]string, 0, len(i.BlockDeviceMappings)),
+				ElasticIPs:   make([]string, 0),
+			}
+			if at, ok := stoppedAt(i); ok {
+				stoppedFor := si.opts.Now.Sub(at)
+				if stoppedFor < si.opts.StoppedFor {
+					continue
+				}
+				r.StoppedAt = &at
+				r.StoppedDays = aws.Float64(stoppedFor.Hours() / 24)
 			}
+			for _, m := range i.BlockDeviceMappings {
+				if m.Ebs == nil || m.Ebs.VolumeId == nil {
+					continue
+				}
+				r.VolumeIDs = append(r.VolumeIDs, aws.StringValue(m.Ebs.VolumeId))
+				r.VolumeSizeGB += sizes[aws.StringValue(m.Ebs.VolumeId)]
+			}
+			r.ElasticIPs = append(r.ElasticIPs, eips[r.InstanceID]...)
+			records = append(records, r)
 		}
+	}
+	return records
+}
 
-		unusedRanges := tree.UnusedRanges()
-		unusedCIDRs := make([]string, len(unusedRanges))
-		for i, n := range unusedRanges {
-			unusedCIDRs[i] = n.String()
+// Table implements views.View
+func (si *StoppedInstances) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Instance ID",
+		"Name",
+		"Instance Type",
+		"Stopped At",
+		"Stopped Days",
+		"Volume Size(GB)",
+		"Elastic IPs",
+	)
+	var sizeGB int64
+	eips := 0
+	records := si.records()
+	for _, r := range records {
+		sizeGB += r.VolumeSizeGB
+		eips += len(r.ElasticIPs)
+		stoppedAt, stoppedDays := "unknown", ""
+		if r.StoppedAt != nil {
+			stoppedAt = r.StoppedAt.Format("2006-01-02")
+			stoppedDays = strconv.Itoa(int(*r.StoppedDays))
 		}
+		table.Append(
+			r.Account,
+			r.Region,
+			r.InstanceID,
+			r.Name,
+			r.InstanceType,
+			stoppedAt,
+			stoppedDays,
+			strconv.FormatInt(r.VolumeSizeGB, 10),
+			strings.Join(r.ElasticIPs, ", "),
+		)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("%d Stopped Instances: %d GB", len(records), sizeGB),
+		fmt.Sprintf("Elastic IPs: %d", eips),
+	}
+	return table
+}
+
+// Records implements views.View
+func (si *StoppedInstances) Records() interface{} {
+	return si.records()
+}
 
-		table.Append([]string{
-			aws.StringValue(vpc.VpcId),
-			utils.GetTagValue(vpc.Tags, "Name"),
-			aws.StringValue(vpc.CidrBlock),
-			strings.Join(unusedCIDRs, "\n"),
+// Findings implements policy.Auditor
+func (si *StoppedInstances) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range si.records() {
+		message := "instance has been stopped for an unknown time"
+		if r.StoppedDays != nil {
+			message = fmt.Sprintf("instance has been stopped for %d days", int(*r.StoppedDays))
+		}
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.InstanceID,
+			Message:  message,
 		})
 	}
-
-	table.Render()
+	return findings
 }
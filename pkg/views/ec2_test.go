@@ -0,0 +1,258 @@
+package views
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeEC2Instance(instanceID, instanceType string) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:   aws.String(instanceID),
+		InstanceType: aws.String(instanceType),
+	}
+}
+
+func makeEC2Reservation(instanceType string, count int) *ec2.ReservedInstances {
+	return &ec2.ReservedInstances{
+		InstanceType:  aws.String(instanceType),
+		InstanceCount: aws.Int64(int64(count)),
+		State:         aws.String("active"),
+	}
+}
+
+func TestReservationUtilizationIsScoped(t *testing.T) {
+	west := models.Scope{Region: "us-west-2"}
+	east := models.Scope{Region: "us-east-1"}
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			west: {
+				makeEC2Instance("i-1", "m5.large"),
+				makeEC2Instance("i-2", "m5.large"),
+			},
+			east: {makeEC2Instance("i-3", "m5.large")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{
+			east: {makeEC2Reservation("m5.large", 2)},
+		},
+		ReservationUtilizationOptions{},
+	)
+
+	assert.Equal(t, 8.0, utilization.InstanceTypeReservationUtilizations[west]["m5"].Unreserved())
+	assert.Equal(t, -4.0, utilization.InstanceTypeReservationUtilizations[east]["m5"].Unreserved())
+	assert.Equal(t, true, utilization.InstanceTypeReservationUtilizations[east]["m5"].HasUnused())
+}
+
+func TestReservationUtilizationIsSizeFlexible(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	reservation := makeEC2Reservation("m5.2xlarge", 1)
+	reservation.ProductDescription = aws.String("Linux/UNIX (Amazon VPC)")
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			east: {makeEC2Instance("i-1", "m5.large"), makeEC2Instance("i-2", "m5.large")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{
+			east: {reservation},
+		},
+		ReservationUtilizationOptions{},
+	)
+
+	assert.Equal(t, []string{"m5"}, utilization.SortedInstanceTypes(east))
+	m5 := utilization.InstanceTypeReservationUtilizations[east]["m5"]
+	assert.Equal(t, 8.0, m5.NumRunning)
+	assert.Equal(t, 16.0, m5.NumReserved)
+	assert.Equal(t, -8.0, m5.Unreserved())
+}
+
+func TestReservationUtilizationMatchesExactly(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+
+	windows := makeEC2Reservation("c5.xlarge", 1)
+	windows.ProductDescription = aws.String("Windows (Amazon VPC)")
+	dedicated := makeEC2Reservation("r5.large", 1)
+	dedicated.InstanceTenancy = aws.String(ec2.TenancyDedicated)
+
+	windowsInstance := makeEC2Instance("i-1", "c5.large")
+	windowsInstance.Platform = aws.String(ec2.PlatformValuesWindows)
+	rhelInstance := makeEC2Instance("i-2", "c5.large")
+	rhelInstance.PlatformDetails = aws.String("Red Hat Enterprise Linux")
+
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			east: {windowsInstance, rhelInstance, makeEC2Instance("i-3", "r5.large")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{
+			east: {windows, dedicated},
+		},
+		ReservationUtilizationOptions{},
+	)
+
+	assert.Equal(t, []string{
+		"c5.large (Red Hat Enterprise Linux)",
+		"c5.large (Windows)",
+		"c5.xlarge (Windows)",
+		"r5",
+		"r5.large (dedicated)",
+	}, utilization.SortedInstanceTypes(east))
+	utilizations := utilization.InstanceTypeReservationUtilizations[east]
+	// Windows, RHEL and dedicated reservations can't be used for another
+	// size, platform or tenancy.
+	assert.Equal(t, []string{"i-2"}, utilizations["c5.large (Red Hat Enterprise Linux)"].Uncovered)
+	assert.Equal(t, []string{"i-1"}, utilizations["c5.large (Windows)"].Uncovered)
+	assert.Equal(t, -1.0, utilizations["c5.xlarge (Windows)"].Unreserved())
+	assert.Equal(t, -1.0, utilizations["r5.large (dedicated)"].Unreserved())
+	assert.Equal(t, []string{"i-3"}, utilizations["r5"].Uncovered)
+}
+
+func TestReservationUtilizationAppliesZonalFirst(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+
+	zonal := makeEC2Reservation("m5.large", 1)
+	zonal.Scope = aws.String(ec2.ScopeAvailabilityZone)
+	zonal.AvailabilityZone = aws.String("us-east-1a")
+	regional := makeEC2Reservation("m5.large", 1)
+
+	instance := func(id, itype, zone string) *ec2.Instance {
+		i := makeEC2Instance(id, itype)
+		i.Placement = &ec2.Placement{AvailabilityZone: aws.String(zone)}
+		return i
+	}
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			east: {
+				instance("i-1", "m5.large", "us-east-1b"),
+				instance("i-2", "m5.large", "us-east-1a"),
+				instance("i-3", "m5.xlarge", "us-east-1a"),
+			},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{
+			east: {zonal, regional},
+		},
+		ReservationUtilizationOptions{},
+	)
+
+	assert.Equal(t, []string{"m5", "m5.large (us-east-1a)"}, utilization.SortedInstanceTypes(east))
+	utilizations := utilization.InstanceTypeReservationUtilizations[east]
+	// The zonal reservation covers i-2 in its own zone, which leaves the
+	// regional reservation for the smallest of the other instances.
+	assert.Equal(t, 0.0, utilizations["m5.large (us-east-1a)"].Unreserved())
+	assert.Equal(t, 12.0, utilizations["m5"].NumRunning)
+	assert.Equal(t, 4.0, utilizations["m5"].NumReserved)
+	assert.Equal(t, []string{"i-3"}, utilizations["m5"].Uncovered)
+}
+
+func TestReservationUtilizationTable(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			east: {makeEC2Instance("i-1", "m5.large"), makeEC2Instance("i-2", "m5.xlarge")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{},
+		ReservationUtilizationOptions{},
+	)
+
+	table := utilization.Table()
+	assert.Equal(t, [][]string{
+		{"", "us-east-1", "m5", "12.00", "0.00", "", "12.00", "i-1\ni-2"},
+	}, table.Rows)
+	assert.Equal(t, []string{"2 Uncovered Instances"}, table.Summary)
+}
+
+func TestReservationUtilizationFindings(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	utilization := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{
+			east: {makeEC2Instance("i-1", "m5.large"), makeEC2Instance("i-2", "c5.large")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{
+			east: {makeEC2Reservation("m5.large", 3), makeEC2Reservation("c5.large", 1)},
+		},
+		ReservationUtilizationOptions{},
+	)
+
+	findings := utilization.Findings()
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "[medium] us-east-1 m5: 12.00 reserved but only 4.00 running", findings[0].String())
+}
+
+func TestInstancesWithoutTagFindings(t *testing.T) {
+	tagged := makeEC2Instance("i-1", "m5.large")
+	tagged.Tags = []*ec2.Tag{{Key: aws.String("cost"), Value: aws.String("platform")}}
+	view := NewInstancesWithoutTag(map[models.Scope][]*ec2.Instance{
+		{Region: "us-east-1"}: {tagged, makeEC2Instance("i-2", "m5.large")},
+	}, "cost")
+
+	findings := view.Findings()
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "i-2", findings[0].Resource)
+}
+
+func TestStoppedAt(t *testing.T) {
+	at, ok := stoppedAt(&ec2.Instance{StateTransitionReason: aws.String("User initiated (2024-03-01 12:30:00 GMT)")})
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), at)
+
+	_, ok = stoppedAt(&ec2.Instance{StateTransitionReason: aws.String("Server.ScheduledStop: Stopped due to scheduled retirement")})
+	assert.False(t, ok)
+	_, ok = stoppedAt(&ec2.Instance{})
+	assert.False(t, ok)
+}
+
+func TestStoppedInstances(t *testing.T) {
+	scope := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	instance := func(id, state, reason string, volumeIDs ...string) *ec2.Instance {
+		i := makeEC2Instance(id, "m5.large")
+		i.State = &ec2.InstanceState{Name: aws.String(state)}
+		i.StateTransitionReason = aws.String(reason)
+		for _, v := range volumeIDs {
+			i.BlockDeviceMappings = append(i.BlockDeviceMappings, &ec2.InstanceBlockDeviceMapping{
+				Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(v)},
+			})
+		}
+		return i
+	}
+
+	si := NewStoppedInstances(
+		map[models.Scope][]*ec2.Instance{scope: {
+			instance("i-running", ec2.InstanceStateNameRunning, ""),
+			instance("i-old", ec2.InstanceStateNameStopped, "User initiated (2024-01-01 00:00:00 GMT)", "vol-1", "vol-2"),
+			instance("i-recent", ec2.InstanceStateNameStopped, "User initiated (2024-03-25 00:00:00 GMT)"),
+			instance("i-unknown", ec2.InstanceStateNameStopped, ""),
+		}},
+		map[models.Scope][]*ec2.Volume{scope: {
+			{VolumeId: aws.String("vol-1"), Size: aws.Int64(100)},
+			{VolumeId: aws.String("vol-2"), Size: aws.Int64(20)},
+		}},
+		map[models.Scope][]*ec2.Address{scope: {
+			{InstanceId: aws.String("i-old"), PublicIp: aws.String("1.1.1.1")},
+			{PublicIp: aws.String("2.2.2.2")},
+		}},
+		StoppedInstancesOptions{
+			Now:        time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			StoppedFor: 30 * 24 * time.Hour,
+		},
+	)
+
+	records := si.records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, "i-old", records[0].InstanceID)
+	assert.Equal(t, 91.0, *records[0].StoppedDays)
+	assert.Equal(t, []string{"vol-1", "vol-2"}, records[0].VolumeIDs)
+	assert.Equal(t, int64(120), records[0].VolumeSizeGB)
+	assert.Equal(t, []string{"1.1.1.1"}, records[0].ElasticIPs)
+	assert.Equal(t, "i-unknown", records[1].InstanceID)
+	assert.Nil(t, records[1].StoppedAt)
+
+	findings := si.Findings()
+	assert.Len(t, findings, 2)
+	assert.Equal(t, policy.Low, findings[0].Severity)
+	assert.Equal(t, "instance has been stopped for 91 days", findings[0].Message)
+	assert.Equal(t, "instance has been stopped for an unknown time", findings[1].Message)
+
+	assert.Equal(t, []string{"2 Stopped Instances: 120 GB", "Elastic IPs: 1"}, si.Table().Summary)
+}
@@ -0,0 +1,377 @@
+package views
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+)
+
+// DefaultExpiryHorizons are the number of days ahead the expiry forecast shows
+// coverage for, unless others are given.
+var DefaultExpiryHorizons = []int{30, 60, 90}
+
+// ReservationExpiryOptions are options which modify the ReservationExpiry
+// view.
+type ReservationExpiryOptions struct {
+	// Now is when the forecast starts. Defaults to the current time.
+	Now time.Time
+	// Horizons are the number of days ahead to forecast coverage for.
+	// Defaults to DefaultExpiryHorizons.
+	Horizons []int
+	// NormalizedUnits are used on top of the built in normalized units to
+	// match size flexible reservations. It may be nil.
+	NormalizedUnits *NormalizedUnits
+}
+
+// ExpiringReservation is an active EC2 or RDS reservation along with when it
+// expires.
+type ExpiringReservation struct {
+	Scope        models.Scope
+	Service      string
+	ID           string
+	InstanceType string
+	Count        int64
+	Expires      time.Time
+}
+
+// Month returns the month the reservation expires in, e.g. "2024-05".
+func (r *ExpiringReservation) Month() string {
+	return r.Expires.UTC().Format("2006-01")
+}
+
+// CoverageForecast is how the reservation utilization of an instance type or
+// family looks now and at each of the horizons if none of the reservations
+// are renewed.
+type CoverageForecast struct {
+	Service string
+	Now     *InstanceTypeReservationUtilization
+	// Later holds the utilization at each horizon.
+	Later []*InstanceTypeReservationUtilization
+	// UncoveredIn is the first horizon, in days, at which more is running
+	// than is reserved while everything is covered now. It is 0 when the
+	// coverage doesn't drop off.
+	UncoveredIn int
+}
+
+// ReservationExpiry is a view of when EC2 and RDS reservations expire and
+// how that changes their coverage of the running instances.
+type ReservationExpiry struct {
+	Reservations []*ExpiringReservation
+	Forecasts    []*CoverageForecast
+
+	opts ReservationExpiryOptions
+}
+
+// ec2ReservationExpiry returns when the reservation ends, which is calculated
+// from the start and duration when the end isn't set.
+func ec2ReservationExpiry(r *ec2.ReservedInstances) time.Time {
+	if r.End != nil {
+		return aws.TimeValue(r.End)
+	}
+	return aws.TimeValue(r.Start).Add(time.Duration(aws.Int64Value(r.Duration)) * time.Second)
+}
+
+// rdsReservationExpiry returns when the reservation ends. RDS reservations
+// only have a start and duration.
+func rdsReservationExpiry(r *rds.ReservedDBInstance) time.Time {
+	return aws.TimeValue(r.StartTime).Add(time.Duration(aws.Int64Value(r.Duration)) * time.Second)
+}
+
+// NewReservationExpiry creates a view of the expiry of the active EC2 and RDS
+// reservations, forecasting how the utilization of the running instances
+// looks at each horizon if nothing is renewed.
+func NewReservationExpiry(
+	instances map[models.Scope][]*ec2.Instance,
+	reservations map[models.Scope][]*ec2.ReservedInstances,
+	dbs map[models.Scope][]*rds.DBInstance,
+	dbReservations map[models.Scope][]*rds.ReservedDBInstance,
+	opts ReservationExpiryOptions,
+) *ReservationExpiry {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.Horizons == nil {
+		opts.Horizons = DefaultExpiryHorizons
+	}
+	re := &ReservationExpiry{opts: opts}
+
+	for scope, rs := range reservations {
+		for _, r := range rs {
+			re.Reservations = append(re.Reservations, &ExpiringReservation{
+				Scope:        scope,
+				Service:      "ec2",
+				ID:           aws.StringValue(r.ReservedInstancesId),
+				InstanceType: aws.StringValue(r.InstanceType),
+				Count:        aws.Int64Value(r.InstanceCount),
+				Expires:      ec2ReservationExpiry(r),
+			})
+		}
+	}
+	for scope, rs := range dbReservations {
+		for _, r := range rs {
+			re.Reservations = append(re.Reservations, &ExpiringReservation{
+				Scope:        scope,
+				Service:      "rds",
+				ID:           aws.StringValue(r.ReservedDBInstanceId),
+				InstanceType: aws.StringValue(r.DBInstanceClass),
+				Count:        aws.Int64Value(r.DBInstanceCount),
+				Expires:      rdsReservationExpiry(r),
+			})
+		}
+	}
+	sort.SliceStable(re.Reservations, func(i, j int) bool {
+		a, b := re.Reservations[i], re.Reservations[j]
+		if !a.Expires.Equal(b.Expires) {
+			return a.Expires.Before(b.Expires)
+		}
+		return a.ID < b.ID
+	})
+
+	ec2Opts := ReservationUtilizationOptions{NormalizedUnits: opts.NormalizedUnits}
+	rdsOpts := RDSReservationUtilizationOptions{NormalizedUnits: opts.NormalizedUnits}
+	ec2Now := NewReservationUtilization(instances, reservations, ec2Opts)
+	rdsNow := NewRDSReservationUtilization(dbs, dbReservations, rdsOpts)
+	ec2Later := make([]map[models.Scope]map[string]*InstanceTypeReservationUtilization, len(opts.Horizons))
+	rdsLater := make([]map[models.Scope]map[string]*InstanceTypeReservationUtilization, len(opts.Horizons))
+	for h, days := range opts.Horizons {
+		at := opts.Now.Add(time.Duration(days) * 24 * time.Hour)
+		ec2Later[h] = NewReservationUtilization(instances, unexpired(reservations, ec2ReservationExpiry, at), ec2Opts).InstanceTypeReservationUtilizations
+		rdsLater[h] = NewRDSReservationUtilization(dbs, unexpired(dbReservations, rdsReservationExpiry, at), rdsOpts).InstanceTypeReservationUtilizations
+	}
+
+	re.addForecasts("ec2", ec2Now.InstanceTypeReservationUtilizations, ec2Later)
+	re.addForecasts("rds", rdsNow.InstanceTypeReservationUtilizations, rdsLater)
+	return re
+}
+
+// unexpired returns the reservations which are still active at the given
+// time.
+func unexpired[R any](reservations map[models.Scope][]R, expiry func(R) time.Time, at time.Time) map[models.Scope][]R {
+	filtered := make(map[models.Scope][]R)
+	for scope, rs := range reservations {
+		for _, r := range rs {
+			if expiry(r).After(at) {
+				filtered[scope] = append(filtered[scope], r)
+			}
+		}
+	}
+	return filtered
+}
+
+// withoutCounts returns a utilization for the same instance type as iru with
+// nothing running or reserved.
+func withoutCounts(iru *InstanceTypeReservationUtilization) *InstanceTypeReservationUtilization {
+	return &InstanceTypeReservationUtilization{
+		Scope:            iru.Scope,
+		InstanceType:     iru.InstanceType,
+		Platform:         iru.Platform,
+		Tenancy:          iru.Tenancy,
+		AvailabilityZone: iru.AvailabilityZone,
+	}
+}
+
+// addForecasts adds a forecast for each of the utilizations which change at
+// any of the horizons.
+func (re *ReservationExpiry) addForecasts(service string, now map[models.Scope]map[string]*InstanceTypeReservationUtilization, later []map[models.Scope]map[string]*InstanceTypeReservationUtilization) {
+	// Rows can appear later, e.g. once a zonal reservation expires and its
+	// instance is counted with the rest of its family, so the rows of every
+	// horizon are included.
+	rows := make(map[models.Scope]map[string]*InstanceTypeReservationUtilization)
+	for _, utilizations := range append([]map[models.Scope]map[string]*InstanceTypeReservationUtilization{now}, later...) {
+		for scope, byType := range utilizations {
+			if _, ok := rows[scope]; !ok {
+				rows[scope] = make(map[string]*InstanceTypeReservationUtilization)
+			}
+			for k, iru := range byType {
+				if _, ok := rows[scope][k]; ok {
+					continue
+				}
+				if current, ok := now[scope][k]; ok {
+					rows[scope][k] = current
+				} else {
+					rows[scope][k] = withoutCounts(iru)
+				}
+			}
+		}
+	}
+
+	for _, scope := range models.SortedScopes(rows) {
+		keys := make([]string, 0, len(rows[scope]))
+		for k := range rows[scope] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			current := rows[scope][k]
+			forecast := &CoverageForecast{Service: service, Now: current, Later: make([]*InstanceTypeReservationUtilization, len(later))}
+			changed := false
+			for h, utilizations := range later {
+				future, ok := utilizations[scope][k]
+				if !ok {
+					future = withoutCounts(current)
+				}
+				forecast.Later[h] = future
+				if future.NumReserved != current.NumReserved || future.NumRunning != current.NumRunning {
+					changed = true
+				}
+				if forecast.UncoveredIn == 0 && current.Unreserved() <= 0 && future.Unreserved() > 0 {
+					forecast.UncoveredIn = re.opts.Horizons[h]
+				}
+			}
+			if changed {
+				re.Forecasts = append(re.Forecasts, forecast)
+			}
+		}
+	}
+}
+
+// ExpiringReservationRecord is the JSON representation of a reservation and
+// when it expires.
+type ExpiringReservationRecord struct {
+	Account      string    `json:"account" yaml:"account"`
+	Region       string    `json:"region" yaml:"region"`
+	Service      string    `json:"service" yaml:"service"`
+	ID           string    `json:"id" yaml:"id"`
+	InstanceType string    `json:"instance_type" yaml:"instance_type"`
+	Count        int64     `json:"count" yaml:"count"`
+	Expires      time.Time `json:"expires" yaml:"expires"`
+	Month        string    `json:"month" yaml:"month"`
+}
+
+// HorizonRecord is the JSON representation of the coverage at a horizon.
+type HorizonRecord struct {
+	Days       int     `json:"days" yaml:"days"`
+	Running    float64 `json:"running" yaml:"running"`
+	Reserved   float64 `json:"reserved" yaml:"reserved"`
+	Unreserved float64 `json:"unreserved" yaml:"unreserved"`
+}
+
+// CoverageForecastRecord is the JSON representation of a CoverageForecast.
+type CoverageForecastRecord struct {
+	ReservationUtilizationRecord `yaml:",inline"`
+	Service                      string          `json:"service" yaml:"service"`
+	Horizons                     []HorizonRecord `json:"horizons" yaml:"horizons"`
+	UncoveredIn                  int             `json:"uncovered_in" yaml:"uncovered_in"`
+}
+
+// ReservationExpiryRecord is the JSON representation of a ReservationExpiry.
+type ReservationExpiryRecord struct {
+	Reservations []ExpiringReservationRecord `json:"reservations" yaml:"reservations"`
+	Forecasts    []CoverageForecastRecord    `json:"forecasts" yaml:"forecasts"`
+}
+
+func (re *ReservationExpiry) records() ReservationExpiryRecord {
+	record := ReservationExpiryRecord{
+		Reservations: make([]ExpiringReservationRecord, 0, len(re.Reservations)),
+		Forecasts:    make([]CoverageForecastRecord, 0, len(re.Forecasts)),
+	}
+	for _, r := range re.Reservations {
+		record.Reservations = append(record.Reservations, ExpiringReservationRecord{
+			Account:      r.Scope.Account,
+			Region:       r.Scope.Region,
+			Service:      r.Service,
+			ID:           r.ID,
+			InstanceType: r.InstanceType,
+			Count:        r.Count,
+			Expires:      r.Expires,
+			Month:        r.Month(),
+		})
+	}
+	for _, f := range re.Forecasts {
+		horizons := make([]HorizonRecord, len(f.Later))
+		for h, iru := range f.Later {
+			horizons[h] = HorizonRecord{
+				Days:       re.opts.Horizons[h],
+				Running:    iru.NumRunning,
+				Reserved:   iru.NumReserved,
+				Unreserved: iru.Unreserved(),
+			}
+		}
+		record.Forecasts = append(record.Forecasts, CoverageForecastRecord{
+			ReservationUtilizationRecord: f.Now.Record(),
+			Service:                      f.Service,
+			Horizons:                     horizons,
+			UncoveredIn:                  f.UncoveredIn,
+		})
+	}
+	return record
+}
+
+// Table implements views.View. Each instance type or family with expiring
+// reservations gets a row with the reserved count at each horizon, while the
+// summary shows how many reservations expire each month.
+func (re *ReservationExpiry) Table() *Table {
+	header := []string{"Account", "Region", "Service", "Instance Type/Family", "Running", "Reserved"}
+	for _, days := range re.opts.Horizons {
+		header = append(header, fmt.Sprintf("Reserved in %dd", days))
+	}
+	table := NewTable(append(header, "Uncovered In")...)
+
+	for _, f := range re.Forecasts {
+		row := []string{
+			f.Now.Scope.Account,
+			f.Now.Scope.Region,
+			f.Service,
+			f.Now.String(),
+			fmt.Sprintf("%.2f", f.Now.NumRunning),
+			fmt.Sprintf("%.2f", f.Now.NumReserved),
+		}
+		for _, iru := range f.Later {
+			row = append(row, fmt.Sprintf("%.2f", iru.NumReserved))
+		}
+		uncoveredIn := ""
+		if f.UncoveredIn > 0 {
+			uncoveredIn = strconv.Itoa(f.UncoveredIn) + " days"
+		}
+		table.Append(append(row, uncoveredIn)...)
+	}
+
+	months := make([]string, 0)
+	counts := make(map[string]int64)
+	for _, r := range re.Reservations {
+		if _, ok := counts[r.Month()]; !ok {
+			months = append(months, r.Month())
+		}
+		counts[r.Month()] += r.Count
+	}
+	for _, month := range months {
+		table.Summary = append(table.Summary, fmt.Sprintf("%s: %d reserved instances expire", month, counts[month]))
+	}
+	return table
+}
+
+// Records implements views.View
+func (re *ReservationExpiry) Records() interface{} {
+	return re.records()
+}
+
+// Findings implements policy.Auditor. Coverage which drops off within the
+// first horizon is more urgent than coverage which drops off later.
+func (re *ReservationExpiry) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, f := range re.Forecasts {
+		if f.UncoveredIn == 0 {
+			continue
+		}
+		severity := policy.Low
+		if len(re.opts.Horizons) > 0 && f.UncoveredIn <= re.opts.Horizons[0] {
+			severity = policy.Medium
+		}
+		findings = append(findings, policy.Finding{
+			Scope:    f.Now.Scope,
+			Severity: severity,
+			Resource: strings.Join([]string{f.Service, f.Now.String()}, " "),
+			Message:  fmt.Sprintf("reservations expire and leave it uncovered within %d days", f.UncoveredIn),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,120 @@
+package views
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+const year = int64(365 * 24 * 60 * 60)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestReservationExpiry(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+
+	ending := makeEC2Reservation("m5.large", 1)
+	ending.ReservedInstancesId = aws.String("ri-1")
+	ending.End = aws.Time(date(2024, time.January, 20))
+	// Without an end, the expiry is calculated from the start and duration.
+	later := makeEC2Reservation("m5.large", 1)
+	later.ReservedInstancesId = aws.String("ri-2")
+	later.Start = aws.Time(date(2023, time.March, 14))
+	later.Duration = aws.Int64(year)
+
+	db := makeRDSReservation("db.m5.large", "postgresql", 1)
+	db.ReservedDBInstanceId = aws.String("rdb-1")
+	db.StartTime = aws.Time(date(2023, time.February, 10))
+	db.Duration = aws.Int64(year)
+
+	expiry := NewReservationExpiry(
+		map[models.Scope][]*ec2.Instance{
+			east: {makeEC2Instance("i-1", "m5.large"), makeEC2Instance("i-2", "m5.large")},
+		},
+		map[models.Scope][]*ec2.ReservedInstances{east: {later, ending}},
+		map[models.Scope][]*rds.DBInstance{
+			east: {makeRDSInstance("db-1", "db.m5.large", "postgres", false)},
+		},
+		map[models.Scope][]*rds.ReservedDBInstance{east: {db}},
+		ReservationExpiryOptions{Now: date(2024, time.January, 1)},
+	)
+
+	ids := make([]string, 0)
+	for _, r := range expiry.Reservations {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []string{"ri-1", "rdb-1", "ri-2"}, ids)
+	assert.Equal(t, date(2024, time.March, 13), expiry.Reservations[2].Expires)
+
+	assert.Len(t, expiry.Forecasts, 2)
+	m5 := expiry.Forecasts[0]
+	assert.Equal(t, "ec2", m5.Service)
+	assert.Equal(t, "m5", m5.Now.String())
+	assert.Equal(t, 8.0, m5.Now.NumReserved)
+	assert.Equal(t, []float64{4, 4, 0}, reservedLater(m5))
+	assert.Equal(t, 30, m5.UncoveredIn)
+
+	postgres := expiry.Forecasts[1]
+	assert.Equal(t, "rds", postgres.Service)
+	assert.Equal(t, "postgresql/db.m5", postgres.Now.String())
+	assert.Equal(t, []float64{4, 0, 0}, reservedLater(postgres))
+	assert.Equal(t, 60, postgres.UncoveredIn)
+
+	table := expiry.Table()
+	assert.Equal(t, []string{
+		"2024-01: 1 reserved instances expire",
+		"2024-02: 1 reserved instances expire",
+		"2024-03: 1 reserved instances expire",
+	}, table.Summary)
+	assert.Equal(t, []string{"", "us-east-1", "ec2", "m5", "8.00", "8.00", "4.00", "4.00", "0.00", "30 days"}, table.Rows[0])
+
+	findings := expiry.Findings()
+	assert.Len(t, findings, 2)
+	assert.Equal(t, policy.Medium, findings[0].Severity)
+	assert.Equal(t, policy.Low, findings[1].Severity)
+	assert.Equal(t, "[low] us-east-1 rds postgresql/db.m5: reservations expire and leave it uncovered within 60 days", findings[1].String())
+}
+
+func TestReservationExpiryOfZonalReservation(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+
+	zonal := makeEC2Reservation("m5.large", 1)
+	zonal.Scope = aws.String(ec2.ScopeAvailabilityZone)
+	zonal.AvailabilityZone = aws.String("us-east-1a")
+	zonal.End = aws.Time(date(2024, time.January, 10))
+	instance := makeEC2Instance("i-1", "m5.large")
+	instance.Placement = &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")}
+
+	expiry := NewReservationExpiry(
+		map[models.Scope][]*ec2.Instance{east: {instance}},
+		map[models.Scope][]*ec2.ReservedInstances{east: {zonal}},
+		nil,
+		nil,
+		ReservationExpiryOptions{Now: date(2024, time.January, 1), Horizons: []int{30}},
+	)
+
+	// Once the zonal reservation expires, the instance is counted with the
+	// rest of its family, which isn't reserved.
+	assert.Len(t, expiry.Forecasts, 2)
+	assert.Equal(t, "m5", expiry.Forecasts[0].Now.String())
+	assert.Equal(t, 4.0, expiry.Forecasts[0].Later[0].Unreserved())
+	assert.Equal(t, 30, expiry.Forecasts[0].UncoveredIn)
+	assert.Equal(t, "m5.large (us-east-1a)", expiry.Forecasts[1].Now.String())
+	assert.Equal(t, 0, expiry.Forecasts[1].UncoveredIn)
+}
+
+func reservedLater(f *CoverageForecast) []float64 {
+	reserved := make([]float64, len(f.Later))
+	for h, iru := range f.Later {
+		reserved[h] = iru.NumReserved
+	}
+	return reserved
+}
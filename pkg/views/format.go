@@ -0,0 +1,116 @@
+package views
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Formatter writes a view to w in a particular output format.
+type Formatter interface {
+	Format(w io.Writer, v View) error
+}
+
+// FormatterFunc adapts a function to a Formatter.
+type FormatterFunc func(w io.Writer, v View) error
+
+// Format implements Formatter.
+func (f FormatterFunc) Format(w io.Writer, v View) error {
+	return f(w, v)
+}
+
+var formatters = map[string]Formatter{
+	"table":    FormatterFunc(formatTable),
+	"json":     FormatterFunc(formatJSON),
+	"csv":      FormatterFunc(formatCSV),
+	"yaml":     FormatterFunc(formatYAML),
+	"markdown": FormatterFunc(formatMarkdown),
+}
+
+// Formats are the names of the supported output formats.
+var Formats = []string{"table", "json", "csv", "yaml", "markdown"}
+
+// NewFormatter returns the formatter for the named output format.
+func NewFormatter(name string) (Formatter, error) {
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %q, must be one of: %s", name, strings.Join(Formats, ", "))
+	}
+	return f, nil
+}
+
+func formatTable(w io.Writer, v View) error {
+	t := v.Table()
+	for _, line := range t.Summary {
+		fmt.Fprintln(w, line)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(t.Header)
+	table.AppendBulk(t.Rows)
+	table.Render()
+	return nil
+}
+
+func formatCSV(w io.Writer, v View) error {
+	t := v.Table()
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", "<br>")
+
+func formatMarkdown(w io.Writer, v View) error {
+	t := v.Table()
+	for _, line := range t.Summary {
+		fmt.Fprintf(w, "%s  \n", line)
+	}
+	if len(t.Summary) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	writeRow := func(row []string) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownEscaper.Replace(cell)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	writeRow(t.Header)
+	separator := make([]string, len(t.Header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeRow(separator)
+	for _, row := range t.Rows {
+		writeRow(row)
+	}
+	return nil
+}
+
+func formatJSON(w io.Writer, v View) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v.Records())
+}
+
+func formatYAML(w io.Writer, v View) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v.Records()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,64 @@
+package views
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	Name  string `json:"name" yaml:"name"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+type testView struct{}
+
+func (testView) Table() *Table {
+	t := NewTable("Name", "Count")
+	t.Append("a|b", "1")
+	t.Append("c\nd", "2")
+	t.Summary = []string{"2 things"}
+	return t
+}
+
+func (testView) Records() interface{} {
+	return []testRecord{{"a|b", 1}, {"c\nd", 2}}
+}
+
+func format(t *testing.T, name string) string {
+	t.Helper()
+	f, err := NewFormatter(name)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, f.Format(&buf, testView{}))
+	return buf.String()
+}
+
+func TestFormatters(t *testing.T) {
+	assert.Equal(t, "Name,Count\na|b,1\n\"c\nd\",2\n", format(t, "csv"))
+
+	assert.Equal(t, "2 things  \n\n| Name | Count |\n| --- | --- |\n| a\\|b | 1 |\n| c<br>d | 2 |\n", format(t, "markdown"))
+
+	assert.Equal(t, `[
+  {
+    "name": "a|b",
+    "count": 1
+  },
+  {
+    "name": "c\nd",
+    "count": 2
+  }
+]
+`, format(t, "json"))
+
+	assert.Equal(t, "- name: a|b\n  count: 1\n- name: |-\n    c\n    d\n  count: 2\n", format(t, "yaml"))
+
+	table := format(t, "table")
+	assert.Contains(t, table, "2 things\n")
+	assert.Contains(t, table, "NAME")
+
+	_, err := NewFormatter("xml")
+	assert.NotNil(t, err)
+}
@@ -2,54 +2,124 @@ package views
 
 import (
 	"fmt"
-	"os"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
-	"github.com/olekukonko/tablewriter"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
 )
 
+// UnusedAccessKeyAge is how long an access key can go without being used
+// before it is reported as a finding.
+const UnusedAccessKeyAge = 90 * 24 * time.Hour
+
 type IAMAccessKeysAudit struct {
-	Users    map[string][]*iam.AccessKeyMetadata
+	// Users maps each scope to the access keys of its users, keyed by user name.
+	Users    map[models.Scope]map[string][]*iam.AccessKeyMetadata
 	LastUsed map[string]*iam.AccessKeyLastUsed
 }
 
-// Print prints the table to stdout
-func (v *IAMAccessKeysAudit) Print() {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
+// AccessKeyRecord is the JSON representation of an access key and when it
+// was last used. LastUsed is null if the key has never been used.
+type AccessKeyRecord struct {
+	Account         string     `json:"account" yaml:"account"`
+	UserName        string     `json:"user_name" yaml:"user_name"`
+	AccessKeyID     string     `json:"access_key_id" yaml:"access_key_id"`
+	Status          string     `json:"status" yaml:"status"`
+	AgeDays         float64    `json:"age_days" yaml:"age_days"`
+	LastUsed        *time.Time `json:"last_used" yaml:"last_used"`
+	LastUsedService string     `json:"last_used_service" yaml:"last_used_service"`
+}
+
+func (v *IAMAccessKeysAudit) records() []AccessKeyRecord {
+	records := make([]AccessKeyRecord, 0)
+	for _, scope := range models.SortedScopes(v.Users) {
+		usernames := make([]string, 0, len(v.Users[scope]))
+		for username := range v.Users[scope] {
+			usernames = append(usernames, username)
+		}
+		sort.Strings(usernames)
+
+		for _, username := range usernames {
+			for _, key := range v.Users[scope][username] {
+				if key != nil {
+					accessKeyID := aws.StringValue(key.AccessKeyId)
+					keyAge := time.Since(*key.CreateDate)
+					record := AccessKeyRecord{
+						Account:     scope.Account,
+						UserName:    username,
+						AccessKeyID: accessKeyID,
+						Status:      aws.StringValue(key.Status),
+						AgeDays:     keyAge.Hours() / 24,
+					}
+					if v.LastUsed[accessKeyID] != nil && v.LastUsed[accessKeyID].LastUsedDate != nil {
+						record.LastUsed = v.LastUsed[accessKeyID].LastUsedDate
+						record.LastUsedService = aws.StringValue(v.LastUsed[accessKeyID].ServiceName)
+					}
+					records = append(records, record)
+				}
+			}
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (v *IAMAccessKeysAudit) Table() *Table {
+	table := NewTable(
+		"Account",
 		"User Name",
 		"Access Key ID",
 		"Status",
 		"Age Days",
 		"Last Used",
 		"Last Used Service",
-	})
-
-	for username, keys := range v.Users {
-		for _, key := range keys {
-			if key != nil {
-				accessKeyID := aws.StringValue(key.AccessKeyId)
-				keyAge := time.Since(*key.CreateDate)
-				var lastUsed string
-				var lastUsedService string
-				if v.LastUsed[accessKeyID] != nil && v.LastUsed[accessKeyID].LastUsedDate != nil {
-					lastUsed = v.LastUsed[accessKeyID].LastUsedDate.Local().String()
-					lastUsedService = aws.StringValue(v.LastUsed[accessKeyID].ServiceName)
-				}
-				table.Append([]string{
-					username,
-					accessKeyID,
-					aws.StringValue(key.Status),
-					fmt.Sprintf("%0.2f", (keyAge.Hours() / 24)),
-					lastUsed,
-					lastUsedService,
-				})
-			}
+	)
+	for _, r := range v.records() {
+		var lastUsed string
+		if r.LastUsed != nil {
+			lastUsed = r.LastUsed.Local().String()
 		}
-
+		table.Append(
+			r.Account,
+			r.UserName,
+			r.AccessKeyID,
+			r.Status,
+			fmt.Sprintf("%0.2f", r.AgeDays),
+			lastUsed,
+			r.LastUsedService,
+		)
 	}
+	return table
+}
 
-	table.Render()
+// Records implements views.View
+func (v *IAMAccessKeysAudit) Records() interface{} {
+	return v.records()
+}
+
+// Findings implements policy.Auditor. Access keys which have never been used
+// or haven't been used in UnusedAccessKeyAge should be deactivated.
+func (v *IAMAccessKeysAudit) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range v.records() {
+		var message string
+		switch {
+		case r.LastUsed == nil:
+			message = "access key has never been used"
+		case time.Since(*r.LastUsed) > UnusedAccessKeyAge:
+			message = fmt.Sprintf("access key hasn't been used in %.0f days", time.Since(*r.LastUsed).Hours()/24)
+		default:
+			continue
+		}
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account},
+			Severity: policy.High,
+			Resource: r.UserName + "/" + r.AccessKeyID,
+			Message:  message,
+		})
+	}
+	return findings
 }
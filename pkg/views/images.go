@@ -0,0 +1,179 @@
+package views
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+)
+
+// ImageUsers are the resources which can launch instances from an AMI.
+type ImageUsers struct {
+	// Instances in any state but terminated keep their AMI in use, since
+	// stopped instances can be started again.
+	Instances              map[models.Scope][]*ec2.Instance
+	LaunchTemplateVersions map[models.Scope][]*ec2.LaunchTemplateVersion
+	LaunchConfigurations   map[models.Scope][]*autoscaling.LaunchConfiguration
+}
+
+// usedImages returns the IDs of the AMIs used in each scope.
+func (u ImageUsers) usedImages() map[models.Scope]map[string]bool {
+	used := make(map[models.Scope]map[string]bool)
+	use := func(scope models.Scope, id *string) {
+		if id == nil {
+			return
+		}
+		if used[scope] == nil {
+			used[scope] = make(map[string]bool)
+		}
+		used[scope][aws.StringValue(id)] = true
+	}
+
+	for scope, instances := range u.Instances {
+		for _, i := range instances {
+			if i.State != nil && aws.StringValue(i.State.Name) == ec2.InstanceStateNameTerminated {
+				continue
+			}
+			use(scope, i.ImageId)
+		}
+	}
+	for scope, versions := range u.LaunchTemplateVersions {
+		for _, v := range versions {
+			if v.LaunchTemplateData != nil {
+				use(scope, v.LaunchTemplateData.ImageId)
+			}
+		}
+	}
+	for scope, configs := range u.LaunchConfigurations {
+		for _, lc := range configs {
+			use(scope, lc.ImageId)
+		}
+	}
+	return used
+}
+
+// UnusedImages is a view of the AMIs owned by the account which no instance,
+// launch template or launch configuration uses, so they can be deregistered.
+type UnusedImages struct {
+	images map[models.Scope][]*ec2.Image
+	users  ImageUsers
+}
+
+// NewUnusedImages creates a new unused images view.
+func NewUnusedImages(images map[models.Scope][]*ec2.Image, users ImageUsers) *UnusedImages {
+	return &UnusedImages{images: images, users: users}
+}
+
+// NumImages returns the number of images
+func (ui *UnusedImages) NumImages() int {
+	n := 0
+	for _, images := range ui.images {
+		n += len(images)
+	}
+	return n
+}
+
+// UnusedImageRecord is the JSON representation of an unused AMI.
+type UnusedImageRecord struct {
+	Account string     `json:"account" yaml:"account"`
+	Region  string     `json:"region" yaml:"region"`
+	ImageID string     `json:"image_id" yaml:"image_id"`
+	Name    string     `json:"name" yaml:"name"`
+	Created *time.Time `json:"created" yaml:"created"`
+	// SnapshotIDs are the EBS snapshots backing the image, which are only
+	// deleted along with it if that's done separately.
+	SnapshotIDs    []string `json:"snapshot_ids" yaml:"snapshot_ids"`
+	SnapshotSizeGB int64    `json:"snapshot_size_gb" yaml:"snapshot_size_gb"`
+}
+
+func (ui *UnusedImages) records() []UnusedImageRecord {
+	used := ui.users.usedImages()
+	records := make([]UnusedImageRecord, 0)
+	for _, scope := range models.SortedScopes(ui.images) {
+		for _, image := range ui.images[scope] {
+			if used[scope][aws.StringValue(image.ImageId)] {
+				continue
+			}
+			r := UnusedImageRecord{
+				Account:     scope.Account,
+				Region:      scope.Region,
+				ImageID:     aws.StringValue(image.ImageId),
+				Name:        aws.StringValue(image.Name),
+				SnapshotIDs: make([]string, 0),
+			}
+			if created, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate)); err == nil {
+				r.Created = &created
+			}
+			for _, m := range image.BlockDeviceMappings {
+				if m.Ebs == nil || m.Ebs.SnapshotId == nil {
+					continue
+				}
+				r.SnapshotIDs = append(r.SnapshotIDs, aws.StringValue(m.Ebs.SnapshotId))
+				r.SnapshotSizeGB += aws.Int64Value(m.Ebs.VolumeSize)
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (ui *UnusedImages) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Image ID",
+		"Name",
+		"Created",
+		"Snapshots",
+		"Snapshot Size(GB)",
+	)
+	var sizeGB int64
+	records := ui.records()
+	for _, r := range records {
+		sizeGB += r.SnapshotSizeGB
+		created := ""
+		if r.Created != nil {
+			created = r.Created.Format("2006-01-02")
+		}
+		table.Append(
+			r.Account,
+			r.Region,
+			r.ImageID,
+			r.Name,
+			created,
+			strings.Join(r.SnapshotIDs, ", "),
+			strconv.FormatInt(r.SnapshotSizeGB, 10),
+		)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("%d of %d Images Aren't Used", len(records), ui.NumImages()),
+		fmt.Sprintf("Total Snapshot storage: %d GB", sizeGB),
+	}
+	return table
+}
+
+// Records implements views.View
+func (ui *UnusedImages) Records() interface{} {
+	return ui.records()
+}
+
+// Findings implements policy.Auditor
+func (ui *UnusedImages) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range ui.records() {
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.ImageID,
+			Message:  "image isn't used by any instance, launch template or launch configuration",
+		})
+	}
+	return findings
+}
@@ -0,0 +1,101 @@
+package views
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeImage(id string, snapshots ...string) *ec2.Image {
+	image := &ec2.Image{
+		ImageId:      aws.String(id),
+		Name:         aws.String("build-" + id),
+		CreationDate: aws.String("2024-03-01T12:00:00.000Z"),
+	}
+	for _, s := range snapshots {
+		image.BlockDeviceMappings = append(image.BlockDeviceMappings, &ec2.BlockDeviceMapping{
+			DeviceName: aws.String("/dev/xvda"),
+			Ebs:        &ec2.EbsBlockDevice{SnapshotId: aws.String(s), VolumeSize: aws.Int64(8)},
+		})
+	}
+	return image
+}
+
+func TestUnusedImages(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	west := models.Scope{Account: "111111111111", Region: "us-west-2"}
+	instance := func(id, imageID, state string) *ec2.Instance {
+		return &ec2.Instance{
+			InstanceId: aws.String(id),
+			ImageId:    aws.String(imageID),
+			State:      &ec2.InstanceState{Name: aws.String(state)},
+		}
+	}
+
+	unused := makeImage("ami-unused", "snap-1", "snap-2")
+	unused.BlockDeviceMappings = append(unused.BlockDeviceMappings, &ec2.BlockDeviceMapping{
+		DeviceName:  aws.String("/dev/sdb"),
+		VirtualName: aws.String("ephemeral0"),
+	})
+	ui := NewUnusedImages(
+		map[models.Scope][]*ec2.Image{
+			east: {
+				makeImage("ami-running"),
+				makeImage("ami-stopped"),
+				makeImage("ami-terminated"),
+				makeImage("ami-template"),
+				makeImage("ami-config"),
+				unused,
+			},
+			// AMIs are regional, so the instance in us-east-1 doesn't use it.
+			west: {makeImage("ami-running", "snap-3")},
+		},
+		ImageUsers{
+			Instances: map[models.Scope][]*ec2.Instance{east: {
+				instance("i-1", "ami-running", ec2.InstanceStateNameRunning),
+				instance("i-2", "ami-stopped", ec2.InstanceStateNameStopped),
+				instance("i-3", "ami-terminated", ec2.InstanceStateNameTerminated),
+			}},
+			LaunchTemplateVersions: map[models.Scope][]*ec2.LaunchTemplateVersion{east: {
+				{LaunchTemplateData: &ec2.ResponseLaunchTemplateData{ImageId: aws.String("ami-template")}},
+				{LaunchTemplateData: &ec2.ResponseLaunchTemplateData{}},
+			}},
+			LaunchConfigurations: map[models.Scope][]*autoscaling.LaunchConfiguration{east: {
+				{ImageId: aws.String("ami-config")},
+			}},
+		},
+	)
+
+	records := ui.records()
+	ids := make([]string, 0)
+	for _, r := range records {
+		ids = append(ids, r.Region+" "+r.ImageID)
+	}
+	assert.Equal(t, []string{
+		"us-east-1 ami-terminated",
+		"us-east-1 ami-unused",
+		"us-west-2 ami-running",
+	}, ids)
+
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, UnusedImageRecord{
+		Account:        "111111111111",
+		Region:         "us-east-1",
+		ImageID:        "ami-unused",
+		Name:           "build-ami-unused",
+		Created:        &created,
+		SnapshotIDs:    []string{"snap-1", "snap-2"},
+		SnapshotSizeGB: 16,
+	}, records[1])
+
+	assert.Equal(t, []string{
+		"3 of 7 Images Aren't Used",
+		"Total Snapshot storage: 24 GB",
+	}, ui.Table().Summary)
+	assert.Len(t, ui.Findings(), 3)
+}
@@ -1,22 +1,24 @@
 package views
 
 import (
-	"os"
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/olekukonko/tablewriter"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
 )
 
 // SecurityGroupAudit is a view for auditing security groups.
 type SecurityGroupAudit struct {
-	securityGroups    []*ec2.SecurityGroup
-	networkInterfaces []*ec2.NetworkInterface
+	securityGroups    map[models.Scope][]*ec2.SecurityGroup
+	networkInterfaces map[models.Scope][]*ec2.NetworkInterface
 }
 
 // NewSecurityGroupAudit creates and initializes a new security group audit
-func NewSecurityGroupAudit(securityGroups []*ec2.SecurityGroup, networkInterfaces []*ec2.NetworkInterface) *SecurityGroupAudit {
+func NewSecurityGroupAudit(securityGroups map[models.Scope][]*ec2.SecurityGroup, networkInterfaces map[models.Scope][]*ec2.NetworkInterface) *SecurityGroupAudit {
 	return &SecurityGroupAudit{
 		securityGroups:    securityGroups,
 		networkInterfaces: networkInterfaces,
@@ -35,30 +37,230 @@ func (sga *SecurityGroupAudit) interfaceHasSecurityGroup(ifc *ec2.NetworkInterfa
 	return false
 }
 
-// Print prints the SecurityGroupAudit view
-func (sga *SecurityGroupAudit) Print() {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
+// SecurityGroupRecord is the JSON representation of a security group and
+// how many network interfaces use it.
+type SecurityGroupRecord struct {
+	Account   string `json:"account" yaml:"account"`
+	Region    string `json:"region" yaml:"region"`
+	VPCID     string `json:"vpc_id" yaml:"vpc_id"`
+	GroupID   string `json:"group_id" yaml:"group_id"`
+	GroupName string `json:"group_name" yaml:"group_name"`
+	Usages    int    `json:"usages" yaml:"usages"`
+}
+
+func (sga *SecurityGroupAudit) records() []SecurityGroupRecord {
+	records := make([]SecurityGroupRecord, 0)
+	for _, scope := range models.SortedScopes(sga.securityGroups) {
+		for _, sg := range sga.securityGroups[scope] {
+			usages := 0
+			for _, ifc := range sga.networkInterfaces[scope] {
+				if sga.interfaceHasSecurityGroup(ifc, sg) {
+					usages++
+				}
+			}
+			records = append(records, SecurityGroupRecord{
+				Account:   scope.Account,
+				Region:    scope.Region,
+				VPCID:     aws.StringValue(sg.VpcId),
+				GroupID:   aws.StringValue(sg.GroupId),
+				GroupName: aws.StringValue(sg.GroupName),
+				Usages:    usages,
+			})
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (sga *SecurityGroupAudit) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
 		"VPC ID",
 		"Group ID",
 		"Group Name",
 		"Usages(Number of Interfaces)",
-	})
+	)
+	for _, r := range sga.records() {
+		table.Append(r.Account, r.Region, r.VPCID, r.GroupID, r.GroupName, strconv.Itoa(r.Usages))
+	}
+	return table
+}
+
+// Records implements views.View
+func (sga *SecurityGroupAudit) Records() interface{} {
+	return sga.records()
+}
+
+// Findings implements policy.Auditor. Security groups which aren't used by
+// any network interface are reported, except for the default groups which
+// can't be deleted.
+func (sga *SecurityGroupAudit) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range sga.records() {
+		if r.Usages == 0 && r.GroupName != "default" {
+			findings = append(findings, policy.Finding{
+				Scope:    models.Scope{Account: r.Account, Region: r.Region},
+				Severity: policy.Low,
+				Resource: r.GroupID,
+				Message:  "security group is not used by any network interface",
+			})
+		}
+	}
+	return findings
+}
+
+// The kinds of unattached network resources.
+const (
+	UnattachedInterface = "network_interface"
+	UnattachedAddress   = "elastic_ip"
+)
+
+// UnattachedNetwork is a view of the network interfaces which aren't attached
+// to anything and the Elastic IPs which aren't associated with anything. Both
+// are usually left behind by a service which created them and they cost
+// money, since public IPv4 addresses are billed while they're allocated.
+type UnattachedNetwork struct {
+	networkInterfaces map[models.Scope][]*ec2.NetworkInterface
+	addresses         map[models.Scope][]*ec2.Address
+}
+
+// NewUnattachedNetwork creates a new unattached network view
+func NewUnattachedNetwork(networkInterfaces map[models.Scope][]*ec2.NetworkInterface, addresses map[models.Scope][]*ec2.Address) *UnattachedNetwork {
+	return &UnattachedNetwork{networkInterfaces: networkInterfaces, addresses: addresses}
+}
 
-	for _, sg := range sga.securityGroups {
-		usages := 0
-		for _, ifc := range sga.networkInterfaces {
-			if sga.interfaceHasSecurityGroup(ifc, sg) {
-				usages++
+// UnattachedNetworkRecord is the JSON representation of an unattached network
+// interface or Elastic IP. The subnet, security groups and description are
+// the ones of the network interface, which Elastic IPs don't have.
+type UnattachedNetworkRecord struct {
+	Account string `json:"account" yaml:"account"`
+	Region  string `json:"region" yaml:"region"`
+	// Kind is UnattachedInterface or UnattachedAddress.
+	Kind           string   `json:"kind" yaml:"kind"`
+	ID             string   `json:"id" yaml:"id"`
+	PublicIP       string   `json:"public_ip" yaml:"public_ip"`
+	PrivateIP      string   `json:"private_ip" yaml:"private_ip"`
+	VPCID          string   `json:"vpc_id" yaml:"vpc_id"`
+	SubnetID       string   `json:"subnet_id" yaml:"subnet_id"`
+	SecurityGroups []string `json:"security_groups" yaml:"security_groups"`
+	InterfaceType  string   `json:"interface_type" yaml:"interface_type"`
+	RequesterID    string   `json:"requester_id" yaml:"requester_id"`
+	Description    string   `json:"description" yaml:"description"`
+}
+
+func (un *UnattachedNetwork) records() []UnattachedNetworkRecord {
+	records := make([]UnattachedNetworkRecord, 0)
+	for _, scope := range models.SortedScopes(un.networkInterfaces) {
+		for _, ifc := range un.networkInterfaces[scope] {
+			if aws.StringValue(ifc.Status) != ec2.NetworkInterfaceStatusAvailable {
+				continue
+			}
+			r := UnattachedNetworkRecord{
+				Account:        scope.Account,
+				Region:         scope.Region,
+				Kind:           UnattachedInterface,
+				ID:             aws.StringValue(ifc.NetworkInterfaceId),
+				PrivateIP:      aws.StringValue(ifc.PrivateIpAddress),
+				VPCID:          aws.StringValue(ifc.VpcId),
+				SubnetID:       aws.StringValue(ifc.SubnetId),
+				SecurityGroups: make([]string, 0, len(ifc.Groups)),
+				InterfaceType:  aws.StringValue(ifc.InterfaceType),
+				RequesterID:    aws.StringValue(ifc.RequesterId),
+				Description:    aws.StringValue(ifc.Description),
+			}
+			if ifc.Association != nil {
+				r.PublicIP = aws.StringValue(ifc.Association.PublicIp)
+			}
+			for _, group := range ifc.Groups {
+				r.SecurityGroups = append(r.SecurityGroups, aws.StringValue(group.GroupId))
 			}
+			records = append(records, r)
+		}
+	}
+	for _, scope := range models.SortedScopes(un.addresses) {
+		for _, a := range un.addresses[scope] {
+			if a.AssociationId != nil || a.InstanceId != nil || a.NetworkInterfaceId != nil {
+				continue
+			}
+			records = append(records, UnattachedNetworkRecord{
+				Account:        scope.Account,
+				Region:         scope.Region,
+				Kind:           UnattachedAddress,
+				ID:             aws.StringValue(a.AllocationId),
+				PublicIP:       aws.StringValue(a.PublicIp),
+				SecurityGroups: make([]string, 0),
+			})
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (un *UnattachedNetwork) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Kind",
+		"ID",
+		"Public IP",
+		"Private IP",
+		"VPC ID",
+		"Subnet ID",
+		"Security Groups",
+		"Interface Type",
+		"Description",
+	)
+	interfaces, addresses := 0, 0
+	for _, r := range un.records() {
+		if r.Kind == UnattachedAddress {
+			addresses++
+		} else {
+			interfaces++
 		}
-		table.Append([]string{
-			aws.StringValue(sg.VpcId),
-			aws.StringValue(sg.GroupId),
-			aws.StringValue(sg.GroupName),
-			strconv.Itoa(usages),
-		})
+		table.Append(
+			r.Account,
+			r.Region,
+			r.Kind,
+			r.ID,
+			r.PublicIP,
+			r.PrivateIP,
+			r.VPCID,
+			r.SubnetID,
+			strings.Join(r.SecurityGroups, ", "),
+			r.InterfaceType,
+			r.Description,
+		)
 	}
+	table.Summary = []string{
+		fmt.Sprintf("Unattached Network Interfaces: %d", interfaces),
+		fmt.Sprintf("Unassociated Elastic IPs: %d", addresses),
+	}
+	return table
+}
 
-	table.Render()
+// Records implements views.View
+func (un *UnattachedNetwork) Records() interface{} {
+	return un.records()
+}
+
+// Findings implements policy.Auditor. Unassociated Elastic IPs are medium
+// severity findings, since they are billed, while unattached network
+// interfaces are low severity ones.
+func (un *UnattachedNetwork) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range un.records() {
+		f := policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.ID,
+			Message:  "network interface isn't attached to anything",
+		}
+		if r.Kind == UnattachedAddress {
+			f.Severity = policy.Medium
+			f.Message = fmt.Sprintf("Elastic IP %s isn't associated with anything", r.PublicIP)
+		}
+		findings = append(findings, f)
+	}
+	return findings
 }
@@ -0,0 +1,78 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnattachedNetwork(t *testing.T) {
+	scope := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	un := NewUnattachedNetwork(
+		map[models.Scope][]*ec2.NetworkInterface{scope: {
+			{
+				NetworkInterfaceId: aws.String("eni-attached"),
+				Status:             aws.String(ec2.NetworkInterfaceStatusInUse),
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-leaked"),
+				Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+				PrivateIpAddress:   aws.String("10.0.1.5"),
+				VpcId:              aws.String("vpc-1"),
+				SubnetId:           aws.String("subnet-1"),
+				Groups: []*ec2.GroupIdentifier{
+					{GroupId: aws.String("sg-1")},
+					{GroupId: aws.String("sg-2")},
+				},
+				InterfaceType: aws.String("interface"),
+				RequesterId:   aws.String("AROAEXAMPLE:lambda"),
+				Description:   aws.String("AWS Lambda VPC ENI-worker"),
+				Association:   &ec2.NetworkInterfaceAssociation{PublicIp: aws.String("3.3.3.3")},
+			},
+		}},
+		map[models.Scope][]*ec2.Address{scope: {
+			{
+				AllocationId:  aws.String("eipalloc-used"),
+				AssociationId: aws.String("eipassoc-1"),
+				PublicIp:      aws.String("1.1.1.1"),
+			},
+			{AllocationId: aws.String("eipalloc-free"), PublicIp: aws.String("2.2.2.2")},
+		}},
+	)
+
+	records := un.records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, UnattachedNetworkRecord{
+		Account:        "111111111111",
+		Region:         "us-east-1",
+		Kind:           UnattachedInterface,
+		ID:             "eni-leaked",
+		PublicIP:       "3.3.3.3",
+		PrivateIP:      "10.0.1.5",
+		VPCID:          "vpc-1",
+		SubnetID:       "subnet-1",
+		SecurityGroups: []string{"sg-1", "sg-2"},
+		InterfaceType:  "interface",
+		RequesterID:    "AROAEXAMPLE:lambda",
+		Description:    "AWS Lambda VPC ENI-worker",
+	}, records[0])
+	assert.Equal(t, UnattachedAddress, records[1].Kind)
+	assert.Equal(t, "eipalloc-free", records[1].ID)
+	assert.Equal(t, "2.2.2.2", records[1].PublicIP)
+
+	findings := un.Findings()
+	assert.Len(t, findings, 2)
+	assert.Equal(t, policy.Low, findings[0].Severity)
+	assert.Equal(t, policy.Medium, findings[1].Severity)
+	assert.Equal(t, "Elastic IP 2.2.2.2 isn't associated with anything", findings[1].Message)
+
+	table := un.Table()
+	assert.Equal(t, []string{
+		"Unattached Network Interfaces: 1",
+		"Unassociated Elastic IPs: 1",
+	}, table.Summary)
+}
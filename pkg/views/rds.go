@@ -2,8 +2,6 @@ package views
 
 import (
 	"fmt"
-	"io"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,168 +9,297 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
-	"github.com/olekukonko/tablewriter"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
 )
 
-// See https://docs.aws.amazon.com/AmazonRDS/latest/AuroraUserGuide/USER_WorkingWithReservedDBInstances.html
-// and https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ri-modifying.html
-// for more information about normalized instance units
-var normalizedUnits = map[string]float64{
-	"nano":      0.25,
-	"micro":     0.5,
-	"small":     1,
-	"medium":    2,
-	"large":     4,
-	"xlarge":    8,
-	"2xlarge":   16,
-	"4xlarge":   32,
-	"6xlarge":   48,
-	"8xlarge":   64,
-	"10xlarge":  80,
-	"12xlarge":  96,
-	"16xlarge":  128,
-	"24xlarge":  192,
-	"32xlarge":  256,
-	"48xlarge":  384,
-	"56xlarge":  448,
-	"64xlarge":  512,
-	"72xlarge":  576,
-	"80xlarge":  640,
-	"96xlarge":  768,
-	"112xlarge": 896,
-}
-
 type rdsInstanceType string
 
 func (r rdsInstanceType) family() string { return string(r[0:strings.LastIndex(string(r), ".")]) }
 func (r rdsInstanceType) size() string   { return string(r[strings.LastIndex(string(r), ".")+1:]) }
-func (r rdsInstanceType) normalizedUnits() (float64, bool) {
-	units, ok := normalizedUnits[r.size()]
-	return units, ok
+func (r rdsInstanceType) normalizedUnits(u *NormalizedUnits) (float64, bool) {
+	return u.lookup(r.family(), r.size())
+}
+
+// rdsEngineProducts maps the engines of DB instances which are described
+// differently by reservations to their product description.
+var rdsEngineProducts = map[string]string{
+	"postgres": "postgresql",
+	"aurora":   "aurora-mysql",
+}
+
+// rdsLicenseModels maps the license models of DB instances to the suffix of
+// the product description of their reservations. Only Oracle and SQL Server
+// reservations have one.
+var rdsLicenseModels = map[string]string{
+	"license-included":       "(li)",
+	"bring-your-own-license": "(byol)",
+}
+
+// rdsProduct is the product description of a reservation, which is the engine
+// of the instances it covers and, for Oracle and SQL Server, their license
+// model, e.g. "postgresql" or "oracle-se2(byol)".
+type rdsProduct string
+
+// dbInstanceProduct returns the product description of the reservations which
+// cover the instance.
+func dbInstanceProduct(i *rds.DBInstance) rdsProduct {
+	engine := aws.StringValue(i.Engine)
+	if product, ok := rdsEngineProducts[engine]; ok {
+		return rdsProduct(product)
+	}
+	if strings.HasPrefix(engine, "oracle-") || strings.HasPrefix(engine, "sqlserver-") {
+		return rdsProduct(engine + rdsLicenseModels[aws.StringValue(i.LicenseModel)])
+	}
+	return rdsProduct(engine)
+}
+
+// reservedDBInstanceProduct returns the product description of the
+// reservation, with any older names of an engine replaced.
+func reservedDBInstanceProduct(r *rds.ReservedDBInstance) rdsProduct {
+	description := aws.StringValue(r.ProductDescription)
+	if product, ok := rdsEngineProducts[description]; ok {
+		return rdsProduct(product)
+	}
+	return rdsProduct(description)
 }
 
+// sizeFlexible returns whether reservations of the product apply to any size
+// in their family. SQL Server and license included Oracle reservations only
+// apply to instances of exactly the same class.
+func (p rdsProduct) sizeFlexible() bool {
+	engine, license, _ := strings.Cut(string(p), "(")
+	switch {
+	case strings.HasPrefix(engine, "oracle-"):
+		return license == "byol)"
+	case strings.HasPrefix(engine, "sqlserver-"):
+		return false
+	}
+	return true
+}
+
+// utilizationKey returns the key of the utilization an instance class of the
+// product counts against: its family when the product is size flexible and
+// the class itself otherwise.
+func (p rdsProduct) utilizationKey(itype rdsInstanceType) string {
+	if p.sizeFlexible() {
+		return fmt.Sprintf("%s/%s", p, itype.family())
+	}
+	return fmt.Sprintf("%s/%s", p, itype)
+}
+
+// serverlessDBInstanceClass is the class of Aurora Serverless v2 instances,
+// which are billed by capacity and can't be reserved.
+const serverlessDBInstanceClass = "db.serverless"
+
 // RDSReservationUtilization shows which instance types & families we are
-// utilizing instances in.
+// utilizing instances in. Reservations only apply to instances in their own
+// scope.
 type RDSReservationUtilization struct {
-	InstanceTypeReservationUtilizations map[string]*InstanceTypeReservationUtilization
+	InstanceTypeReservationUtilizations map[models.Scope]map[string]*InstanceTypeReservationUtilization
+	// Serverless is the number of Aurora Serverless v2 instances, which
+	// aren't included since they can't be reserved.
+	Serverless int
+}
+
+// RDSReservationUtilizationOptions are options which modify the
+// RDSReservationUtilization view.
+type RDSReservationUtilizationOptions struct {
+	// NormalizedUnits are used on top of the built in normalized units to
+	// match size flexible reservations. It may be nil.
+	NormalizedUnits *NormalizedUnits
 }
 
 // NewRDSReservationUtilization Creates a new view for the reserved utilization.
-func NewRDSReservationUtilization(running []*rds.DBInstance, reservations []*rds.ReservedDBInstance) *RDSReservationUtilization {
+//
+// Instances and reservations are matched on their product description, so
+// reservations only cover instances with the same engine and, for Oracle and
+// SQL Server, license model. Size flexible reservations are counted per
+// family in normalized units, and the others per instance class in instances.
+//
+// Multi-AZ instances and reservations are counted twice, since their standby
+// is billed too. The instances of a cluster are billed one by one instead,
+// readers included, so members of a cluster are only counted once.
+func NewRDSReservationUtilization(running map[models.Scope][]*rds.DBInstance, reservations map[models.Scope][]*rds.ReservedDBInstance, opts RDSReservationUtilizationOptions) *RDSReservationUtilization {
 	ru := RDSReservationUtilization{
-		InstanceTypeReservationUtilizations: make(map[string]*InstanceTypeReservationUtilization),
-	}
-	for _, i := range running {
-		itype := rdsInstanceType(aws.StringValue(i.DBInstanceClass))
-		var engine string
-		switch *i.Engine {
-		case "postgres":
-			engine = "postgresql"
-		default:
-			engine = *i.Engine
-		}
-		iru := ru.getOrInitializeITypeReservation(fmt.Sprintf("%s/%s", engine, itype.family()))
+		InstanceTypeReservationUtilizations: make(map[models.Scope]map[string]*InstanceTypeReservationUtilization),
+	}
+	for scope, instances := range running {
+		for _, i := range instances {
+			if aws.StringValue(i.DBInstanceClass) == serverlessDBInstanceClass {
+				ru.Serverless++
+				continue
+			}
+			itype := rdsInstanceType(aws.StringValue(i.DBInstanceClass))
+			product := dbInstanceProduct(i)
+			iru := ru.getOrInitializeITypeReservation(scope, product.utilizationKey(itype))
 
-		units, ok := itype.normalizedUnits()
-		if ok {
-			if aws.BoolValue(i.MultiAZ) == true {
+			units, ok := 1.0, true
+			if product.sizeFlexible() {
+				units, ok = itype.normalizedUnits(opts.NormalizedUnits)
+			}
+			if !ok {
+				iru.addUnnormalized(string(itype))
+				continue
+			}
+			iru.addSize(itype.size())
+			if aws.BoolValue(i.MultiAZ) == true && i.DBClusterIdentifier == nil {
 				iru.NumRunning += units * 2
 			} else {
 				iru.NumRunning += units
 			}
 		}
-
 	}
 
-	for _, r := range reservations {
-		itype := rdsInstanceType(aws.StringValue(r.DBInstanceClass))
-		iru := ru.getOrInitializeITypeReservation(fmt.Sprintf("%s/%s", *r.ProductDescription, itype.family()))
-		units, ok := itype.normalizedUnits()
-		if ok {
-			iru.NumReserved += units * float64(*r.DBInstanceCount)
+	for scope, scopeReservations := range reservations {
+		for _, r := range scopeReservations {
+			itype := rdsInstanceType(aws.StringValue(r.DBInstanceClass))
+			product := reservedDBInstanceProduct(r)
+			iru := ru.getOrInitializeITypeReservation(scope, product.utilizationKey(itype))
+
+			units, ok := 1.0, true
+			if product.sizeFlexible() {
+				units, ok = itype.normalizedUnits(opts.NormalizedUnits)
+			}
+			if !ok {
+				iru.addUnnormalized(string(itype))
+				continue
+			}
+			if aws.BoolValue(r.MultiAZ) {
+				units *= 2
+			}
+			iru.NumReserved += units * float64(aws.Int64Value(r.DBInstanceCount))
 		}
 	}
 	return &ru
 
 }
 
-func (ru *RDSReservationUtilization) getOrInitializeITypeReservation(s string) *InstanceTypeReservationUtilization {
-	_, ok := ru.InstanceTypeReservationUtilizations[s]
+func (ru *RDSReservationUtilization) getOrInitializeITypeReservation(scope models.Scope, s string) *InstanceTypeReservationUtilization {
+	utilizations, ok := ru.InstanceTypeReservationUtilizations[scope]
 	if !ok {
-		ru.InstanceTypeReservationUtilizations[s] = &InstanceTypeReservationUtilization{
+		utilizations = make(map[string]*InstanceTypeReservationUtilization)
+		ru.InstanceTypeReservationUtilizations[scope] = utilizations
+	}
+	if _, ok := utilizations[s]; !ok {
+		utilizations[s] = &InstanceTypeReservationUtilization{
+			Scope:        scope,
 			InstanceType: s,
 		}
 	}
-	return ru.InstanceTypeReservationUtilizations[s]
+	return utilizations[s]
 }
 
-// SortedInstanceTypes returns a sorted slice of the instance types
-func (ru *RDSReservationUtilization) SortedInstanceTypes() []string {
+// SortedInstanceTypes returns a sorted slice of the instance types in the
+// given scope
+func (ru *RDSReservationUtilization) SortedInstanceTypes(scope models.Scope) []string {
 	types := make([]string, 0)
-	for k := range ru.InstanceTypeReservationUtilizations {
+	for k := range ru.InstanceTypeReservationUtilizations[scope] {
 		types = append(types, k)
 	}
 	sort.Strings(types)
 	return types
 }
 
-// Print prints the table to stdout
-func (ru *RDSReservationUtilization) Print() {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{
+// Table implements views.View
+func (ru *RDSReservationUtilization) Table() *Table {
+	table := utilizationTable(
+		sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes),
 		"Engine/Family",
 		"Normalized Running Units",
 		"Normalized Reserved Units",
 		"Has Unused",
 		"Units Not Reserved",
-	})
+	)
+	if ru.Serverless > 0 {
+		table.Summary = append(table.Summary, fmt.Sprintf("%d Aurora Serverless Instances Aren't Included", ru.Serverless))
+	}
+	if unnormalized := ru.unnormalized(); len(unnormalized) > 0 {
+		table.Summary = append(table.Summary, fmt.Sprintf("Couldn't Normalize: %s", strings.Join(unnormalized, ", ")))
+	}
+	return table
+}
 
-	for _, k := range ru.SortedInstanceTypes() {
-		iru := ru.getOrInitializeITypeReservation(k)
-		extra := ""
-		if iru.HasUnused() {
-			extra = "X"
+// unnormalized returns the instance classes whose normalized units aren't
+// known, in every scope.
+func (ru *RDSReservationUtilization) unnormalized() []string {
+	seen := make(map[string]bool)
+	classes := make([]string, 0)
+	for _, utilizations := range ru.InstanceTypeReservationUtilizations {
+		for _, iru := range utilizations {
+			for _, c := range iru.Unnormalized {
+				if !seen[c] {
+					seen[c] = true
+					classes = append(classes, c)
+				}
+			}
 		}
-		table.Append([]string{
-			k,
-			fmt.Sprintf("%.2f", iru.NumRunning),
-			fmt.Sprintf("%.2f", iru.NumReserved),
-			extra,
-			fmt.Sprintf("%.2f", iru.Unreserved()),
-		})
 	}
+	sort.Strings(classes)
+	return classes
+}
 
-	table.Render()
+// Records implements views.View
+func (ru *RDSReservationUtilization) Records() interface{} {
+	return utilizationRecords(sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes))
+}
+
+// Findings implements policy.Auditor. Instance classes which couldn't be
+// normalized are reported too, since the utilization is wrong without them.
+func (ru *RDSReservationUtilization) Findings() []policy.Finding {
+	utilizations := sortedUtilizations(ru.InstanceTypeReservationUtilizations, ru.SortedInstanceTypes)
+	findings := utilizationFindings(utilizations)
+	for _, iru := range utilizations {
+		for _, c := range iru.Unnormalized {
+			findings = append(findings, policy.Finding{
+				Scope:    iru.Scope,
+				Severity: policy.Low,
+				Resource: iru.String(),
+				Message:  fmt.Sprintf("the normalized units of %s aren't known, so it isn't counted", c),
+			})
+		}
+	}
+	return findings
 }
 
 // RDSSnapshotAudit gives an overview of the RDS snapshots, with their instances,
 // and how much storage is being used
 type RDSSnapshotAudit struct {
-	Snapshots []*rds.DBSnapshot
-	Instances []*rds.DBInstance
+	Snapshots map[models.Scope][]*rds.DBSnapshot
+	Instances map[models.Scope][]*rds.DBInstance
 }
 
 // NewRDSSnapshotAudit returns an audit view for comparing snapshots vs running
 // instances
-func NewRDSSnapshotAudit(snapshots []*rds.DBSnapshot, instances []*rds.DBInstance) *RDSSnapshotAudit {
+func NewRDSSnapshotAudit(snapshots map[models.Scope][]*rds.DBSnapshot, instances map[models.Scope][]*rds.DBInstance) *RDSSnapshotAudit {
 	return &RDSSnapshotAudit{Snapshots: snapshots, Instances: instances}
 }
 
 // NumRunningInstances returns the number of running instances
 func (audit *RDSSnapshotAudit) NumRunningInstances() int {
-	return len(audit.Instances)
+	n := 0
+	for _, instances := range audit.Instances {
+		n += len(instances)
+	}
+	return n
 }
 
 // NumSnapshots returns the number of snapshots
 func (audit *RDSSnapshotAudit) NumSnapshots() int {
-	return len(audit.Snapshots)
+	n := 0
+	for _, snapshots := range audit.Snapshots {
+		n += len(snapshots)
+	}
+	return n
 }
 
 // TotalRunningStorageGB returns the total storage of running intances in GB
 func (audit *RDSSnapshotAudit) TotalRunningStorageGB() int64 {
 	var storage int64
-	for _, i := range audit.Instances {
-		storage += aws.Int64Value(i.AllocatedStorage)
+	for _, instances := range audit.Instances {
+		for _, i := range instances {
+			storage += aws.Int64Value(i.AllocatedStorage)
+		}
 	}
 	return storage
 }
@@ -181,23 +308,26 @@ func (audit *RDSSnapshotAudit) TotalRunningStorageGB() int64 {
 // This is the "virtual" storage though as the snapshots only store deltas
 func (audit *RDSSnapshotAudit) TotalVirtualSnapshotStorageGB() int64 {
 	var storage int64
-	for _, s := range audit.Snapshots {
-		storage += aws.Int64Value(s.AllocatedStorage)
+	for _, snapshots := range audit.Snapshots {
+		for _, s := range snapshots {
+			storage += aws.Int64Value(s.AllocatedStorage)
+		}
 	}
 	return storage
 }
 
 // OldInstancesWithSnapshots returns a map whose keys are DBInstanceIdentifiers
-// which no longer exist. The values are slices of *rds.DBSnapshots
-func (audit *RDSSnapshotAudit) OldInstancesWithSnapshots() map[string][]*rds.DBSnapshot {
+// which no longer exist in the given scope. The values are slices of
+// *rds.DBSnapshots
+func (audit *RDSSnapshotAudit) OldInstancesWithSnapshots(scope models.Scope) map[string][]*rds.DBSnapshot {
 	runningIdentifiers := make(map[string]bool)
-	for _, i := range audit.Instances {
+	for _, i := range audit.Instances[scope] {
 		dbIdentifier := aws.StringValue(i.DBInstanceIdentifier)
 		runningIdentifiers[dbIdentifier] = true
 	}
 
 	old := make(map[string][]*rds.DBSnapshot)
-	for _, snap := range audit.Snapshots {
+	for _, snap := range audit.Snapshots[scope] {
 		dbIdentifier := aws.StringValue(snap.DBInstanceIdentifier)
 		if _, ok := runningIdentifiers[dbIdentifier]; !ok {
 			if _, ok := old[dbIdentifier]; !ok {
@@ -210,26 +340,103 @@ func (audit *RDSSnapshotAudit) OldInstancesWithSnapshots() map[string][]*rds.DBS
 	return old
 }
 
-// Render renders the table to the io.Writer
-func (audit *RDSSnapshotAudit) Render(w io.Writer) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{
+// RDSSnapshotAuditRecord is the JSON representation of the RDS snapshot
+// audit.
+type RDSSnapshotAuditRecord struct {
+	RunningInstances              int                `json:"running_instances" yaml:"running_instances"`
+	Snapshots                     int                `json:"snapshots" yaml:"snapshots"`
+	TotalRunningStorageGB         int64              `json:"total_running_storage_gb" yaml:"total_running_storage_gb"`
+	TotalVirtualSnapshotStorageGB int64              `json:"total_virtual_snapshot_storage_gb" yaml:"total_virtual_snapshot_storage_gb"`
+	OldSnapshots                  []DBSnapshotRecord `json:"old_snapshots" yaml:"old_snapshots"`
+}
+
+// DBSnapshotRecord is the JSON representation of a snapshot whose DB
+// instance no longer exists.
+type DBSnapshotRecord struct {
+	Account              string    `json:"account" yaml:"account"`
+	Region               string    `json:"region" yaml:"region"`
+	DBInstanceIdentifier string    `json:"db_instance_identifier" yaml:"db_instance_identifier"`
+	SnapshotIdentifier   string    `json:"snapshot_identifier" yaml:"snapshot_identifier"`
+	CreatedTime          time.Time `json:"created_time" yaml:"created_time"`
+	SizeGB               int64     `json:"size_gb" yaml:"size_gb"`
+}
+
+func (audit *RDSSnapshotAudit) oldSnapshotRecords() []DBSnapshotRecord {
+	records := make([]DBSnapshotRecord, 0)
+	for _, scope := range models.SortedScopes(audit.Snapshots) {
+		oldSnapMap := audit.OldInstancesWithSnapshots(scope)
+		identifiers := make([]string, 0, len(oldSnapMap))
+		for i := range oldSnapMap {
+			identifiers = append(identifiers, i)
+		}
+		sort.Strings(identifiers)
+
+		for _, i := range identifiers {
+			for _, snap := range oldSnapMap[i] {
+				records = append(records, DBSnapshotRecord{
+					Account:              scope.Account,
+					Region:               scope.Region,
+					DBInstanceIdentifier: i,
+					SnapshotIdentifier:   aws.StringValue(snap.DBSnapshotIdentifier),
+					CreatedTime:          aws.TimeValue(snap.SnapshotCreateTime),
+					SizeGB:               aws.Int64Value(snap.AllocatedStorage),
+				})
+			}
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (audit *RDSSnapshotAudit) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
 		"DB Instance Identifier",
 		"Snapshot Identifier",
 		"Created Time",
 		"Size",
-	})
-
-	oldSnapMap := audit.OldInstancesWithSnapshots()
-	for i := range oldSnapMap {
-		for _, snap := range oldSnapMap[i] {
-			table.Append([]string{
-				i,
-				aws.StringValue(snap.DBSnapshotIdentifier),
-				aws.TimeValue(snap.SnapshotCreateTime).Format(time.UnixDate),
-				strconv.Itoa(int(aws.Int64Value(snap.AllocatedStorage))),
-			})
-		}
+	)
+	for _, r := range audit.oldSnapshotRecords() {
+		table.Append(
+			r.Account,
+			r.Region,
+			r.DBInstanceIdentifier,
+			r.SnapshotIdentifier,
+			r.CreatedTime.Format(time.UnixDate),
+			strconv.FormatInt(r.SizeGB, 10),
+		)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("Number of Running DBs: %d", audit.NumRunningInstances()),
+		fmt.Sprintf("Number of DB Snapshots: %d", audit.NumSnapshots()),
+		fmt.Sprintf("Total Running storage: %d GB", audit.TotalRunningStorageGB()),
+		fmt.Sprintf("Total Virtual Snapshot storage: %d GB", audit.TotalVirtualSnapshotStorageGB()),
+	}
+	return table
+}
+
+// Records implements views.View
+func (audit *RDSSnapshotAudit) Records() interface{} {
+	return RDSSnapshotAuditRecord{
+		RunningInstances:              audit.NumRunningInstances(),
+		Snapshots:                     audit.NumSnapshots(),
+		TotalRunningStorageGB:         audit.TotalRunningStorageGB(),
+		TotalVirtualSnapshotStorageGB: audit.TotalVirtualSnapshotStorageGB(),
+		OldSnapshots:                  audit.oldSnapshotRecords(),
+	}
+}
+
+// Findings implements policy.Auditor
+func (audit *RDSSnapshotAudit) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, r := range audit.oldSnapshotRecords() {
+		findings = append(findings, policy.Finding{
+			Scope:    models.Scope{Account: r.Account, Region: r.Region},
+			Severity: policy.Low,
+			Resource: r.SnapshotIdentifier,
+			Message:  fmt.Sprintf("snapshot of deleted DB instance %s", r.DBInstanceIdentifier),
+		})
 	}
-	table.Render()
+	return findings
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,39 +31,191 @@ func TestGetInstanceFamily(t *testing.T) {
 	x := rdsInstanceType("db.m5.large")
 	assert.Equal(t, "db.m5", x.family())
 	assert.Equal(t, "large", x.size())
-	units, ok := x.normalizedUnits()
+	units, ok := x.normalizedUnits(nil)
 	assert.True(t, ok)
 	assert.Equal(t, 4.0, units)
 }
 
 func TestRDSResrvationUtilization(t *testing.T) {
+	scope := models.Scope{Region: "us-west-2"}
 	utilization := NewRDSReservationUtilization(
-		[]*rds.DBInstance{
+		map[models.Scope][]*rds.DBInstance{scope: {
 			makeRDSInstance("db-1", "db.m5.large", "postgresql", true),
 			makeRDSInstance("db-2", "db.m5.large", "postgresql", false),
 			makeRDSInstance("db-3", "db.m5.large", "postgresql", false),
 			makeRDSInstance("db-4", "db.m5.large", "aurora-postgresql", true),
-		},
-		[]*rds.ReservedDBInstance{
+		}},
+		map[models.Scope][]*rds.ReservedDBInstance{scope: {
 			makeRDSReservation("db.m5.large", "postgresql", 3),
 			makeRDSReservation("db.r6.2xlarge", "aurora-postgresql", 2),
-		},
+		}},
+		RDSReservationUtilizationOptions{},
 	)
+	utilizations := utilization.InstanceTypeReservationUtilizations[scope]
 
 	// Should have 16 running units. 2 normal larges = 2 * 4 = 8. And 1 MultiAZ large = 2 * 4 = 8, so 16 units total.
-	assert.Equal(t, 16.0, utilization.InstanceTypeReservationUtilizations["postgresql/db.m5"].NumRunning)
-	assert.Equal(t, 12.0, utilization.InstanceTypeReservationUtilizations["postgresql/db.m5"].NumReserved)
-	assert.Equal(t, 4.0, utilization.InstanceTypeReservationUtilizations["postgresql/db.m5"].Unreserved())
-	assert.Equal(t, false, utilization.InstanceTypeReservationUtilizations["postgresql/db.m5"].HasUnused())
+	assert.Equal(t, 16.0, utilizations["postgresql/db.m5"].NumRunning)
+	assert.Equal(t, 12.0, utilizations["postgresql/db.m5"].NumReserved)
+	assert.Equal(t, 4.0, utilizations["postgresql/db.m5"].Unreserved())
+	assert.Equal(t, false, utilizations["postgresql/db.m5"].HasUnused())
 
 	// Again, here we expect 8 normalized units, 4 for each large since its MultiAZ.
-	assert.Equal(t, 8.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.m5"].NumRunning)
-	assert.Equal(t, 0.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.m5"].NumReserved)
-	assert.Equal(t, 8.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.m5"].Unreserved())
-	assert.Equal(t, false, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.m5"].HasUnused())
-
-	assert.Equal(t, 0.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.r6"].NumRunning)
-	assert.Equal(t, 32.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.r6"].NumReserved)
-	assert.Equal(t, -32.0, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.r6"].Unreserved())
-	assert.Equal(t, true, utilization.InstanceTypeReservationUtilizations["aurora-postgresql/db.r6"].HasUnused())
+	assert.Equal(t, 8.0, utilizations["aurora-postgresql/db.m5"].NumRunning)
+	assert.Equal(t, 0.0, utilizations["aurora-postgresql/db.m5"].NumReserved)
+	assert.Equal(t, 8.0, utilizations["aurora-postgresql/db.m5"].Unreserved())
+	assert.Equal(t, false, utilizations["aurora-postgresql/db.m5"].HasUnused())
+
+	assert.Equal(t, 0.0, utilizations["aurora-postgresql/db.r6"].NumRunning)
+	assert.Equal(t, 32.0, utilizations["aurora-postgresql/db.r6"].NumReserved)
+	assert.Equal(t, -32.0, utilizations["aurora-postgresql/db.r6"].Unreserved())
+	assert.Equal(t, true, utilizations["aurora-postgresql/db.r6"].HasUnused())
+}
+
+func TestRDSReservationUtilizationIsScoped(t *testing.T) {
+	west := models.Scope{Region: "us-west-2"}
+	east := models.Scope{Region: "us-east-1"}
+	utilization := NewRDSReservationUtilization(
+		map[models.Scope][]*rds.DBInstance{
+			west: {makeRDSInstance("db-1", "db.m5.large", "postgresql", false)},
+		},
+		map[models.Scope][]*rds.ReservedDBInstance{
+			east: {makeRDSReservation("db.m5.large", "postgresql", 1)},
+		},
+		RDSReservationUtilizationOptions{},
+	)
+
+	assert.Equal(t, 4.0, utilization.InstanceTypeReservationUtilizations[west]["postgresql/db.m5"].Unreserved())
+	assert.Equal(t, true, utilization.InstanceTypeReservationUtilizations[east]["postgresql/db.m5"].HasUnused())
+}
+
+func TestRDSReservationUtilizationAuroraClusters(t *testing.T) {
+	scope := models.Scope{Region: "us-east-1"}
+	writer := makeRDSInstance("db-1", "db.r6g.large", "aurora-postgresql", true)
+	writer.DBClusterIdentifier = aws.String("cluster-1")
+	reader := makeRDSInstance("db-2", "db.r6g.large", "aurora-postgresql", true)
+	reader.DBClusterIdentifier = aws.String("cluster-1")
+	serverless := makeRDSInstance("db-3", "db.serverless", "aurora-postgresql", false)
+	serverless.DBClusterIdentifier = aws.String("cluster-2")
+
+	utilization := NewRDSReservationUtilization(
+		map[models.Scope][]*rds.DBInstance{scope: {writer, reader, serverless}},
+		map[models.Scope][]*rds.ReservedDBInstance{scope: {
+			makeRDSReservation("db.r6g.large", "aurora-postgresql", 2),
+		}},
+		RDSReservationUtilizationOptions{},
+	)
+	utilizations := utilization.InstanceTypeReservationUtilizations[scope]
+
+	// The writer and reader are billed once each, even though the cluster is
+	// Multi-AZ, and the serverless instance isn't included.
+	assert.Len(t, utilizations, 1)
+	assert.Equal(t, 8.0, utilizations["aurora-postgresql/db.r6g"].NumRunning)
+	assert.Equal(t, 8.0, utilizations["aurora-postgresql/db.r6g"].NumReserved)
+	assert.Equal(t, false, utilizations["aurora-postgresql/db.r6g"].HasUnused())
+	assert.Equal(t, 1, utilization.Serverless)
+	assert.Equal(t, []string{"1 Aurora Serverless Instances Aren't Included"}, utilization.Table().Summary)
+}
+
+func TestDBInstanceProduct(t *testing.T) {
+	cases := []struct {
+		engine, licenseModel string
+		product              rdsProduct
+		sizeFlexible         bool
+	}{
+		{"postgres", "postgresql-license", "postgresql", true},
+		{"mysql", "general-public-license", "mysql", true},
+		{"mariadb", "general-public-license", "mariadb", true},
+		{"aurora", "general-public-license", "aurora-mysql", true},
+		{"aurora-postgresql", "postgresql-license", "aurora-postgresql", true},
+		{"oracle-se2", "bring-your-own-license", "oracle-se2(byol)", true},
+		{"oracle-se2", "license-included", "oracle-se2(li)", false},
+		{"sqlserver-se", "license-included", "sqlserver-se(li)", false},
+		{"sqlserver-ee", "bring-your-own-license", "sqlserver-ee(byol)", false},
+	}
+	for _, c := range cases {
+		t.Run(c.engine+"/"+c.licenseModel, func(t *testing.T) {
+			i := makeRDSInstance("db-1", "db.m5.large", c.engine, false)
+			i.LicenseModel = aws.String(c.licenseModel)
+			assert.Equal(t, c.product, dbInstanceProduct(i))
+			assert.Equal(t, c.sizeFlexible, c.product.sizeFlexible())
+		})
+	}
+}
+
+func TestRDSReservationUtilizationMatchesProducts(t *testing.T) {
+	scope := models.Scope{Region: "us-east-1"}
+	licensed := func(id, class, engine, licenseModel string, multiAZ bool) *rds.DBInstance {
+		i := makeRDSInstance(id, class, engine, multiAZ)
+		i.LicenseModel = aws.String(licenseModel)
+		return i
+	}
+	multiAZ := makeRDSReservation("db.m5.large", "mysql", 1)
+	multiAZ.MultiAZ = aws.Bool(true)
+
+	utilization := NewRDSReservationUtilization(
+		map[models.Scope][]*rds.DBInstance{scope: {
+			licensed("db-1", "db.m5.large", "mysql", "general-public-license", true),
+			licensed("db-2", "db.m5.xlarge", "oracle-se2", "bring-your-own-license", false),
+			licensed("db-3", "db.m5.xlarge", "oracle-se2", "license-included", false),
+			licensed("db-4", "db.m5.xlarge", "sqlserver-se", "license-included", true),
+			licensed("db-5", "db.r5.large", "aurora", "general-public-license", false),
+		}},
+		map[models.Scope][]*rds.ReservedDBInstance{scope: {
+			multiAZ,
+			makeRDSReservation("db.m5.large", "oracle-se2(byol)", 2),
+			makeRDSReservation("db.m5.large", "oracle-se2(li)", 2),
+			makeRDSReservation("db.m5.xlarge", "sqlserver-se(li)", 1),
+			makeRDSReservation("db.r5.large", "aurora", 1),
+		}},
+		RDSReservationUtilizationOptions{},
+	)
+	utilizations := utilization.InstanceTypeReservationUtilizations[scope]
+
+	// A Multi-AZ reservation covers both the instance and its standby.
+	assert.Equal(t, 8.0, utilizations["mysql/db.m5"].NumRunning)
+	assert.Equal(t, 8.0, utilizations["mysql/db.m5"].NumReserved)
+
+	// Bring your own license Oracle is size flexible.
+	assert.Equal(t, 8.0, utilizations["oracle-se2(byol)/db.m5"].NumRunning)
+	assert.Equal(t, 8.0, utilizations["oracle-se2(byol)/db.m5"].NumReserved)
+
+	// License included Oracle and SQL Server are matched by class, in
+	// instances rather than normalized units.
+	assert.Equal(t, 1.0, utilizations["oracle-se2(li)/db.m5.xlarge"].NumRunning)
+	assert.Equal(t, 0.0, utilizations["oracle-se2(li)/db.m5.xlarge"].NumReserved)
+	assert.Equal(t, 2.0, utilizations["oracle-se2(li)/db.m5.large"].NumReserved)
+	assert.Equal(t, true, utilizations["oracle-se2(li)/db.m5.large"].HasUnused())
+	assert.Equal(t, 2.0, utilizations["sqlserver-se(li)/db.m5.xlarge"].NumRunning)
+	assert.Equal(t, 1.0, utilizations["sqlserver-se(li)/db.m5.xlarge"].NumReserved)
+
+	// The older name of Aurora MySQL matches either way.
+	assert.Equal(t, 4.0, utilizations["aurora-mysql/db.r5"].NumRunning)
+	assert.Equal(t, 4.0, utilizations["aurora-mysql/db.r5"].NumReserved)
+	assert.Len(t, utilizations, 6)
+}
+
+func TestRDSReservationUtilizationReportsUnnormalized(t *testing.T) {
+	scope := models.Scope{Region: "us-east-1"}
+	utilization := NewRDSReservationUtilization(
+		map[models.Scope][]*rds.DBInstance{scope: {
+			makeRDSInstance("db-1", "db.m5.large", "postgres", false),
+			makeRDSInstance("db-2", "db.m5.huge", "postgres", false),
+		}},
+		map[models.Scope][]*rds.ReservedDBInstance{scope: {
+			makeRDSReservation("db.m5.huge", "postgresql", 1),
+		}},
+		RDSReservationUtilizationOptions{},
+	)
+	iru := utilization.InstanceTypeReservationUtilizations[scope]["postgresql/db.m5"]
+
+	// The unknown size is listed instead of silently dropped.
+	assert.Equal(t, 4.0, iru.NumRunning)
+	assert.Equal(t, 0.0, iru.NumReserved)
+	assert.Equal(t, []string{"db.m5.huge"}, iru.Unnormalized)
+	assert.Equal(t, []string{"db.m5.huge"}, iru.Record().Unnormalized)
+	assert.Equal(t, []string{"Couldn't Normalize: db.m5.huge"}, utilization.Table().Summary)
+
+	findings := utilization.Findings()
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "[low] us-east-1 postgresql/db.m5: the normalized units of db.m5.huge aren't known, so it isn't counted", findings[0].String())
 }
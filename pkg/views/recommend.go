@@ -0,0 +1,312 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/pricing"
+)
+
+// RIRecommendationOptions are options which modify the RIRecommendations
+// view.
+type RIRecommendationOptions struct {
+	// Term defaults to pricing.OneYear.
+	Term string
+	// OfferingClass only applies to EC2 and defaults to pricing.Standard.
+	OfferingClass string
+	// Prices are used to estimate the annual savings and to only recommend
+	// sizes which exist. It may be nil.
+	Prices *pricing.Table
+	// NormalizedUnits are used on top of the built in normalized units to
+	// pick the sizes of size flexible families. It may be nil.
+	NormalizedUnits *NormalizedUnits
+}
+
+// RIRecommendation is a reservation we should buy.
+type RIRecommendation struct {
+	Scope        models.Scope
+	Service      string
+	InstanceType string
+	// Product is the platform for EC2 or the engine for RDS.
+	Product       string
+	Tenancy       string
+	Count         int64
+	Term          string
+	OfferingClass string
+	// AnnualSavings is nil when there is no price for the instance type.
+	AnnualSavings *float64
+}
+
+// RIRemainder is the part of the gap in coverage of a size flexible family
+// which is smaller than any of the sizes it could be covered with.
+type RIRemainder struct {
+	Scope   models.Scope
+	Service string
+	Family  string
+	Product string
+	Units   float64
+}
+
+// RIRecommendations is a view of the reservations which would cover the
+// instances which have been running without one.
+type RIRecommendations struct {
+	Recommendations []*RIRecommendation
+	// Remainders are left uncovered rather than reserved with a larger
+	// size than needed.
+	Remainders []*RIRemainder
+
+	opts RIRecommendationOptions
+}
+
+// NewRIRecommendations recommends reservations for the sustained gaps in
+// coverage, which are the utilizations of only the instances which have been
+// running for a while. The current utilizations, of all the running
+// instances, cap the gaps, and nothing is recommended for the families which
+// currently have unused reservations in the scope, zonal ones and ones for
+// other platforms included. Size flexible families are covered with the
+// largest sizes which fit in the gap, out of the sizes with a price, or the
+// sizes running when there are no prices, and what is left is reported as a
+// remainder. Either of the EC2 or RDS utilizations may be nil.
+func NewRIRecommendations(ec2Now, ec2Sustained *ReservationUtilization, rdsNow, rdsSustained *RDSReservationUtilization, opts RIRecommendationOptions) *RIRecommendations {
+	if opts.Term == "" {
+		opts.Term = pricing.OneYear
+	}
+	if opts.OfferingClass == "" {
+		opts.OfferingClass = pricing.Standard
+	}
+	rr := &RIRecommendations{opts: opts}
+
+	if ec2Now != nil && ec2Sustained != nil {
+		rr.recommend("ec2", ec2Now.InstanceTypeReservationUtilizations,
+			sortedUtilizations(ec2Sustained.InstanceTypeReservationUtilizations, ec2Sustained.SortedInstanceTypes))
+	}
+	if rdsNow != nil && rdsSustained != nil {
+		rr.recommend("rds", rdsNow.InstanceTypeReservationUtilizations,
+			sortedUtilizations(rdsSustained.InstanceTypeReservationUtilizations, rdsSustained.SortedInstanceTypes))
+	}
+	return rr
+}
+
+func (rr *RIRecommendations) recommend(service string, now map[models.Scope]map[string]*InstanceTypeReservationUtilization, sustained []*InstanceTypeReservationUtilization) {
+	// Unused reservations of a family could be modified or exchanged to
+	// cover the gap instead of buying more.
+	unused := make(map[models.Scope]map[string]bool)
+	for scope, utilizations := range now {
+		for _, u := range utilizations {
+			if !u.HasUnused() {
+				continue
+			}
+			if unused[scope] == nil {
+				unused[scope] = make(map[string]bool)
+			}
+			unused[scope][utilizationFamily(service, u)] = true
+		}
+	}
+
+	for _, iru := range sustained {
+		current, ok := now[iru.Scope][iru.String()]
+		if !ok || unused[iru.Scope][utilizationFamily(service, iru)] || iru.AvailabilityZone != "" {
+			continue
+		}
+		gap := math.Min(iru.Unreserved(), current.Unreserved())
+		if gap <= 0 {
+			continue
+		}
+
+		r := RIRecommendation{
+			Scope:         iru.Scope,
+			Service:       service,
+			Product:       iru.Platform,
+			Tenancy:       iru.Tenancy,
+			Term:          rr.opts.Term,
+			OfferingClass: rr.opts.OfferingClass,
+		}
+		family := ""
+		r.InstanceType = iru.InstanceType
+		switch service {
+		case "ec2":
+			if r.Product == "" {
+				r.Product = ec2.RIProductDescriptionLinuxUnix
+			}
+			// Only size flexible families are keyed without a size.
+			if !strings.Contains(iru.InstanceType, ".") {
+				family = iru.InstanceType
+			}
+		case "rds":
+			r.OfferingClass = ""
+			r.Product, r.InstanceType, _ = strings.Cut(iru.InstanceType, "/")
+			// Only size flexible families are keyed without a size, e.g.
+			// "db.m5" rather than "db.m5.large".
+			if strings.Count(r.InstanceType, ".") == 1 {
+				family = r.InstanceType
+			}
+		}
+
+		if family == "" {
+			r.Count = int64(math.Floor(gap))
+			rr.add(r)
+			continue
+		}
+		for _, size := range rr.sizes(r, family, iru.sizes) {
+			units, _ := rr.opts.NormalizedUnits.lookup(family, size)
+			count := int64(math.Floor(gap/units + 1e-9))
+			if count == 0 {
+				continue
+			}
+			gap -= float64(count) * units
+			r.InstanceType = family + "." + size
+			r.Count = count
+			rr.add(r)
+		}
+		if gap > 1e-9 {
+			rr.Remainders = append(rr.Remainders, &RIRemainder{
+				Scope:   r.Scope,
+				Service: service,
+				Family:  family,
+				Product: r.Product,
+				Units:   gap,
+			})
+		}
+	}
+}
+
+// utilizationFamily returns the family of the instance type or family a
+// utilization is keyed by, without the RDS product.
+func utilizationFamily(service string, iru *InstanceTypeReservationUtilization) string {
+	if service == "rds" {
+		_, class, _ := strings.Cut(iru.InstanceType, "/")
+		if strings.Count(class, ".") == 1 {
+			return class
+		}
+		return rdsInstanceType(class).family()
+	}
+	return ec2InstanceType(iru.InstanceType).family()
+}
+
+// sizes returns the sizes to choose from for a family, largest first.
+func (rr *RIRecommendations) sizes(r RIRecommendation, family string, running map[string]bool) []string {
+	sizes := make([]string, 0)
+	for _, size := range rr.opts.NormalizedUnits.sizes() {
+		if _, ok := rr.opts.Prices.Lookup(r.Service, r.Scope.Region, family+"."+size, r.Product); ok {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		for size := range running {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		unitsI, _ := rr.opts.NormalizedUnits.lookup(family, sizes[i])
+		unitsJ, _ := rr.opts.NormalizedUnits.lookup(family, sizes[j])
+		if unitsI != unitsJ {
+			return unitsI > unitsJ
+		}
+		return sizes[i] < sizes[j]
+	})
+	return sizes
+}
+
+// add adds a recommendation with the estimated savings, if there's a price.
+func (rr *RIRecommendations) add(r RIRecommendation) {
+	if r.Count <= 0 {
+		return
+	}
+	if price, ok := rr.opts.Prices.Lookup(r.Service, r.Scope.Region, r.InstanceType, r.Product); ok {
+		if savings, ok := price.AnnualSavings(r.Term, r.OfferingClass, float64(r.Count)); ok {
+			r.AnnualSavings = &savings
+		}
+	}
+	rr.Recommendations = append(rr.Recommendations, &r)
+}
+
+// RIRecommendationRecord is the JSON representation of a recommended
+// reservation.
+type RIRecommendationRecord struct {
+	Account       string   `json:"account" yaml:"account"`
+	Region        string   `json:"region" yaml:"region"`
+	Service       string   `json:"service" yaml:"service"`
+	InstanceType  string   `json:"instance_type" yaml:"instance_type"`
+	Product       string   `json:"product" yaml:"product"`
+	Tenancy       string   `json:"tenancy,omitempty" yaml:"tenancy,omitempty"`
+	Count         int64    `json:"count" yaml:"count"`
+	Term          string   `json:"term" yaml:"term"`
+	OfferingClass string   `json:"offering_class,omitempty" yaml:"offering_class,omitempty"`
+	AnnualSavings *float64 `json:"annual_savings" yaml:"annual_savings"`
+}
+
+func (rr *RIRecommendations) records() []RIRecommendationRecord {
+	records := make([]RIRecommendationRecord, 0, len(rr.Recommendations))
+	for _, r := range rr.Recommendations {
+		records = append(records, RIRecommendationRecord{
+			Account:       r.Scope.Account,
+			Region:        r.Scope.Region,
+			Service:       r.Service,
+			InstanceType:  r.InstanceType,
+			Product:       r.Product,
+			Tenancy:       r.Tenancy,
+			Count:         r.Count,
+			Term:          r.Term,
+			OfferingClass: r.OfferingClass,
+			AnnualSavings: r.AnnualSavings,
+		})
+	}
+	return records
+}
+
+// Table implements views.View
+func (rr *RIRecommendations) Table() *Table {
+	table := NewTable(
+		"Account",
+		"Region",
+		"Service",
+		"Instance Type",
+		"Product",
+		"Tenancy",
+		"Count",
+		"Term",
+		"Offering Class",
+		"Est. Annual Savings",
+	)
+
+	total := 0.0
+	for _, r := range rr.records() {
+		savings := ""
+		if r.AnnualSavings != nil {
+			total += *r.AnnualSavings
+			savings = fmt.Sprintf("%.2f", *r.AnnualSavings)
+		}
+		table.Append(
+			r.Account,
+			r.Region,
+			r.Service,
+			r.InstanceType,
+			r.Product,
+			r.Tenancy,
+			strconv.FormatInt(r.Count, 10),
+			r.Term,
+			r.OfferingClass,
+			savings,
+		)
+	}
+
+	table.Summary = []string{
+		fmt.Sprintf("%d Reservations to Buy", len(rr.Recommendations)),
+		fmt.Sprintf("Est. Annual Savings: %.2f", total),
+	}
+	for _, r := range rr.Remainders {
+		table.Summary = append(table.Summary, fmt.Sprintf(
+			"Not Covered: %g normalized units of %s %s in %s, fewer than any size", r.Units, r.Product, r.Family, r.Scope))
+	}
+	return table
+}
+
+// Records implements views.View
+func (rr *RIRecommendations) Records() interface{} {
+	return rr.records()
+}
@@ -0,0 +1,147 @@
+package views
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func recommendationSummary(rr *RIRecommendations) []string {
+	summary := make([]string, 0)
+	for _, r := range rr.Recommendations {
+		summary = append(summary, fmt.Sprintf("%s %s x%d", r.Product, r.InstanceType, r.Count))
+	}
+	return summary
+}
+
+func TestRIRecommendations(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+
+	windows := makeEC2Instance("i-5", "c5.large")
+	windows.Platform = aws.String(ec2.PlatformValuesWindows)
+	instances := map[models.Scope][]*ec2.Instance{east: {
+		makeEC2Instance("i-1", "m5.large"),
+		makeEC2Instance("i-2", "m5.large"),
+		makeEC2Instance("i-3", "m5.large"),
+		makeEC2Instance("i-4", "m5.xlarge"),
+		windows,
+		makeEC2Instance("i-6", "r5.large"),
+	}}
+	reservations := map[models.Scope][]*ec2.ReservedInstances{east: {
+		// r5 has more reserved than is running, so nothing is recommended
+		// for it.
+		makeEC2Reservation("r5.xlarge", 1),
+	}}
+	ec2Now := NewReservationUtilization(instances, reservations, ReservationUtilizationOptions{})
+
+	sqlserver := makeRDSInstance("db-3", "db.m5.xlarge", "sqlserver-se", true)
+	sqlserver.LicenseModel = aws.String("license-included")
+	dbs := map[models.Scope][]*rds.DBInstance{east: {
+		makeRDSInstance("db-1", "db.m5.large", "postgres", false),
+		makeRDSInstance("db-2", "db.m5.large", "postgres", false),
+		sqlserver,
+	}}
+	rdsNow := NewRDSReservationUtilization(dbs, nil, RDSReservationUtilizationOptions{})
+
+	rr := NewRIRecommendations(ec2Now, ec2Now, rdsNow, rdsNow, RIRecommendationOptions{})
+
+	// Without prices, the sizes which are running are used.
+	assert.Equal(t, []string{
+		"Windows c5.large x1",
+		"Linux/UNIX m5.xlarge x2",
+		"Linux/UNIX m5.large x1",
+		"postgresql db.m5.large x2",
+		"sqlserver-se(li) db.m5.xlarge x2",
+	}, recommendationSummary(rr))
+	assert.Equal(t, pricing.Standard, rr.Recommendations[0].OfferingClass)
+	assert.Equal(t, "", rr.Recommendations[3].OfferingClass)
+	assert.Nil(t, rr.Recommendations[0].AnnualSavings)
+}
+
+func TestRIRecommendationsWithPrices(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	prices := &pricing.Table{Prices: []pricing.Price{
+		{Service: "ec2", Region: "us-east-1", InstanceType: "m5.large", OnDemand: 0.1, Reserved: map[string]float64{"3yr/convertible": 0.05}},
+		{Service: "ec2", Region: "us-east-1", InstanceType: "m5.2xlarge", OnDemand: 0.4, Reserved: map[string]float64{"3yr/convertible": 0.2}},
+	}}
+
+	instances := map[models.Scope][]*ec2.Instance{east: {
+		makeEC2Instance("i-1", "m5.xlarge"),
+		makeEC2Instance("i-2", "m5.xlarge"),
+		makeEC2Instance("i-3", "m5.large"),
+	}}
+	ec2Now := NewReservationUtilization(instances, nil, ReservationUtilizationOptions{})
+
+	rr := NewRIRecommendations(ec2Now, ec2Now, nil, nil, RIRecommendationOptions{
+		Term:          pricing.ThreeYear,
+		OfferingClass: pricing.Convertible,
+		Prices:        prices,
+	})
+
+	// 20 units are covered by the largest sizes with a price.
+	assert.Equal(t, []string{"Linux/UNIX m5.2xlarge x1", "Linux/UNIX m5.large x1"}, recommendationSummary(rr))
+	assert.InDelta(t, 0.2*pricing.HoursPerYear, *rr.Recommendations[0].AnnualSavings, 1e-6)
+	assert.InDelta(t, 0.05*pricing.HoursPerYear, *rr.Recommendations[1].AnnualSavings, 1e-6)
+
+	table := rr.Table()
+	assert.Equal(t, []string{"2 Reservations to Buy", "Est. Annual Savings: 2190.00"}, table.Summary)
+}
+
+func TestRIRecommendationsOnlyForSustainedGaps(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	old := makeEC2Instance("i-1", "m5.large")
+	recent := makeEC2Instance("i-2", "m5.large")
+
+	ec2Now := NewReservationUtilization(map[models.Scope][]*ec2.Instance{east: {old, recent}}, nil, ReservationUtilizationOptions{})
+	ec2Sustained := NewReservationUtilization(map[models.Scope][]*ec2.Instance{east: {old}}, nil, ReservationUtilizationOptions{})
+
+	rr := NewRIRecommendations(ec2Now, ec2Sustained, nil, nil, RIRecommendationOptions{})
+	assert.Equal(t, []string{"Linux/UNIX m5.large x1"}, recommendationSummary(rr))
+}
+
+func TestRIRecommendationsSkipFamiliesWithUnusedReservations(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	// The zonal reservation is unused, since the instances run in another
+	// zone, and could be made regional to cover them.
+	zonal := makeEC2Reservation("m5.large", 1)
+	zonal.Scope = aws.String(ec2.ScopeAvailabilityZone)
+	zonal.AvailabilityZone = aws.String("us-east-1a")
+
+	instances := map[models.Scope][]*ec2.Instance{east: {
+		makeEC2Instance("i-1", "m5.large"),
+		makeEC2Instance("i-2", "c5.large"),
+	}}
+	reservations := map[models.Scope][]*ec2.ReservedInstances{east: {zonal}}
+	ec2Now := NewReservationUtilization(instances, reservations, ReservationUtilizationOptions{})
+
+	rr := NewRIRecommendations(ec2Now, ec2Now, nil, nil, RIRecommendationOptions{})
+	assert.Equal(t, []string{"Linux/UNIX c5.large x1"}, recommendationSummary(rr))
+}
+
+func TestRIRecommendationsReportRemainders(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	prices := &pricing.Table{Prices: []pricing.Price{
+		{Service: "ec2", Region: "us-east-1", InstanceType: "m5.xlarge", OnDemand: 0.2, Reserved: map[string]float64{"1yr/standard": 0.1}},
+	}}
+
+	instances := map[models.Scope][]*ec2.Instance{east: {
+		makeEC2Instance("i-1", "m5.xlarge"),
+		makeEC2Instance("i-2", "m5.large"),
+	}}
+	ec2Now := NewReservationUtilization(instances, nil, ReservationUtilizationOptions{})
+
+	rr := NewRIRecommendations(ec2Now, ec2Now, nil, nil, RIRecommendationOptions{Prices: prices})
+
+	// Only m5.xlarge has a price, which is too large for the m5.large.
+	assert.Equal(t, []string{"Linux/UNIX m5.xlarge x1"}, recommendationSummary(rr))
+	assert.Equal(t, []*RIRemainder{
+		{Scope: east, Service: "ec2", Family: "m5", Product: "Linux/UNIX", Units: 4},
+	}, rr.Remainders)
+	assert.Equal(t, "Not Covered: 4 normalized units of Linux/UNIX m5 in us-east-1, fewer than any size", rr.Table().Summary[2])
+}
@@ -1,51 +1,73 @@
 package views
 
 import (
-	"io"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jonstacks/aws/pkg/models"
 	"github.com/jonstacks/aws/pkg/utils"
-	"github.com/olekukonko/tablewriter"
 )
 
 // S3ReplicationAudit is a S3ReplicationAudit View
 type S3ReplicationAudit struct {
-	buckets      []*s3.Bucket
-	replications []*s3.GetBucketReplicationOutput
+	buckets      map[models.Scope][]*s3.Bucket
+	replications map[models.Scope][]*s3.GetBucketReplicationOutput
 }
 
 // NewS3ReplicationAudit initializes the S3 Replication Audit from the
+// buckets. The replications of each scope are in the same order as its
 // buckets.
-func NewS3ReplicationAudit(buckets []*s3.Bucket, replications []*s3.GetBucketReplicationOutput) S3ReplicationAudit {
-	return S3ReplicationAudit{
+func NewS3ReplicationAudit(buckets map[models.Scope][]*s3.Bucket, replications map[models.Scope][]*s3.GetBucketReplicationOutput) *S3ReplicationAudit {
+	return &S3ReplicationAudit{
 		buckets:      buckets,
 		replications: replications,
 	}
 }
 
-// Render implments view.View and renders the table
-func (v *S3ReplicationAudit) Render(w io.Writer) {
-	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{
+// BucketReplicationRecord is the JSON representation of a bucket and the
+// buckets it is replicated to.
+type BucketReplicationRecord struct {
+	Account      string   `json:"account" yaml:"account"`
+	Bucket       string   `json:"bucket" yaml:"bucket"`
+	Destinations []string `json:"destinations" yaml:"destinations"`
+}
+
+func (v *S3ReplicationAudit) records() []BucketReplicationRecord {
+	records := make([]BucketReplicationRecord, 0)
+	for _, scope := range models.SortedScopes(v.buckets) {
+		for i, bucket := range v.buckets[scope] {
+			replication := v.replications[scope][i]
+			destinations := make([]string, 0)
+			if replication != nil {
+				destinations = s3ReplicationRules(replication.ReplicationConfiguration.Rules).Buckets()
+			}
+			records = append(records, BucketReplicationRecord{
+				Account:      scope.Account,
+				Bucket:       aws.StringValue(bucket.Name),
+				Destinations: destinations,
+			})
+		}
+	}
+	return records
+}
+
+// Table implements views.View
+func (v *S3ReplicationAudit) Table() *Table {
+	table := NewTable(
+		"Account",
 		"Name",
 		"Cross Region Replication",
-	})
-
-	for i, bucket := range v.buckets {
-		replication := v.replications[i]
-		replicationStatus := ""
-		if replication != nil {
-			replicationBuckets := s3ReplicationRules(replication.ReplicationConfiguration.Rules).Buckets()
-			replicationStatus = strings.Join(replicationBuckets, ",")
-		}
-		table.Append([]string{
-			aws.StringValue(bucket.Name),
-			replicationStatus,
-		})
+	)
+	for _, r := range v.records() {
+		table.Append(r.Account, r.Bucket, strings.Join(r.Destinations, ","))
 	}
-	table.Render()
+	return table
+}
+
+// Records implements views.View
+func (v *S3ReplicationAudit) Records() interface{} {
+	return v.records()
 }
 
 type s3ReplicationRules []*s3.ReplicationRule
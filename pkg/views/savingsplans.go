@@ -0,0 +1,276 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/pricing"
+)
+
+// AccountCoverage is how much of the on demand spend of the running EC2
+// instances in an account is covered by reservations and savings plans. The
+// spend is the hourly on demand price of the instances, while the commitment
+// is the hourly amount the savings plans are paid at their own rates.
+type AccountCoverage struct {
+	Account               string
+	OnDemand              float64
+	CoveredByReservations float64
+	CoveredBySavingsPlans float64
+	Uncovered             float64
+	Commitment            float64
+	CommitmentUsed        float64
+	// Unpriced holds the IDs of the instances without a price, which aren't
+	// included in the spend.
+	Unpriced []string
+}
+
+// UnusedCommitment returns how much of the hourly commitment isn't used by
+// the running instances.
+func (ac *AccountCoverage) UnusedCommitment() float64 {
+	return math.Max(0, ac.Commitment-ac.CommitmentUsed)
+}
+
+// SavingsPlansCoverage is a view of how much of the on demand spend of each
+// account is covered by reservations and savings plans, and what remains.
+type SavingsPlansCoverage struct {
+	Accounts []*AccountCoverage
+}
+
+// pendingInstance is an instance which isn't covered by a reservation, along
+// with how much of it isn't covered by a savings plan yet.
+type pendingInstance struct {
+	region    string
+	family    string
+	price     pricing.Price
+	remaining float64
+}
+
+func (pi *pendingInstance) discount() float64 {
+	if pi.price.OnDemand <= 0 {
+		return 0
+	}
+	return 1 - pi.price.SavingsPlanRate()/pi.price.OnDemand
+}
+
+// SavingsPlansCoverageOptions are options which modify the
+// SavingsPlansCoverage view.
+type SavingsPlansCoverageOptions struct {
+	// NormalizedUnits are used on top of the built in normalized units to
+	// match size flexible reservations. It may be nil.
+	NormalizedUnits *NormalizedUnits
+}
+
+// NewSavingsPlansCoverage creates a view of the coverage of the running
+// instances. The savings plans are keyed by account, without a region. They
+// are applied the way AWS bills them: after reservations, EC2 instance
+// savings plans for the family and region first and then compute savings
+// plans, each to the instances with the largest discount first. Other types
+// of savings plans, which don't apply to EC2, are ignored.
+func NewSavingsPlansCoverage(instances map[models.Scope][]*ec2.Instance, reservations map[models.Scope][]*ec2.ReservedInstances, plans map[models.Scope][]*savingsplans.SavingsPlan, prices *pricing.Table, opts SavingsPlansCoverageOptions) *SavingsPlansCoverage {
+	notReserved := make(map[string]bool)
+	ru := NewReservationUtilization(instances, reservations, ReservationUtilizationOptions{NormalizedUnits: opts.NormalizedUnits})
+	for _, utilizations := range ru.InstanceTypeReservationUtilizations {
+		for _, iru := range utilizations {
+			for _, id := range iru.Uncovered {
+				notReserved[id] = true
+			}
+		}
+	}
+
+	accounts := make(map[string]*AccountCoverage)
+	account := func(id string) *AccountCoverage {
+		if _, ok := accounts[id]; !ok {
+			accounts[id] = &AccountCoverage{Account: id}
+		}
+		return accounts[id]
+	}
+
+	pending := make(map[string][]*pendingInstance)
+	for _, scope := range models.SortedScopes(instances) {
+		ac := account(scope.Account)
+		for _, i := range instances[scope] {
+			key := instanceKey(i)
+			price, ok := prices.Lookup("ec2", scope.Region, key.InstanceType, key.Platform)
+			if !ok {
+				ac.Unpriced = append(ac.Unpriced, aws.StringValue(i.InstanceId))
+				continue
+			}
+			ac.OnDemand += price.OnDemand
+			if !notReserved[aws.StringValue(i.InstanceId)] {
+				ac.CoveredByReservations += price.OnDemand
+				continue
+			}
+			pending[scope.Account] = append(pending[scope.Account], &pendingInstance{
+				region:    scope.Region,
+				family:    ec2InstanceType(key.InstanceType).family(),
+				price:     price,
+				remaining: 1,
+			})
+		}
+	}
+
+	for _, scope := range models.SortedScopes(plans) {
+		ac := account(scope.Account)
+		sorted := make([]*savingsplans.SavingsPlan, 0)
+		for _, p := range plans[scope] {
+			switch aws.StringValue(p.SavingsPlanType) {
+			case savingsplans.SavingsPlanTypeEc2instance, savingsplans.SavingsPlanTypeCompute:
+				sorted = append(sorted, p)
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return aws.StringValue(sorted[i].SavingsPlanType) == savingsplans.SavingsPlanTypeEc2instance &&
+				aws.StringValue(sorted[j].SavingsPlanType) != savingsplans.SavingsPlanTypeEc2instance
+		})
+		for _, p := range sorted {
+			ac.applySavingsPlan(p, pending[scope.Account])
+		}
+	}
+
+	cov := &SavingsPlansCoverage{Accounts: make([]*AccountCoverage, 0, len(accounts))}
+	for id, ac := range accounts {
+		for _, pi := range pending[id] {
+			ac.Uncovered += pi.remaining * pi.price.OnDemand
+		}
+		cov.Accounts = append(cov.Accounts, ac)
+	}
+	sort.Slice(cov.Accounts, func(i, j int) bool { return cov.Accounts[i].Account < cov.Accounts[j].Account })
+	return cov
+}
+
+// applySavingsPlan covers as much of the instances the plan applies to as its
+// commitment allows.
+func (ac *AccountCoverage) applySavingsPlan(p *savingsplans.SavingsPlan, instances []*pendingInstance) {
+	commitment, _ := strconv.ParseFloat(aws.StringValue(p.Commitment), 64)
+	ac.Commitment += commitment
+
+	eligible := make([]*pendingInstance, 0)
+	for _, pi := range instances {
+		if aws.StringValue(p.SavingsPlanType) == savingsplans.SavingsPlanTypeEc2instance &&
+			(pi.region != aws.StringValue(p.Region) || pi.family != aws.StringValue(p.Ec2InstanceFamily)) {
+			continue
+		}
+		eligible = append(eligible, pi)
+	}
+	sort.SliceStable(eligible, func(i, j int) bool { return eligible[i].discount() > eligible[j].discount() })
+
+	for _, pi := range eligible {
+		if commitment <= 0 {
+			return
+		}
+		covered := pi.remaining
+		cost := covered * pi.price.SavingsPlanRate()
+		if cost > commitment {
+			covered = commitment / pi.price.SavingsPlanRate()
+			cost = commitment
+		}
+		commitment -= cost
+		pi.remaining -= covered
+		ac.CommitmentUsed += cost
+		ac.CoveredBySavingsPlans += covered * pi.price.OnDemand
+	}
+}
+
+// AccountCoverageRecord is the JSON representation of an AccountCoverage.
+type AccountCoverageRecord struct {
+	Account               string   `json:"account" yaml:"account"`
+	OnDemand              float64  `json:"on_demand" yaml:"on_demand"`
+	CoveredByReservations float64  `json:"covered_by_reservations" yaml:"covered_by_reservations"`
+	CoveredBySavingsPlans float64  `json:"covered_by_savings_plans" yaml:"covered_by_savings_plans"`
+	Uncovered             float64  `json:"uncovered" yaml:"uncovered"`
+	Commitment            float64  `json:"commitment" yaml:"commitment"`
+	CommitmentUsed        float64  `json:"commitment_used" yaml:"commitment_used"`
+	UnusedCommitment      float64  `json:"unused_commitment" yaml:"unused_commitment"`
+	Unpriced              []string `json:"unpriced" yaml:"unpriced"`
+}
+
+func (cov *SavingsPlansCoverage) records() []AccountCoverageRecord {
+	records := make([]AccountCoverageRecord, 0, len(cov.Accounts))
+	for _, ac := range cov.Accounts {
+		unpriced := ac.Unpriced
+		if unpriced == nil {
+			unpriced = make([]string, 0)
+		}
+		records = append(records, AccountCoverageRecord{
+			Account:               ac.Account,
+			OnDemand:              ac.OnDemand,
+			CoveredByReservations: ac.CoveredByReservations,
+			CoveredBySavingsPlans: ac.CoveredBySavingsPlans,
+			Uncovered:             ac.Uncovered,
+			Commitment:            ac.Commitment,
+			CommitmentUsed:        ac.CommitmentUsed,
+			UnusedCommitment:      ac.UnusedCommitment(),
+			Unpriced:              unpriced,
+		})
+	}
+	return records
+}
+
+// Table implements views.View. All of the amounts are hourly.
+func (cov *SavingsPlansCoverage) Table() *Table {
+	table := NewTable(
+		"Account",
+		"On Demand",
+		"Covered by RIs",
+		"Commitment",
+		"Commitment Used",
+		"Covered by Savings Plans",
+		"Uncovered",
+		"Unpriced Instances",
+	)
+	unpriced := 0
+	for _, r := range cov.records() {
+		unpriced += len(r.Unpriced)
+		table.Append(
+			r.Account,
+			fmt.Sprintf("%.2f", r.OnDemand),
+			fmt.Sprintf("%.2f", r.CoveredByReservations),
+			fmt.Sprintf("%.2f", r.Commitment),
+			fmt.Sprintf("%.2f", r.CommitmentUsed),
+			fmt.Sprintf("%.2f", r.CoveredBySavingsPlans),
+			fmt.Sprintf("%.2f", r.Uncovered),
+			strconv.Itoa(len(r.Unpriced)),
+		)
+	}
+	if unpriced > 0 {
+		table.Summary = []string{fmt.Sprintf("%d Instances Without a Price Aren't Included", unpriced)}
+	}
+	return table
+}
+
+// Records implements views.View
+func (cov *SavingsPlansCoverage) Records() interface{} {
+	return cov.records()
+}
+
+// Findings implements policy.Auditor. Unused commitment is paid for either
+// way, like unused reservations.
+func (cov *SavingsPlansCoverage) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0)
+	for _, ac := range cov.Accounts {
+		if unused := ac.UnusedCommitment(); unused >= 0.005 {
+			findings = append(findings, policy.Finding{
+				Scope:    models.Scope{Account: ac.Account},
+				Severity: policy.Medium,
+				Resource: "savings plans",
+				Message:  fmt.Sprintf("%.2f of the hourly commitment of %.2f is unused", unused, ac.Commitment),
+			})
+		}
+		if ac.Uncovered >= 0.005 {
+			findings = append(findings, policy.Finding{
+				Scope:    models.Scope{Account: ac.Account},
+				Severity: policy.Low,
+				Resource: "on demand spend",
+				Message:  fmt.Sprintf("%.2f an hour isn't covered by reservations or savings plans", ac.Uncovered),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,99 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/savingsplans"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavingsPlansCoverage(t *testing.T) {
+	east := models.Scope{Region: "us-east-1"}
+	prices := &pricing.Table{Prices: []pricing.Price{
+		{Service: "ec2", Region: "us-east-1", InstanceType: "m5.large", OnDemand: 0.1, SavingsPlan: 0.07},
+		{Service: "ec2", Region: "us-east-1", InstanceType: "c5.large", Product: "Windows", OnDemand: 0.2, SavingsPlan: 0.17},
+	}}
+
+	windows := makeEC2Instance("i-3", "c5.large")
+	windows.Platform = aws.String(ec2.PlatformValuesWindows)
+	cov := NewSavingsPlansCoverage(
+		map[models.Scope][]*ec2.Instance{east: {
+			makeEC2Instance("i-1", "m5.large"),
+			makeEC2Instance("i-2", "m5.large"),
+			windows,
+			makeEC2Instance("i-4", "r5.large"),
+		}},
+		map[models.Scope][]*ec2.ReservedInstances{east: {makeEC2Reservation("m5.large", 1)}},
+		map[models.Scope][]*savingsplans.SavingsPlan{{}: {
+			{
+				SavingsPlanType: aws.String(savingsplans.SavingsPlanTypeCompute),
+				Commitment:      aws.String("0.1"),
+			},
+			{
+				SavingsPlanType:   aws.String(savingsplans.SavingsPlanTypeEc2instance),
+				Ec2InstanceFamily: aws.String("c5"),
+				Region:            aws.String("us-east-1"),
+				Commitment:        aws.String("0.05"),
+			},
+			{
+				SavingsPlanType: aws.String(savingsplans.SavingsPlanTypeSageMaker),
+				Commitment:      aws.String("5"),
+			},
+		}},
+		prices,
+		SavingsPlansCoverageOptions{},
+	)
+
+	assert.Len(t, cov.Accounts, 1)
+	ac := cov.Accounts[0]
+	assert.InDelta(t, 0.4, ac.OnDemand, 1e-9)
+	// The reservation covers one of the m5.larges.
+	assert.InDelta(t, 0.1, ac.CoveredByReservations, 1e-9)
+	// The EC2 instance savings plan covers 0.05/0.17 of the c5.large. The
+	// compute savings plan then covers the other m5.large, which has the
+	// larger discount, and 0.03/0.17 of the c5.large with what's left.
+	assert.InDelta(t, 0.15, ac.Commitment, 1e-9)
+	assert.InDelta(t, 0.15, ac.CommitmentUsed, 1e-9)
+	assert.InDelta(t, 0.1+0.08/0.17*0.2, ac.CoveredBySavingsPlans, 1e-9)
+	assert.InDelta(t, 0.09/0.17*0.2, ac.Uncovered, 1e-9)
+	assert.Equal(t, []string{"i-4"}, ac.Unpriced)
+
+	findings := cov.Findings()
+	assert.Len(t, findings, 1)
+	assert.Equal(t, policy.Low, findings[0].Severity)
+	assert.Equal(t, []string{"1 Instances Without a Price Aren't Included"}, cov.Table().Summary)
+}
+
+func TestSavingsPlansCoverageUnusedCommitment(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	prices := &pricing.Table{Prices: []pricing.Price{
+		{Service: "ec2", Region: "us-east-1", InstanceType: "m5.large", OnDemand: 0.1},
+	}}
+
+	cov := NewSavingsPlansCoverage(
+		map[models.Scope][]*ec2.Instance{east: {makeEC2Instance("i-1", "m5.large")}},
+		nil,
+		map[models.Scope][]*savingsplans.SavingsPlan{{Account: "111111111111"}: {{
+			SavingsPlanType: aws.String(savingsplans.SavingsPlanTypeCompute),
+			Commitment:      aws.String("0.5"),
+		}}},
+		prices,
+		SavingsPlansCoverageOptions{},
+	)
+
+	ac := cov.Accounts[0]
+	// Without a savings plan price, instances are covered at the on demand
+	// price.
+	assert.InDelta(t, 0.1, ac.CommitmentUsed, 1e-9)
+	assert.InDelta(t, 0.4, ac.UnusedCommitment(), 1e-9)
+	assert.Equal(t, 0.0, ac.Uncovered)
+
+	findings := cov.Findings()
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "[medium] 111111111111 savings plans: 0.40 of the hourly commitment of 0.50 is unused", findings[0].String())
+}
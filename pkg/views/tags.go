@@ -0,0 +1,440 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/jonstacks/aws/pkg/utils"
+)
+
+// TaggableResources are the resources whose tags are audited. Buckets are
+// keyed by account, without a region, and BucketTags is keyed by bucket name.
+type TaggableResources struct {
+	Instances      map[models.Scope][]*ec2.Instance
+	Volumes        map[models.Scope][]*ec2.Volume
+	DBInstances    map[models.Scope][]*rds.DBInstance
+	SecurityGroups map[models.Scope][]*ec2.SecurityGroup
+	Buckets        map[models.Scope][]*s3.Bucket
+	BucketTags     map[models.Scope]map[string][]*s3.Tag
+
+	// VPCs, Subnets and AutoScalingGroups are only needed for remediation,
+	// since missing tags can be inherited from them.
+	VPCs              map[models.Scope][]*ec2.Vpc
+	Subnets           map[models.Scope][]*ec2.Subnet
+	AutoScalingGroups map[models.Scope][]*autoscaling.Group
+}
+
+// TaggedResource is a resource along with its tags.
+type TaggedResource struct {
+	Scope models.Scope
+	// Kind is one of policy.TagResourceKinds.
+	Kind string
+	ID   string
+	Name string
+	Tags map[string]string
+
+	// The resources the tags can be inherited from. Volumes have the ones of
+	// the instance they're attached to, and security groups only a VPC.
+	VPCID            string
+	SubnetID         string
+	AutoScalingGroup string
+}
+
+func ec2Tags(tags []*ec2.Tag) map[string]string {
+	m := make(map[string]string)
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+func rdsTags(tags []*rds.Tag) map[string]string {
+	m := make(map[string]string)
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+func s3Tags(tags []*s3.Tag) map[string]string {
+	m := make(map[string]string)
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+// resources returns the resources with their tags, sorted by scope and then
+// in the order they were described. Terminated instances and deleted volumes
+// are left out.
+func (tr TaggableResources) resources() []TaggedResource {
+	resources := make([]TaggedResource, 0)
+	instances := make(map[models.Scope]map[string]TaggedResource)
+	for _, scope := range models.SortedScopes(tr.Instances) {
+		instances[scope] = make(map[string]TaggedResource)
+		for _, i := range tr.Instances[scope] {
+			if i.State != nil && (aws.StringValue(i.State.Name) == ec2.InstanceStateNameTerminated ||
+				aws.StringValue(i.State.Name) == ec2.InstanceStateNameShuttingDown) {
+				continue
+			}
+			r := TaggedResource{
+				Scope:            scope,
+				Kind:             policy.TagInstance,
+				ID:               aws.StringValue(i.InstanceId),
+				Name:             utils.GetInstanceName(i),
+				Tags:             ec2Tags(i.Tags),
+				VPCID:            aws.StringValue(i.VpcId),
+				SubnetID:         aws.StringValue(i.SubnetId),
+				AutoScalingGroup: utils.GetTagValue(i.Tags, "aws:autoscaling:groupName"),
+			}
+			instances[scope][r.ID] = r
+			resources = append(resources, r)
+		}
+	}
+	for _, scope := range models.SortedScopes(tr.Volumes) {
+		for _, v := range tr.Volumes[scope] {
+			state := aws.StringValue(v.State)
+			if state == ec2.VolumeStateDeleting || state == ec2.VolumeStateDeleted {
+				continue
+			}
+			r := TaggedResource{
+				Scope: scope,
+				Kind:  policy.TagVolume,
+				ID:    aws.StringValue(v.VolumeId),
+				Name:  utils.GetTagValue(v.Tags, "Name"),
+				Tags:  ec2Tags(v.Tags),
+			}
+			if len(v.Attachments) > 0 {
+				i := instances[scope][aws.StringValue(v.Attachments[0].InstanceId)]
+				r.VPCID, r.SubnetID, r.AutoScalingGroup = i.VPCID, i.SubnetID, i.AutoScalingGroup
+			}
+			resources = append(resources, r)
+		}
+	}
+	for _, scope := range models.SortedScopes(tr.DBInstances) {
+		for _, db := range tr.DBInstances[scope] {
+			resources = append(resources, TaggedResource{
+				Scope: scope,
+				Kind:  policy.TagDBInstance,
+				ID:    aws.StringValue(db.DBInstanceIdentifier),
+				Tags:  rdsTags(db.TagList),
+			})
+		}
+	}
+	for _, scope := range models.SortedScopes(tr.SecurityGroups) {
+		for _, sg := range tr.SecurityGroups[scope] {
+			resources = append(resources, TaggedResource{
+				Scope: scope,
+				Kind:  policy.TagSecurityGroup,
+				ID:    aws.StringValue(sg.GroupId),
+				Name:  aws.StringValue(sg.GroupName),
+				Tags:  ec2Tags(sg.Tags),
+				VPCID: aws.StringValue(sg.VpcId),
+			})
+		}
+	}
+	for _, scope := range models.SortedScopes(tr.Buckets) {
+		for _, b := range tr.Buckets[scope] {
+			resources = append(resources, TaggedResource{
+				Scope: scope,
+				Kind:  policy.TagBucket,
+				ID:    aws.StringValue(b.Name),
+				Tags:  s3Tags(tr.BucketTags[scope][aws.StringValue(b.Name)]),
+			})
+		}
+	}
+	return resources
+}
+
+// ResourceTagViolation is a tag of a resource which doesn't comply with a
+// rule of the tag policy.
+type ResourceTagViolation struct {
+	Resource TaggedResource
+	*policy.TagViolation
+}
+
+// TagCompliance is a view of the resources which don't comply with a tag
+// policy.
+type TagCompliance struct {
+	// Checked is the number of resources the policy was evaluated against.
+	Checked    int
+	Violations []ResourceTagViolation
+}
+
+// NewTagCompliance evaluates the tag policy against the resources.
+func NewTagCompliance(resources TaggableResources, p *policy.TagPolicy) *TagCompliance {
+	tc := &TagCompliance{Violations: make([]ResourceTagViolation, 0)}
+	for _, r := range resources.resources() {
+		tc.Checked++
+		for _, v := range p.Evaluate(r.Kind, r.Tags) {
+			tc.Violations = append(tc.Violations, ResourceTagViolation{Resource: r, TagViolation: v})
+		}
+	}
+	return tc
+}
+
+// TagViolationRecord is the JSON representation of a ResourceTagViolation.
+type TagViolationRecord struct {
+	Account  string `json:"account" yaml:"account"`
+	Region   string `json:"region" yaml:"region"`
+	Kind     string `json:"kind" yaml:"kind"`
+	Resource string `json:"resource" yaml:"resource"`
+	Name     string `json:"name" yaml:"name"`
+	Key      string `json:"key" yaml:"key"`
+	Problem  string `json:"problem" yaml:"problem"`
+	Value    string `json:"value" yaml:"value"`
+	Rule     string `json:"rule" yaml:"rule"`
+	Severity string `json:"severity" yaml:"severity"`
+}
+
+func (tc *TagCompliance) records() []TagViolationRecord {
+	records := make([]TagViolationRecord, 0, len(tc.Violations))
+	for _, v := range tc.Violations {
+		records = append(records, TagViolationRecord{
+			Account:  v.Resource.Scope.Account,
+			Region:   v.Resource.Scope.Region,
+			Kind:     v.Resource.Kind,
+			Resource: v.Resource.ID,
+			Name:     v.Resource.Name,
+			Key:      v.Rule.Key,
+			Problem:  v.Problem(),
+			Value:    v.Value,
+			Rule:     v.Rule.String(),
+			Severity: v.Rule.Level().String(),
+		})
+	}
+	return records
+}
+
+// Table implements views.View
+func (tc *TagCompliance) Table() *Table {
+	table := NewTable("Account", "Region", "Kind", "Resource", "Name", "Key", "Problem", "Value", "Rule")
+	resources := make(map[string]bool)
+	for _, r := range tc.records() {
+		resources[r.Kind+"/"+r.Account+"/"+r.Region+"/"+r.Resource] = true
+		table.Append(r.Account, r.Region, r.Kind, r.Resource, r.Name, r.Key, r.Problem, r.Value, r.Rule)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("%d of %d Resources Don't Comply", len(resources), tc.Checked),
+	}
+	return table
+}
+
+// Records implements views.View
+func (tc *TagCompliance) Records() interface{} {
+	return tc.records()
+}
+
+// Findings implements policy.Auditor. The severity of each finding is the
+// severity of the rule it violates.
+func (tc *TagCompliance) Findings() []policy.Finding {
+	findings := make([]policy.Finding, 0, len(tc.Violations))
+	for _, v := range tc.Violations {
+		findings = append(findings, policy.Finding{
+			Scope:    v.Resource.Scope,
+			Severity: v.Rule.Level(),
+			Resource: v.Resource.ID,
+			Message:  v.TagViolation.String(),
+		})
+	}
+	return findings
+}
+
+// inheritedTags returns the tags of the resources missing tags can be
+// inherited from, keyed by scope, source and ID.
+func (tr TaggableResources) inheritedTags() map[models.Scope]map[string]map[string]map[string]string {
+	tags := make(map[models.Scope]map[string]map[string]map[string]string)
+	add := func(scope models.Scope, source, id string, t map[string]string) {
+		if tags[scope] == nil {
+			tags[scope] = make(map[string]map[string]map[string]string)
+		}
+		if tags[scope][source] == nil {
+			tags[scope][source] = make(map[string]map[string]string)
+		}
+		tags[scope][source][id] = t
+	}
+	for scope, vpcs := range tr.VPCs {
+		for _, vpc := range vpcs {
+			add(scope, policy.InheritVPC, aws.StringValue(vpc.VpcId), ec2Tags(vpc.Tags))
+		}
+	}
+	for scope, subnets := range tr.Subnets {
+		for _, subnet := range subnets {
+			add(scope, policy.InheritSubnet, aws.StringValue(subnet.SubnetId), ec2Tags(subnet.Tags))
+		}
+	}
+	for scope, groups := range tr.AutoScalingGroups {
+		for _, g := range groups {
+			t := make(map[string]string)
+			for _, tag := range g.Tags {
+				t[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			add(scope, policy.InheritAutoScalingGroup, aws.StringValue(g.AutoScalingGroupName), t)
+		}
+	}
+	return tags
+}
+
+// TagChange is a missing tag which remediation sets on a resource.
+type TagChange struct {
+	Resource TaggedResource
+	Key      string
+	Value    string
+	// Source is where the value comes from, e.g. "subnet subnet-1" or
+	// "default".
+	Source string
+}
+
+// TagRemediationPlan is a view of the missing tags remediation sets. Tags
+// with invalid values are left as they are.
+type TagRemediationPlan struct {
+	Changes []TagChange
+	// Unresolved are the missing tags which can't be set, either because no
+	// value was found for them or because the resource isn't an EC2 resource.
+	Unresolved []ResourceTagViolation
+}
+
+// NewTagRemediationPlan plans how the missing tags of the audited resources
+// are set. A value is only used if it complies with every rule the missing
+// tag violates.
+func NewTagRemediationPlan(resources TaggableResources, tc *TagCompliance, r *policy.TagRemediation) *TagRemediationPlan {
+	plan := &TagRemediationPlan{
+		Changes:    make([]TagChange, 0),
+		Unresolved: make([]ResourceTagViolation, 0),
+	}
+	inherited := resources.inheritedTags()
+
+	// Several rules can require the same tag, so the violations are grouped
+	// by resource and key first.
+	type missingTag struct {
+		violation ResourceTagViolation
+		rules     []*policy.TagRule
+	}
+	missing := make([]*missingTag, 0)
+	byKey := make(map[string]*missingTag)
+	for _, v := range tc.Violations {
+		if !v.Missing {
+			continue
+		}
+		k := v.Resource.Kind + "/" + v.Resource.Scope.String() + "/" + v.Resource.ID + "/" + v.Rule.Key
+		if m, ok := byKey[k]; ok {
+			m.rules = append(m.rules, v.Rule)
+			continue
+		}
+		byKey[k] = &missingTag{violation: v, rules: []*policy.TagRule{v.Rule}}
+		missing = append(missing, byKey[k])
+	}
+
+	for _, m := range missing {
+		resource, key := m.violation.Resource, m.violation.Rule.Key
+		valid := func(value string) bool {
+			for _, rule := range m.rules {
+				if rule.Check(map[string]string{key: value}) != nil {
+					return false
+				}
+			}
+			return value != ""
+		}
+
+		remedy := r.Remedy(key)
+		if remedy == nil || resource.Kind == policy.TagDBInstance || resource.Kind == policy.TagBucket {
+			plan.Unresolved = append(plan.Unresolved, m.violation)
+			continue
+		}
+
+		change := TagChange{Resource: resource, Key: key}
+		for _, source := range remedy.Inherit {
+			id := resource.parent(source)
+			if id == "" {
+				continue
+			}
+			if value := inherited[resource.Scope][source][id][key]; valid(value) {
+				change.Value, change.Source = value, source+" "+id
+				break
+			}
+		}
+		if change.Value == "" && valid(remedy.Default) {
+			change.Value, change.Source = remedy.Default, "default"
+		}
+		if change.Value == "" {
+			plan.Unresolved = append(plan.Unresolved, m.violation)
+			continue
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan
+}
+
+// parent returns the ID of the resource of the source the resource's tags can
+// be inherited from, or an empty string if it has none.
+func (r TaggedResource) parent(source string) string {
+	switch source {
+	case policy.InheritVPC:
+		return r.VPCID
+	case policy.InheritSubnet:
+		return r.SubnetID
+	case policy.InheritAutoScalingGroup:
+		return r.AutoScalingGroup
+	}
+	return ""
+}
+
+// TagChangeRecord is the JSON representation of a TagChange.
+type TagChangeRecord struct {
+	Account  string `json:"account" yaml:"account"`
+	Region   string `json:"region" yaml:"region"`
+	Kind     string `json:"kind" yaml:"kind"`
+	Resource string `json:"resource" yaml:"resource"`
+	Name     string `json:"name" yaml:"name"`
+	Key      string `json:"key" yaml:"key"`
+	Value    string `json:"value" yaml:"value"`
+	Source   string `json:"source" yaml:"source"`
+}
+
+// Record returns the JSON representation of the change.
+func (c TagChange) Record() TagChangeRecord {
+	return TagChangeRecord{
+		Account:  c.Resource.Scope.Account,
+		Region:   c.Resource.Scope.Region,
+		Kind:     c.Resource.Kind,
+		Resource: c.Resource.ID,
+		Name:     c.Resource.Name,
+		Key:      c.Key,
+		Value:    c.Value,
+		Source:   c.Source,
+	}
+}
+
+func (p *TagRemediationPlan) records() []TagChangeRecord {
+	records := make([]TagChangeRecord, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		records = append(records, c.Record())
+	}
+	return records
+}
+
+// Table implements views.View
+func (p *TagRemediationPlan) Table() *Table {
+	table := NewTable("Account", "Region", "Kind", "Resource", "Name", "Key", "Value", "Source")
+	resources := make(map[string]bool)
+	for _, r := range p.records() {
+		resources[r.Kind+"/"+r.Account+"/"+r.Region+"/"+r.Resource] = true
+		table.Append(r.Account, r.Region, r.Kind, r.Resource, r.Name, r.Key, r.Value, r.Source)
+	}
+	table.Summary = []string{
+		fmt.Sprintf("%d Tags to Set on %d Resources", len(p.Changes), len(resources)),
+		fmt.Sprintf("%d Missing Tags Couldn't Be Resolved", len(p.Unresolved)),
+	}
+	return table
+}
+
+// Records implements views.View
+func (p *TagRemediationPlan) Records() interface{} {
+	return p.records()
+}
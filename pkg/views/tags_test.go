@@ -0,0 +1,159 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/jonstacks/aws/pkg/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagCompliance(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	account := models.Scope{Account: "111111111111"}
+	p := &policy.TagPolicy{Rules: []*policy.TagRule{
+		{Key: "env", Values: []string{"prod", "dev"}, Severity: "high"},
+		{Key: "owner", Resources: []string{policy.TagInstance, policy.TagBucket}},
+	}}
+	assert.NoError(t, p.Compile())
+
+	tagged := makeEC2Instance("i-1", "m5.large")
+	tagged.Tags = []*ec2.Tag{
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("owner"), Value: aws.String("alice")},
+	}
+	untagged := makeEC2Instance("i-2", "m5.large")
+	terminated := makeEC2Instance("i-3", "m5.large")
+	terminated.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+	db := makeRDSInstance("db-1", "db.m5.large", "postgres", false)
+	db.TagList = []*rds.Tag{{Key: aws.String("env"), Value: aws.String("qa")}}
+
+	tc := NewTagCompliance(TaggableResources{
+		Instances: map[models.Scope][]*ec2.Instance{east: {tagged, untagged, terminated}},
+		Volumes: map[models.Scope][]*ec2.Volume{east: {{
+			VolumeId: aws.String("vol-1"),
+			Tags:     []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("dev")}},
+		}}},
+		DBInstances: map[models.Scope][]*rds.DBInstance{east: {db}},
+		SecurityGroups: map[models.Scope][]*ec2.SecurityGroup{east: {{
+			GroupId:   aws.String("sg-1"),
+			GroupName: aws.String("web"),
+		}}},
+		Buckets: map[models.Scope][]*s3.Bucket{account: {{Name: aws.String("logs")}}},
+		BucketTags: map[models.Scope]map[string][]*s3.Tag{account: {
+			"logs": {{Key: aws.String("env"), Value: aws.String("prod")}},
+		}},
+	}, p)
+
+	assert.Equal(t, 6, tc.Checked)
+	records := tc.records()
+	summary := make([]string, 0)
+	for _, r := range records {
+		summary = append(summary, r.Resource+" "+r.Key+" "+r.Problem)
+	}
+	assert.Equal(t, []string{
+		"i-2 env missing",
+		"i-2 owner missing",
+		"db-1 env invalid",
+		"sg-1 env missing",
+		"logs owner missing",
+	}, summary)
+	assert.Equal(t, TagViolationRecord{
+		Account:  "111111111111",
+		Region:   "us-east-1",
+		Kind:     policy.TagDBInstance,
+		Resource: "db-1",
+		Key:      "env",
+		Problem:  "invalid",
+		Value:    "qa",
+		Rule:     "env in (prod, dev)",
+		Severity: "high",
+	}, records[2])
+
+	assert.Equal(t, []string{"4 of 6 Resources Don't Comply"}, tc.Table().Summary)
+
+	findings := tc.Findings()
+	assert.Len(t, findings, 5)
+	assert.Equal(t, `[high] 111111111111/us-east-1 i-2: tag "env" is missing (env in (prod, dev))`, findings[0].String())
+	assert.Equal(t, policy.Medium, findings[1].Severity)
+}
+
+func TestTagRemediationPlan(t *testing.T) {
+	east := models.Scope{Account: "111111111111", Region: "us-east-1"}
+	account := models.Scope{Account: "111111111111"}
+	p := &policy.TagPolicy{Rules: []*policy.TagRule{
+		{Key: "cost", Pattern: "[a-z]+"},
+		{Key: "env", Values: []string{"prod", "dev"}},
+	}}
+	assert.NoError(t, p.Compile())
+	r := &policy.TagRemediation{Tags: []*policy.TagRemedy{
+		{Key: "cost", Inherit: []string{policy.InheritAutoScalingGroup, policy.InheritSubnet, policy.InheritVPC}, Default: "unallocated"},
+	}}
+	assert.NoError(t, r.Validate())
+
+	inASG := makeEC2Instance("i-asg", "m5.large")
+	inASG.SubnetId, inASG.VpcId = aws.String("subnet-1"), aws.String("vpc-1")
+	inASG.Tags = []*ec2.Tag{
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("aws:autoscaling:groupName"), Value: aws.String("web")},
+	}
+	// The subnet's value isn't allowed by the policy, so the VPC's is used.
+	inSubnet := makeEC2Instance("i-subnet", "m5.large")
+	inSubnet.SubnetId, inSubnet.VpcId = aws.String("subnet-2"), aws.String("vpc-1")
+	inSubnet.Tags = []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("dev")}}
+
+	resources := TaggableResources{
+		Instances: map[models.Scope][]*ec2.Instance{east: {inASG, inSubnet}},
+		Volumes: map[models.Scope][]*ec2.Volume{east: {{
+			VolumeId:    aws.String("vol-1"),
+			Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("i-asg")}},
+			Tags:        []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("prod")}},
+		}}},
+		SecurityGroups: map[models.Scope][]*ec2.SecurityGroup{east: {{
+			GroupId: aws.String("sg-1"),
+			Tags:    []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("prod")}},
+		}}},
+		Buckets: map[models.Scope][]*s3.Bucket{account: {{Name: aws.String("logs")}}},
+		VPCs: map[models.Scope][]*ec2.Vpc{east: {{
+			VpcId: aws.String("vpc-1"),
+			Tags:  []*ec2.Tag{{Key: aws.String("cost"), Value: aws.String("platform")}},
+		}}},
+		Subnets: map[models.Scope][]*ec2.Subnet{east: {{
+			SubnetId: aws.String("subnet-2"),
+			Tags:     []*ec2.Tag{{Key: aws.String("cost"), Value: aws.String("Team-42")}},
+		}}},
+		AutoScalingGroups: map[models.Scope][]*autoscaling.Group{east: {{
+			AutoScalingGroupName: aws.String("web"),
+			Tags:                 []*autoscaling.TagDescription{{Key: aws.String("cost"), Value: aws.String("web")}},
+		}}},
+	}
+	plan := NewTagRemediationPlan(resources, NewTagCompliance(resources, p), r)
+
+	changes := make([]string, 0)
+	for _, c := range plan.Changes {
+		changes = append(changes, c.Resource.ID+" "+c.Key+"="+c.Value+" from "+c.Source)
+	}
+	assert.Equal(t, []string{
+		"i-asg cost=web from asg web",
+		"i-subnet cost=platform from vpc vpc-1",
+		"vol-1 cost=web from asg web",
+		"sg-1 cost=unallocated from default",
+	}, changes)
+
+	// Buckets can't be tagged with CreateTags and env has no remedy.
+	unresolved := make([]string, 0)
+	for _, v := range plan.Unresolved {
+		unresolved = append(unresolved, v.Resource.ID+" "+v.Rule.Key)
+	}
+	assert.Equal(t, []string{"logs cost", "logs env"}, unresolved)
+
+	assert.Equal(t, []string{
+		"4 Tags to Set on 4 Resources",
+		"2 Missing Tags Couldn't Be Resolved",
+	}, plan.Table().Summary)
+}
@@ -0,0 +1,160 @@
+package views
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// See https://docs.aws.amazon.com/AmazonRDS/latest/AuroraUserGuide/USER_WorkingWithReservedDBInstances.html
+// and https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ri-modifying.html
+// for more information about normalized instance units
+var normalizedUnits = map[string]float64{
+	"nano":      0.25,
+	"micro":     0.5,
+	"small":     1,
+	"medium":    2,
+	"large":     4,
+	"xlarge":    8,
+	"2xlarge":   16,
+	"3xlarge":   24,
+	"4xlarge":   32,
+	"6xlarge":   48,
+	"8xlarge":   64,
+	"9xlarge":   72,
+	"10xlarge":  80,
+	"12xlarge":  96,
+	"16xlarge":  128,
+	"18xlarge":  144,
+	"24xlarge":  192,
+	"32xlarge":  256,
+	"48xlarge":  384,
+	"56xlarge":  448,
+	"64xlarge":  512,
+	"72xlarge":  576,
+	"80xlarge":  640,
+	"96xlarge":  768,
+	"112xlarge": 896,
+}
+
+// metalUnits holds the normalized units of the metal size of each family,
+// which is the same as its largest virtualized size. Sizes such as
+// "metal-24xl" name their equivalent size instead, so they aren't listed.
+var metalUnits = map[string]float64{
+	"a1":     32,
+	"c5":     192,
+	"c5d":    192,
+	"c5n":    144,
+	"c6a":    384,
+	"c6g":    128,
+	"c6gd":   128,
+	"c6i":    256,
+	"c6id":   256,
+	"c6in":   256,
+	"c7g":    128,
+	"c7gd":   128,
+	"g4dn":   192,
+	"i3":     128,
+	"i3en":   192,
+	"i4i":    256,
+	"m5":     192,
+	"m5d":    192,
+	"m5dn":   192,
+	"m5n":    192,
+	"m5zn":   96,
+	"m6a":    384,
+	"m6g":    128,
+	"m6gd":   128,
+	"m6i":    256,
+	"m6id":   256,
+	"m6idn":  256,
+	"m6in":   256,
+	"m7g":    128,
+	"m7gd":   128,
+	"r5":     192,
+	"r5b":    192,
+	"r5d":    192,
+	"r5dn":   192,
+	"r5n":    192,
+	"r6a":    384,
+	"r6g":    128,
+	"r6gd":   128,
+	"r6i":    256,
+	"r6id":   256,
+	"r6idn":  256,
+	"r6in":   256,
+	"r7g":    128,
+	"r7gd":   128,
+	"x2gd":   128,
+	"x2idn":  256,
+	"x2iedn": 256,
+	"x2iezn": 96,
+	"z1d":    96,
+}
+
+// NormalizedUnits are additions to, or replacements of, the built in
+// normalized units, for sizes which are newer than this release. A nil
+// *NormalizedUnits only has the built in ones.
+type NormalizedUnits struct {
+	// Sizes are keyed by size, e.g. "24xlarge".
+	Sizes map[string]float64 `yaml:"sizes"`
+	// Metal is keyed by family, e.g. "m5", or "db.m5" for RDS.
+	Metal map[string]float64 `yaml:"metal"`
+}
+
+// LoadNormalizedUnits reads normalized units from a YAML or JSON file. They
+// are added to the built in ones, replacing any which are already known.
+func LoadNormalizedUnits(path string) (*NormalizedUnits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	u := &NormalizedUnits{}
+	if err := yaml.Unmarshal(data, u); err != nil {
+		return nil, fmt.Errorf("reading normalized units %s: %w", path, err)
+	}
+	return u, nil
+}
+
+// lookup returns the normalized units of a size in a family, and whether
+// they are known.
+func (u *NormalizedUnits) lookup(family, size string) (float64, bool) {
+	if size == "metal" {
+		if u != nil {
+			if units, ok := u.Metal[family]; ok {
+				return units, true
+			}
+		}
+		units, ok := metalUnits[family]
+		return units, ok
+	}
+	if strings.HasPrefix(size, "metal-") && strings.HasSuffix(size, "xl") {
+		size = strings.TrimPrefix(size, "metal-") + "arge"
+	}
+	if u != nil {
+		if units, ok := u.Sizes[size]; ok {
+			return units, true
+		}
+	}
+	units, ok := normalizedUnits[size]
+	return units, ok
+}
+
+// sizes returns every size with known normalized units, in no particular
+// order. Metal sizes aren't included.
+func (u *NormalizedUnits) sizes() []string {
+	sizes := make([]string, 0, len(normalizedUnits))
+	for size := range normalizedUnits {
+		sizes = append(sizes, size)
+	}
+	if u != nil {
+		for size := range u.Sizes {
+			if _, ok := normalizedUnits[size]; !ok {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,80 @@
+package views
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/jonstacks/aws/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeUnits(t *testing.T) {
+	cases := []struct {
+		instanceType string
+		units        float64
+		ok           bool
+	}{
+		{"m5.large", 4, true},
+		{"c5n.18xlarge", 144, true},
+		{"m5.metal", 192, true},
+		{"m6i.metal", 256, true},
+		{"m7i.metal-48xl", 384, true},
+		{"x2iezn.metal", 96, true},
+		{"u-6tb1.metal", 0, false},
+		{"m5.huge", 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.instanceType, func(t *testing.T) {
+			units, ok := ec2InstanceType(c.instanceType).normalizedUnits(nil)
+			assert.Equal(t, c.ok, ok)
+			assert.Equal(t, c.units, units)
+		})
+	}
+}
+
+func TestLoadNormalizedUnits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "units.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+sizes:
+  128xlarge: 1024
+  large: 5
+metal:
+  m99: 512
+`), 0o644))
+
+	u, err := LoadNormalizedUnits(path)
+	assert.NoError(t, err)
+	units, ok := ec2InstanceType("m99.128xlarge").normalizedUnits(u)
+	assert.True(t, ok)
+	assert.Equal(t, 1024.0, units)
+	units, ok = ec2InstanceType("m99.metal").normalizedUnits(u)
+	assert.True(t, ok)
+	assert.Equal(t, 512.0, units)
+	units, _ = ec2InstanceType("m5.large").normalizedUnits(u)
+	assert.Equal(t, 5.0, units)
+	assert.Contains(t, u.sizes(), "128xlarge")
+
+	// The built in units are left alone.
+	_, ok = ec2InstanceType("m99.128xlarge").normalizedUnits(nil)
+	assert.False(t, ok)
+	units, _ = ec2InstanceType("m5.large").normalizedUnits(nil)
+	assert.Equal(t, 4.0, units)
+
+	east := models.Scope{Region: "us-east-1"}
+	// Sizes which are only known from the file make reservations size
+	// flexible.
+	ru := NewReservationUtilization(
+		map[models.Scope][]*ec2.Instance{east: {makeEC2Instance("i-1", "m99.128xlarge")}},
+		map[models.Scope][]*ec2.ReservedInstances{east: {makeEC2Reservation("m99.128xlarge", 1)}},
+		ReservationUtilizationOptions{NormalizedUnits: u},
+	)
+	iru := ru.InstanceTypeReservationUtilizations[east]["m99"]
+	assert.Equal(t, 1024.0, iru.NumReserved)
+	assert.Equal(t, 1024.0, iru.NumRunning)
+
+	assert.NoError(t, os.WriteFile(path, []byte("sizes: ["), 0o644))
+	_, err = LoadNormalizedUnits(path)
+	assert.ErrorContains(t, err, "reading normalized units")
+}
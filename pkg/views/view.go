@@ -1,8 +1,30 @@
 package views
 
-import "io"
-
-// View is an interface which supports the Render method.
+// View is implemented by all of the views so they can be rendered in any of
+// the output formats. Table is used by the table, CSV and Markdown formats and
+// Records by the JSON and YAML formats. The records of each view are
+// documented in doc/output.md and their fields should only ever be added to,
+// so that scripts consuming them keep working.
 type View interface {
-	Render(io.Writer)
+	Table() *Table
+	Records() interface{}
+}
+
+// Table is the tabular representation of a view.
+type Table struct {
+	Header []string
+	Rows   [][]string
+	// Summary holds lines which are shown before the table by the
+	// human-readable formats.
+	Summary []string
+}
+
+// NewTable creates a table with the given header.
+func NewTable(header ...string) *Table {
+	return &Table{Header: header, Rows: make([][]string, 0)}
+}
+
+// Append adds a row to the table.
+func (t *Table) Append(row ...string) {
+	t.Rows = append(t.Rows, row)
 }